@@ -0,0 +1,225 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/ai-in-pm/Ollama-Code/api"
+)
+
+// DefaultSystemPrompt is used when the configuration has no "agent" system prompt
+const DefaultSystemPrompt = "You are a local coding agent working inside the user's project. " +
+	"Use the provided tools to inspect files before changing them, make small targeted edits, " +
+	"and run commands to verify your work. When the task is complete, reply with a short summary of what you did."
+
+// DefaultMaxIterations caps the number of model round-trips for a single task
+const DefaultMaxIterations = 10
+
+// maxToolOutput limits how much of a tool result is sent back to the model
+const maxToolOutput = 16 * 1024
+
+// ErrMaxIterations is returned when the model keeps calling tools past the iteration cap
+var ErrMaxIterations = errors.New("agent stopped: maximum number of iterations reached")
+
+// Action describes a tool invocation that may need the user's approval
+type Action struct {
+	Tool    string
+	Summary string
+	Args    api.ToolCallArguments
+}
+
+// Approver decides whether an action may run
+type Approver func(action Action) bool
+
+// Agent runs a chat loop in which the model can call tools until it produces a final answer
+type Agent struct {
+	Client          api.Provider
+	Model           string
+	Options         *api.Options
+	Tools           []*Tool
+	MaxIterations   int
+	AutoApprove     []string // Tool names that run without asking
+	AllowedCommands []string // Command prefixes run_command may execute without asking
+	Approve         Approver
+
+	// OnContent receives streamed assistant text
+	OnContent func(content string)
+	// OnToolResult is called after every tool call, whether it ran or not
+	OnToolResult func(action Action, result string)
+	// Unmask, if set, puts secrets that were masked in the conversation back into the text
+	// the file tools write: the content of write_file and the replacement of patch_file.
+	// Commands and searches never get them back.
+	Unmask func(text string) string
+	// UnmaskSearch, if set, does the same for the search text of patch_file, which has to
+	// match the file on disk
+	UnmaskSearch func(text string) string
+}
+
+// NewAgent creates an agent with the built-in tools rooted at the given workspace
+func NewAgent(client api.Provider, model string, ws *Workspace) *Agent {
+	return &Agent{
+		Client:        client,
+		Model:         model,
+		Tools:         BuiltinTools(ws),
+		MaxIterations: DefaultMaxIterations,
+	}
+}
+
+// Run continues the conversation until the model stops calling tools, returning the full message list
+func (a *Agent) Run(ctx context.Context, messages []api.ChatMessage) ([]api.ChatMessage, error) {
+	var definitions []api.Tool
+	for _, tool := range a.Tools {
+		definitions = append(definitions, tool.Definition)
+	}
+
+	maxIterations := a.MaxIterations
+	if maxIterations <= 0 {
+		maxIterations = DefaultMaxIterations
+	}
+
+	for i := 0; i < maxIterations; i++ {
+		var reply api.ChatMessage
+		err := a.Client.ChatStream(ctx, &api.ChatRequest{
+			Model:    a.Model,
+			Messages: messages,
+			Tools:    definitions,
+			Options:  a.Options,
+		}, func(resp interface{}) {
+			if chatResp, ok := resp.(*api.ChatResponse); ok {
+				reply.Append(chatResp.Message)
+				if a.OnContent != nil && chatResp.Message.Content != "" {
+					a.OnContent(chatResp.Message.Content)
+				}
+			}
+		})
+		if err != nil {
+			// Keep any text streamed before the failure, but not half-formed tool calls
+			if reply.Content != "" {
+				messages = append(messages, api.ChatMessage{Role: api.RoleAssistant, Content: reply.Content})
+			}
+			return messages, err
+		}
+
+		reply.Role = api.RoleAssistant
+		messages = append(messages, reply)
+		if len(reply.ToolCalls) == 0 {
+			return messages, nil
+		}
+
+		for _, call := range reply.ToolCalls {
+			result := a.execute(ctx, call)
+			messages = append(messages, api.NewToolResultMessage(call.Function.Name, result))
+		}
+	}
+
+	return messages, ErrMaxIterations
+}
+
+// execute runs a single tool call after checking approval, returning the text sent back to the model
+func (a *Agent) execute(ctx context.Context, call api.ToolCall) string {
+	name := call.Function.Name
+	args := call.Function.Arguments
+	if args == nil {
+		args = api.ToolCallArguments{}
+	}
+
+	tool := a.findTool(name)
+	if tool == nil {
+		return fmt.Sprintf("Error: unknown tool %q", name)
+	}
+	args = a.unmask(name, args)
+
+	action := Action{Tool: name, Summary: name, Args: args}
+	if tool.Describe != nil {
+		action.Summary = tool.Describe(args)
+	}
+
+	var result string
+	if !a.autoApproved(tool, args) && (a.Approve == nil || !a.Approve(action)) {
+		result = "The user denied this action. Do not retry it; ask the user or choose a different approach."
+	} else if output, err := tool.Run(ctx, args); err != nil {
+		result = "Error: " + err.Error()
+	} else {
+		result = output
+	}
+
+	if len(result) > maxToolOutput {
+		// Cut at the start of a character so the model is not sent invalid UTF-8
+		cut := maxToolOutput
+		for cut > 0 && !utf8.RuneStart(result[cut]) {
+			cut--
+		}
+		result = result[:cut] + fmt.Sprintf("\n(output truncated to %d bytes)", maxToolOutput)
+	}
+	if a.OnToolResult != nil {
+		a.OnToolResult(action, result)
+	}
+	return result
+}
+
+func (a *Agent) findTool(name string) *Tool {
+	for _, tool := range a.Tools {
+		if tool.Name() == name {
+			return tool
+		}
+	}
+	return nil
+}
+
+// unmask applies Unmask and UnmaskSearch to the arguments of the file writing tools
+func (a *Agent) unmask(name string, args api.ToolCallArguments) api.ToolCallArguments {
+	var unmaskers map[string]func(string) string
+	switch name {
+	case "write_file":
+		unmaskers = map[string]func(string) string{"content": a.Unmask}
+	case "patch_file":
+		unmaskers = map[string]func(string) string{"search": a.UnmaskSearch, "replace": a.Unmask}
+	default:
+		return args
+	}
+
+	unmasked := make(api.ToolCallArguments, len(args))
+	for key, value := range args {
+		if text, ok := value.(string); ok && unmaskers[key] != nil {
+			value = unmaskers[key](text)
+		}
+		unmasked[key] = value
+	}
+	return unmasked
+}
+
+// autoApproved reports whether a call is covered by the allowlists
+func (a *Agent) autoApproved(tool *Tool, args api.ToolCallArguments) bool {
+	for _, name := range a.AutoApprove {
+		if name == tool.Name() {
+			return true
+		}
+	}
+
+	if tool.Name() == "run_command" {
+		return commandAllowed(args.String("command"), a.AllowedCommands)
+	}
+	return false
+}
+
+// commandAllowed reports whether a command line starts with an allowed prefix and
+// does not chain further commands through shell operators
+func commandAllowed(command string, allowed []string) bool {
+	command = strings.TrimSpace(command)
+	if command == "" || strings.ContainsAny(command, ";&|`$()<>\n") {
+		return false
+	}
+	for _, prefix := range allowed {
+		prefix = strings.TrimSpace(prefix)
+		if prefix == "" {
+			continue
+		}
+		if command == prefix || strings.HasPrefix(command, prefix+" ") {
+			return true
+		}
+	}
+	return false
+}
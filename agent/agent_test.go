@@ -0,0 +1,53 @@
+package agent
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ai-in-pm/Ollama-Code/api"
+)
+
+func TestUnmaskOnlyFileContent(t *testing.T) {
+	restore := func(text string) string { return strings.ReplaceAll(text, "REDACTED_SECRET_1", "hunter2") }
+	a := &Agent{Unmask: restore, UnmaskSearch: restore}
+
+	tests := []struct {
+		tool string
+		args api.ToolCallArguments
+		want api.ToolCallArguments
+	}{
+		{
+			"write_file",
+			api.ToolCallArguments{"path": "REDACTED_SECRET_1", "content": "pw = REDACTED_SECRET_1"},
+			api.ToolCallArguments{"path": "REDACTED_SECRET_1", "content": "pw = hunter2"},
+		},
+		{
+			"patch_file",
+			api.ToolCallArguments{"path": "a.go", "search": "REDACTED_SECRET_1", "replace": "x REDACTED_SECRET_1"},
+			api.ToolCallArguments{"path": "a.go", "search": "hunter2", "replace": "x hunter2"},
+		},
+		{
+			"run_command",
+			api.ToolCallArguments{"command": `curl -H "Authorization: REDACTED_SECRET_1" example.com`},
+			api.ToolCallArguments{"command": `curl -H "Authorization: REDACTED_SECRET_1" example.com`},
+		},
+		{
+			"grep",
+			api.ToolCallArguments{"pattern": "REDACTED_SECRET_1"},
+			api.ToolCallArguments{"pattern": "REDACTED_SECRET_1"},
+		},
+		{
+			"read_file",
+			api.ToolCallArguments{"path": "REDACTED_SECRET_1"},
+			api.ToolCallArguments{"path": "REDACTED_SECRET_1"},
+		},
+	}
+	for _, tt := range tests {
+		got := a.unmask(tt.tool, tt.args)
+		for key, want := range tt.want {
+			if got[key] != want {
+				t.Errorf("%s %s = %v, want %v", tt.tool, key, got[key], want)
+			}
+		}
+	}
+}
@@ -0,0 +1,415 @@
+package agent
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ai-in-pm/Ollama-Code/api"
+)
+
+// Tool is a built-in capability the model can invoke through the chat API
+type Tool struct {
+	Definition api.Tool
+	ReadOnly   bool
+	Describe   func(args api.ToolCallArguments) string
+	Run        func(ctx context.Context, args api.ToolCallArguments) (string, error)
+}
+
+// Name returns the function name the model uses to call the tool
+func (t *Tool) Name() string {
+	return t.Definition.Function.Name
+}
+
+// Workspace confines tool file access to a root directory
+type Workspace struct {
+	Root string
+	// Ignore, if set, reports whether a file must not be shown to the model, e.g. because
+	// the project's ignore files exclude it. Ignored files are not read, listed, searched,
+	// written or patched.
+	Ignore func(path string) bool
+}
+
+// Directories skipped when searching the workspace
+var skipDirs = map[string]bool{
+	".git": true, "node_modules": true, "__pycache__": true, "venv": true, ".venv": true,
+}
+
+const (
+	maxGrepMatches = 200
+	commandTimeout = 2 * time.Minute
+)
+
+// Resolve turns a tool-supplied path into an absolute path inside the workspace
+func (w *Workspace) Resolve(path string) (string, error) {
+	root, err := filepath.Abs(w.Root)
+	if err != nil {
+		return "", err
+	}
+	if path == "" {
+		path = "."
+	}
+
+	resolved := path
+	if !filepath.IsAbs(resolved) {
+		resolved = filepath.Join(root, resolved)
+	}
+	resolved = filepath.Clean(resolved)
+	if !within(root, resolved) {
+		return "", fmt.Errorf("path %q is outside the workspace", path)
+	}
+
+	// A symlink inside the workspace may point outside it
+	realRoot, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return "", err
+	}
+	real, err := evalExistingSymlinks(resolved)
+	if err != nil {
+		return "", err
+	}
+	if !within(realRoot, real) {
+		return "", fmt.Errorf("path %q is outside the workspace", path)
+	}
+	return resolved, nil
+}
+
+// within reports whether path is root or inside it; both must be clean and absolute
+func within(root string, path string) bool {
+	rel, err := filepath.Rel(root, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(os.PathSeparator))
+}
+
+// evalExistingSymlinks resolves the symlinks in the longest prefix of path that exists and
+// appends the rest, so that a file about to be created is checked where it will be written
+func evalExistingSymlinks(path string) (string, error) {
+	var rest []string
+	for {
+		real, err := filepath.EvalSymlinks(path)
+		if err == nil {
+			return filepath.Join(append([]string{real}, rest...)...), nil
+		}
+		if !os.IsNotExist(err) {
+			return "", err
+		}
+		parent := filepath.Dir(path)
+		if parent == path {
+			return "", err
+		}
+		rest = append([]string{filepath.Base(path)}, rest...)
+		path = parent
+	}
+}
+
+// ignored reports whether a path is hidden from the model
+func (w *Workspace) ignored(path string) bool {
+	return w.Ignore != nil && w.Ignore(path)
+}
+
+// resolveVisible resolves a path and fails if the file is hidden from the model
+func (w *Workspace) resolveVisible(path string) (string, error) {
+	resolved, err := w.Resolve(path)
+	if err != nil {
+		return "", err
+	}
+	if w.ignored(resolved) {
+		return "", fmt.Errorf("%s is excluded by the project's ignore files", displayPath(path))
+	}
+	return resolved, nil
+}
+
+// BuiltinTools returns the read, search, write and shell tools for a workspace
+func BuiltinTools(ws *Workspace) []*Tool {
+	return []*Tool{
+		{
+			Definition: api.NewTool("read_file", "Read a text file from the project. Lines are prefixed with their line numbers.",
+				map[string]api.ToolProperty{
+					"path":       {Type: "string", Description: "File path relative to the project root"},
+					"start_line": {Type: "integer", Description: "First line to read (1-based, optional)"},
+					"end_line":   {Type: "integer", Description: "Last line to read (inclusive, optional)"},
+				}, "path"),
+			ReadOnly: true,
+			Describe: func(args api.ToolCallArguments) string {
+				return "read " + args.String("path")
+			},
+			Run: ws.readFile,
+		},
+		{
+			Definition: api.NewTool("list_dir", "List the entries of a directory in the project. Directories end with a slash.",
+				map[string]api.ToolProperty{
+					"path": {Type: "string", Description: "Directory path relative to the project root (default: root)"},
+				}),
+			ReadOnly: true,
+			Describe: func(args api.ToolCallArguments) string {
+				return "list " + displayPath(args.String("path"))
+			},
+			Run: ws.listDir,
+		},
+		{
+			Definition: api.NewTool("grep", "Search project files for a regular expression and return matching lines as file:line: text.",
+				map[string]api.ToolProperty{
+					"pattern": {Type: "string", Description: "Regular expression (RE2 syntax)"},
+					"path":    {Type: "string", Description: "File or directory to search (default: root)"},
+				}, "pattern"),
+			ReadOnly: true,
+			Describe: func(args api.ToolCallArguments) string {
+				return fmt.Sprintf("grep %q in %s", args.String("pattern"), displayPath(args.String("path")))
+			},
+			Run: ws.grep,
+		},
+		{
+			Definition: api.NewTool("write_file", "Create or overwrite a file in the project with the given content.",
+				map[string]api.ToolProperty{
+					"path":    {Type: "string", Description: "File path relative to the project root"},
+					"content": {Type: "string", Description: "Complete new file content"},
+				}, "path", "content"),
+			Describe: func(args api.ToolCallArguments) string {
+				return fmt.Sprintf("write %s (%d bytes)", args.String("path"), len(args.String("content")))
+			},
+			Run: ws.writeFile,
+		},
+		{
+			Definition: api.NewTool("patch_file", "Replace one exact occurrence of a block of text in a file.",
+				map[string]api.ToolProperty{
+					"path":    {Type: "string", Description: "File path relative to the project root"},
+					"search":  {Type: "string", Description: "Exact text to find; must occur exactly once"},
+					"replace": {Type: "string", Description: "Text to put in its place"},
+				}, "path", "search", "replace"),
+			Describe: func(args api.ToolCallArguments) string {
+				return fmt.Sprintf("patch %s:\n--- search\n%s\n+++ replace\n%s", args.String("path"), args.String("search"), args.String("replace"))
+			},
+			Run: ws.patchFile,
+		},
+		{
+			Definition: api.NewTool("run_command", "Run a shell command in the project root and return its combined output and exit status.",
+				map[string]api.ToolProperty{
+					"command": {Type: "string", Description: "Command line passed to sh -c"},
+				}, "command"),
+			Describe: func(args api.ToolCallArguments) string {
+				return "run `" + args.String("command") + "`"
+			},
+			Run: ws.runCommand,
+		},
+	}
+}
+
+func displayPath(path string) string {
+	if path == "" {
+		return "."
+	}
+	return path
+}
+
+func (w *Workspace) readFile(ctx context.Context, args api.ToolCallArguments) (string, error) {
+	path, err := w.resolveVisible(args.String("path"))
+	if err != nil {
+		return "", err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+
+	lines := strings.Split(string(data), "\n")
+	start := args.Int("start_line", 1)
+	end := args.Int("end_line", len(lines))
+	if start < 1 {
+		start = 1
+	}
+	if end > len(lines) {
+		end = len(lines)
+	}
+	if start > end {
+		return "", fmt.Errorf("invalid line range %d-%d (file has %d lines)", start, end, len(lines))
+	}
+
+	var sb strings.Builder
+	for i := start; i <= end; i++ {
+		sb.WriteString(fmt.Sprintf("%d\t%s\n", i, lines[i-1]))
+	}
+	return sb.String(), nil
+}
+
+func (w *Workspace) listDir(ctx context.Context, args api.ToolCallArguments) (string, error) {
+	path, err := w.resolveVisible(args.String("path"))
+	if err != nil {
+		return "", err
+	}
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return "", err
+	}
+
+	var names []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if w.ignored(filepath.Join(path, name)) {
+			continue
+		}
+		if entry.IsDir() {
+			name += "/"
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	if len(names) == 0 {
+		return "(empty directory)", nil
+	}
+	return strings.Join(names, "\n"), nil
+}
+
+func (w *Workspace) grep(ctx context.Context, args api.ToolCallArguments) (string, error) {
+	re, err := regexp.Compile(args.String("pattern"))
+	if err != nil {
+		return "", fmt.Errorf("invalid pattern: %w", err)
+	}
+	start, err := w.resolveVisible(args.String("path"))
+	if err != nil {
+		return "", err
+	}
+	root, _ := filepath.Abs(w.Root)
+
+	var matches []string
+	err = filepath.Walk(start, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if info.IsDir() {
+			if path != start && (skipDirs[info.Name()] || w.ignored(path)) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if w.ignored(path) {
+			return nil
+		}
+		if info.Mode()&os.ModeSymlink != 0 {
+			// Only follow links that stay inside the workspace
+			if _, err := w.Resolve(path); err != nil {
+				return nil
+			}
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil || bytes.IndexByte(data, 0) >= 0 {
+			return nil // Unreadable or binary
+		}
+
+		rel, _ := filepath.Rel(root, path)
+		scanner := bufio.NewScanner(bytes.NewReader(data))
+		scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+		lineNum := 0
+		for scanner.Scan() {
+			lineNum++
+			if re.MatchString(scanner.Text()) {
+				matches = append(matches, fmt.Sprintf("%s:%d: %s", rel, lineNum, strings.TrimSpace(scanner.Text())))
+				if len(matches) >= maxGrepMatches {
+					return filepath.SkipAll
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	if len(matches) == 0 {
+		return "No matches found.", nil
+	}
+	result := strings.Join(matches, "\n")
+	if len(matches) >= maxGrepMatches {
+		result += fmt.Sprintf("\n(stopped after %d matches)", maxGrepMatches)
+	}
+	return result, nil
+}
+
+func (w *Workspace) writeFile(ctx context.Context, args api.ToolCallArguments) (string, error) {
+	path, err := w.resolveVisible(args.String("path"))
+	if err != nil {
+		return "", err
+	}
+	content := args.String("content")
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("Wrote %d bytes to %s", len(content), args.String("path")), nil
+}
+
+func (w *Workspace) patchFile(ctx context.Context, args api.ToolCallArguments) (string, error) {
+	path, err := w.resolveVisible(args.String("path"))
+	if err != nil {
+		return "", err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+
+	content := string(data)
+	search := args.String("search")
+	if search == "" {
+		return "", fmt.Errorf("search text must not be empty")
+	}
+	switch count := strings.Count(content, search); count {
+	case 0:
+		return "", fmt.Errorf("search text not found in %s", args.String("path"))
+	case 1:
+	default:
+		return "", fmt.Errorf("search text occurs %d times in %s; include more surrounding lines", count, args.String("path"))
+	}
+
+	content = strings.Replace(content, search, args.String("replace"), 1)
+	if err := os.WriteFile(path, []byte(content), info.Mode().Perm()); err != nil {
+		return "", err
+	}
+	return "Patched " + args.String("path"), nil
+}
+
+func (w *Workspace) runCommand(ctx context.Context, args api.ToolCallArguments) (string, error) {
+	command := args.String("command")
+	if strings.TrimSpace(command) == "" {
+		return "", fmt.Errorf("command must not be empty")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, commandTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	cmd.Dir = w.Root
+	output, err := cmd.CombinedOutput()
+
+	result := string(output)
+	if err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return result + fmt.Sprintf("\n(command timed out after %s)", commandTimeout), nil
+		}
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			return result + fmt.Sprintf("\n(exit status %d)", exitErr.ExitCode()), nil
+		}
+		return "", err
+	}
+	if result == "" {
+		result = "(no output)"
+	}
+	return result, nil
+}
@@ -0,0 +1,174 @@
+package agent
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/ai-in-pm/Ollama-Code/api"
+)
+
+func TestCommandAllowed(t *testing.T) {
+	allowed := []string{"go test", "git status", " ls ", ""}
+	tests := []struct {
+		command string
+		want    bool
+	}{
+		{"go test", true},
+		{"go test ./...", true},
+		{"  git status  ", true},
+		{"ls -la", true},
+		{"go testify", false}, // Prefixes match whole words
+		{"go build", false},
+		{"", false},
+		{"go test; rm -rf /", false},
+		{"go test && curl example.com", false},
+		{"go test | sh", false},
+		{"go test $(cat secret)", false},
+		{"go test `id`", false},
+		{"go test > out.txt", false},
+		{"go test\nrm -rf /", false},
+	}
+	for _, tt := range tests {
+		if got := commandAllowed(tt.command, allowed); got != tt.want {
+			t.Errorf("commandAllowed(%q) = %v, want %v", tt.command, got, tt.want)
+		}
+	}
+}
+
+func TestWorkspaceResolve(t *testing.T) {
+	root := t.TempDir()
+	outside := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, "src"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(outside, filepath.Join(root, "escape")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(root, "src"), filepath.Join(root, "alias")); err != nil {
+		t.Fatal(err)
+	}
+
+	ws := &Workspace{Root: root}
+	tests := []struct {
+		path string
+		want string // Empty if the path must be rejected
+	}{
+		{"", root},
+		{".", root},
+		{"src/main.go", filepath.Join(root, "src", "main.go")},
+		{"new/dir/file.go", filepath.Join(root, "new", "dir", "file.go")},
+		{"src/../main.go", filepath.Join(root, "main.go")},
+		{filepath.Join(root, "src"), filepath.Join(root, "src")},
+		{"alias/main.go", filepath.Join(root, "alias", "main.go")}, // Symlink that stays inside
+		{"..", ""},
+		{"../x", ""},
+		{"src/../../x", ""},
+		{outside, ""},
+		{"escape", ""},            // Symlink pointing outside
+		{"escape/new.go", ""},     // A new file below it
+		{"escape/a/b/new.go", ""}, // Even with missing directories in between
+		{"alias/../../x", ""},     // Cleaned lexically first
+		{"/etc/passwd", ""},       // Absolute path elsewhere
+		{root + "-sibling/x", ""}, // Shares the root's name as a prefix
+	}
+	for _, tt := range tests {
+		got, err := ws.Resolve(tt.path)
+		if tt.want == "" {
+			if err == nil {
+				t.Errorf("Resolve(%q) = %q, want an error", tt.path, got)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("Resolve(%q) = %q, %v, want %q", tt.path, got, err, tt.want)
+		}
+	}
+}
+
+func TestToolsHideIgnoredFiles(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{
+		".env":            "TOKEN=hunter2\n",
+		"main.go":         "package main // TOKEN\n",
+		"secret/key.pem":  "TOKEN\n",
+		"secret/other.go": "TOKEN\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Like ContextManager.ShouldIgnore, files inside an ignored directory are ignored too
+	ws := &Workspace{Root: root, Ignore: func(path string) bool {
+		rel, _ := filepath.Rel(root, path)
+		for _, part := range strings.Split(filepath.ToSlash(rel), "/") {
+			if part == ".env" || part == "secret" {
+				return true
+			}
+		}
+		return false
+	}}
+	ctx := context.Background()
+
+	rejected := []struct {
+		name string
+		run  func(ctx context.Context, args api.ToolCallArguments) (string, error)
+		args api.ToolCallArguments
+	}{
+		{"read", ws.readFile, api.ToolCallArguments{"path": ".env"}},
+		{"list", ws.listDir, api.ToolCallArguments{"path": "secret"}},
+		{"grep", ws.grep, api.ToolCallArguments{"pattern": "TOKEN", "path": ".env"}},
+		{"write", ws.writeFile, api.ToolCallArguments{"path": ".env", "content": "TOKEN=x\n"}},
+		{"create", ws.writeFile, api.ToolCallArguments{"path": "secret/new.pem", "content": "x"}},
+		{"patch", ws.patchFile, api.ToolCallArguments{"path": ".env", "search": "hunter2", "replace": "x"}},
+	}
+	for _, tt := range rejected {
+		output, err := tt.run(ctx, tt.args)
+		if err == nil || !strings.Contains(err.Error(), "excluded by the project's ignore files") {
+			t.Errorf("%s: output %q, error %v, want it excluded", tt.name, output, err)
+		}
+	}
+	if data, _ := os.ReadFile(filepath.Join(root, ".env")); string(data) != files[".env"] {
+		t.Errorf(".env was changed: %q", data)
+	}
+	if _, err := os.Stat(filepath.Join(root, "secret", "new.pem")); !os.IsNotExist(err) {
+		t.Errorf("a file was created in an ignored directory: %v", err)
+	}
+
+	listing, err := ws.listDir(ctx, api.ToolCallArguments{})
+	if err != nil || listing != "main.go" {
+		t.Errorf("list_dir = %q, %v, want only main.go", listing, err)
+	}
+	matches, err := ws.grep(ctx, api.ToolCallArguments{"pattern": "TOKEN"})
+	if err != nil || matches != "main.go:1: package main // TOKEN" {
+		t.Errorf("grep = %q, %v, want only the match in main.go", matches, err)
+	}
+	if _, err := ws.writeFile(ctx, api.ToolCallArguments{"path": "util.go", "content": "package main\n"}); err != nil {
+		t.Errorf("write_file of a visible file: %v", err)
+	}
+}
+
+func TestToolOutputTruncatedOnCharacterBoundary(t *testing.T) {
+	long := &Tool{
+		Definition: api.NewTool("long", "Print a lot", nil),
+		Run: func(ctx context.Context, args api.ToolCallArguments) (string, error) {
+			return "x" + strings.Repeat("é", maxToolOutput), nil
+		},
+	}
+	a := &Agent{Tools: []*Tool{long}, AutoApprove: []string{"long"}}
+	result := a.execute(context.Background(), api.ToolCall{Function: api.ToolCallFunction{Name: "long"}})
+	if !utf8.ValidString(result) {
+		t.Error("truncated output is not valid UTF-8")
+	}
+	if !strings.HasSuffix(result, "(output truncated to 16384 bytes)") || len(result) > maxToolOutput+64 {
+		t.Errorf("output not truncated: %d bytes", len(result))
+	}
+}
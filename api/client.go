@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -12,20 +13,45 @@ import (
 
 // OllamaClient provides a client for the Ollama API
 type OllamaClient struct {
-	BaseURL      string
-	HTTPClient   *http.Client
+	baseClient
 	DefaultModel string
 }
 
+// baseClient sends requests with the retries, timeouts and prompt filtering shared by the
+// clients of all providers
+type baseClient struct {
+	BaseURL    string
+	HTTPClient *http.Client
+	Retry      RetryPolicy
+	Timeouts   Timeouts
+	Filter     PromptFilter // Applied to all outbound text; nil sends it unchanged
+	Header     http.Header  // Added to every request, e.g. Authorization
+}
+
+// RetryPolicy controls how failed requests are retried. Only failures that happen
+// before a response starts streaming are retried, so no output is ever duplicated.
+type RetryPolicy struct {
+	MaxRetries     int           // Retries after the first attempt; 0 disables retrying
+	InitialBackoff time.Duration // Delay before the first retry, doubled for each further one
+	MaxBackoff     time.Duration // Upper bound for a single delay
+}
+
+// DefaultRetryPolicy is used by NewClient
+var DefaultRetryPolicy = RetryPolicy{
+	MaxRetries:     2,
+	InitialBackoff: 500 * time.Millisecond,
+	MaxBackoff:     8 * time.Second,
+}
+
 // GenerateRequest represents a request to the Ollama API for text generation
 type GenerateRequest struct {
-	Model   string                 `json:"model"`
-	Prompt  string                 `json:"prompt"`
-	System  string                 `json:"system,omitempty"`
-	Context []int                  `json:"context,omitempty"`
-	Stream  bool                   `json:"stream"`
-	Raw     bool                   `json:"raw,omitempty"`
-	Options map[string]interface{} `json:"options,omitempty"`
+	Model   string   `json:"model"`
+	Prompt  string   `json:"prompt"`
+	System  string   `json:"system,omitempty"`
+	Context []int    `json:"context,omitempty"`
+	Stream  bool     `json:"stream"`
+	Raw     bool     `json:"raw,omitempty"`
+	Options *Options `json:"options,omitempty"`
 }
 
 // GenerateResponse represents a response from the Ollama API for text generation
@@ -40,24 +66,29 @@ type GenerateResponse struct {
 
 // ChatMessage represents a single message in a chat conversation
 type ChatMessage struct {
-	Role    string `json:"role"`
-	Content string `json:"content"`
+	Role      string     `json:"role"`
+	Content   string     `json:"content"`
+	ToolCalls []ToolCall `json:"tool_calls,omitempty"`
+	ToolName  string     `json:"tool_name,omitempty"`
 }
 
 // ChatRequest represents a request to the Ollama API for chat
 type ChatRequest struct {
-	Model    string                 `json:"model"`
-	Messages []ChatMessage          `json:"messages"`
-	Stream   bool                   `json:"stream"`
-	Options  map[string]interface{} `json:"options,omitempty"`
+	Model    string        `json:"model"`
+	Messages []ChatMessage `json:"messages"`
+	Tools    []Tool        `json:"tools,omitempty"`
+	Stream   bool          `json:"stream"`
+	Format   interface{}   `json:"format,omitempty"` // "json" or a JSON schema the answer must follow
+	Options  *Options      `json:"options,omitempty"`
 }
 
 // ChatResponse represents a response from the Ollama API for chat
 type ChatResponse struct {
-	Model   string      `json:"model"`
-	Message ChatMessage `json:"message"`
-	Done    bool        `json:"done"`
-	Error   string      `json:"error,omitempty"`
+	Model      string      `json:"model"`
+	Message    ChatMessage `json:"message"`
+	Done       bool        `json:"done"`
+	DoneReason string      `json:"done_reason,omitempty"`
+	Error      string      `json:"error,omitempty"`
 }
 
 // StreamHandler is a function that handles streaming responses
@@ -65,53 +96,175 @@ type StreamHandler func(response interface{})
 
 // NewClient creates a new OllamaClient with the given base URL
 func NewClient(baseURL string, defaultModel string) *OllamaClient {
-	return &OllamaClient{
-		BaseURL:      baseURL,
-		HTTPClient:   &http.Client{Timeout: time.Second * 60},
-		DefaultModel: defaultModel,
+	c := &OllamaClient{DefaultModel: defaultModel}
+	c.init(baseURL)
+	return c
+}
+
+// init sets up the client for a server with the default retry policy and timeouts
+func (c *baseClient) init(baseURL string) {
+	c.BaseURL = baseURL
+	c.Retry = DefaultRetryPolicy
+	c.Timeouts = DefaultTimeouts
+
+	// No overall timeout: long generations are bounded by the per-phase Timeouts instead
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = c.dialContext
+	c.HTTPClient = &http.Client{Transport: transport}
+}
+
+// backoff returns the delay before the given retry (starting at 1)
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	delay := p.InitialBackoff
+	if delay <= 0 {
+		delay = DefaultRetryPolicy.InitialBackoff
+	}
+	for i := 1; i < attempt; i++ {
+		delay *= 2
+		if p.MaxBackoff > 0 && delay >= p.MaxBackoff {
+			return p.MaxBackoff
+		}
+	}
+	if p.MaxBackoff > 0 && delay > p.MaxBackoff {
+		return p.MaxBackoff
 	}
+	return delay
 }
 
-// Generate sends a prompt to the Ollama API and returns the generated text
-func (c *OllamaClient) Generate(ctx context.Context, req *GenerateRequest) (*GenerateResponse, error) {
-	if req.Model == "" {
-		req.Model = c.DefaultModel
+// do sends a request and returns the response once the server has accepted it with
+// 200 OK, retrying retryable failures according to the client's retry policy.
+// The caller must close the response body.
+func (c *baseClient) do(ctx context.Context, method string, path string, payload interface{}) (*http.Response, error) {
+	var data []byte
+	if payload != nil {
+		var err error
+		if data, err = json.Marshal(c.filterPayload(payload)); err != nil {
+			return nil, fmt.Errorf("failed to marshal request: %w", err)
+		}
 	}
-	req.Stream = false
 
-	jsonData, err := json.Marshal(req)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	for attempt := 0; ; attempt++ {
+		resp, err := c.send(ctx, method, path, data)
+		if err == nil {
+			return resp, nil
+		}
+		if attempt >= c.Retry.MaxRetries || !IsRetryable(err) {
+			return nil, err
+		}
+
+		delay := c.Retry.backoff(attempt + 1)
+		var apiErr *APIError
+		if errors.As(err, &apiErr) && apiErr.RetryAfter > delay {
+			delay = apiErr.RetryAfter
+		}
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("%w: %w", ErrCanceled, ctx.Err())
+		case <-time.After(delay):
+		}
 	}
+}
 
-	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, fmt.Sprintf("%s/api/generate", c.BaseURL), bytes.NewBuffer(jsonData))
+// send performs a single HTTP round trip
+func (c *baseClient) send(ctx context.Context, method string, path string, data []byte) (*http.Response, error) {
+	var body io.Reader
+	if data != nil {
+		body = bytes.NewReader(data)
+	}
+	reqCtx, w := newWatchdog(ctx, c.Timeouts)
+	httpReq, err := http.NewRequestWithContext(reqCtx, method, c.BaseURL+path, body)
 	if err != nil {
+		w.stop()
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
-	httpReq.Header.Set("Content-Type", "application/json")
+	if data != nil {
+		httpReq.Header.Set("Content-Type", "application/json")
+	}
+	for name, values := range c.Header {
+		httpReq.Header[http.CanonicalHeaderKey(name)] = values
+	}
 
 	resp, err := c.HTTPClient.Do(httpReq)
 	if err != nil {
-		return nil, fmt.Errorf("failed to send request: %w", err)
+		timeoutErr := timeoutCause(reqCtx)
+		w.stop()
+		if timeoutErr != nil {
+			return nil, timeoutErr
+		}
+		return nil, classifyTransportError(ctx, c.BaseURL, err)
 	}
+	resp.Body = &watchedBody{ReadCloser: resp.Body, ctx: reqCtx, w: w}
+	if resp.StatusCode != http.StatusOK {
+		defer func() { _ = resp.Body.Close() }()
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+		return nil, newAPIError(resp, respBody)
+	}
+	return resp, nil
+}
+
+// readResponse decodes a complete JSON response body into v
+func readResponse(ctx context.Context, resp *http.Response, v interface{}) error {
 	defer func() { _ = resp.Body.Close() }()
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		if ctx.Err() != nil {
+			return fmt.Errorf("%w: %w", ErrCanceled, ctx.Err())
+		}
+		if errors.Is(err, ErrTimeout) {
+			return err
+		}
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
 
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("received non-OK response: %s, body: %s", resp.Status, string(body))
+// streamResponse decodes newline-delimited JSON objects from the response body, calling
+// next with a fresh decode target until it reports that the stream is done. A stream that
+// ends before that fails with ErrIncompleteResponse.
+func streamResponse(ctx context.Context, resp *http.Response, next func(decode func(v interface{}) error) (bool, error)) error {
+	defer func() { _ = resp.Body.Close() }()
+	decoder := json.NewDecoder(resp.Body)
+	decode := func(v interface{}) error {
+		err := decoder.Decode(v)
+		switch {
+		case err == nil, errors.Is(err, ErrTimeout):
+			return err
+		case errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+			return fmt.Errorf("%w: %w", ErrIncompleteResponse, io.ErrUnexpectedEOF)
+		}
+		return fmt.Errorf("failed to decode stream: %w", err)
+	}
+	for {
+		done, err := next(decode)
+		if err != nil {
+			if ctx.Err() != nil {
+				return fmt.Errorf("%w: %w", ErrCanceled, ctx.Err())
+			}
+			return err
+		}
+		if done {
+			return nil
+		}
 	}
+}
 
-	body, err := io.ReadAll(resp.Body)
+// Generate sends a prompt to the Ollama API and returns the generated text
+func (c *OllamaClient) Generate(ctx context.Context, req *GenerateRequest) (*GenerateResponse, error) {
+	if req.Model == "" {
+		req.Model = c.DefaultModel
+	}
+	req.Stream = false
+
+	resp, err := c.do(ctx, http.MethodPost, "/api/generate", req)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read response body: %w", err)
+		return nil, err
 	}
 
 	var genResp GenerateResponse
-	if err := json.Unmarshal(body, &genResp); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal response: %w", err)
+	if err := readResponse(ctx, resp, &genResp); err != nil {
+		return nil, err
 	}
 	if genResp.Error != "" {
-		return nil, fmt.Errorf("API error: %s", genResp.Error)
+		return nil, streamError(genResp.Error)
 	}
 	return &genResp, nil
 }
@@ -123,46 +276,22 @@ func (c *OllamaClient) GenerateStream(ctx context.Context, req *GenerateRequest,
 	}
 	req.Stream = true
 
-	jsonData, err := json.Marshal(req)
-	if err != nil {
-		return fmt.Errorf("failed to marshal request: %w", err)
-	}
-
-	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, fmt.Sprintf("%s/api/generate", c.BaseURL), bytes.NewBuffer(jsonData))
+	resp, err := c.do(ctx, http.MethodPost, "/api/generate", req)
 	if err != nil {
-		return fmt.Errorf("failed to create request: %w", err)
+		return err
 	}
-	httpReq.Header.Set("Content-Type", "application/json")
 
-	resp, err := c.HTTPClient.Do(httpReq)
-	if err != nil {
-		return fmt.Errorf("failed to send request: %w", err)
-	}
-	defer func() { _ = resp.Body.Close() }()
-
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("received non-OK response: %s, body: %s", resp.Status, string(body))
-	}
-
-	decoder := json.NewDecoder(resp.Body)
-	for {
+	return streamResponse(ctx, resp, func(decode func(v interface{}) error) (bool, error) {
 		var genResp GenerateResponse
-		if err := decoder.Decode(&genResp); err != nil {
-			if ctx.Err() != nil {
-				return ctx.Err()
-			}
-			break
+		if err := decode(&genResp); err != nil {
+			return false, err
 		}
 		if genResp.Error != "" {
-			return fmt.Errorf("API error: %s", genResp.Error)
+			return false, streamError(genResp.Error)
 		}
 		handler(&genResp)
-		if genResp.Done {
-			break
-		}
-	}
-	return nil
+		return genResp.Done, nil
+	})
 }
 
 // Chat sends a chat request to the Ollama API
@@ -172,39 +301,17 @@ func (c *OllamaClient) Chat(ctx context.Context, req *ChatRequest) (*ChatRespons
 	}
 	req.Stream = false
 
-	jsonData, err := json.Marshal(req)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal request: %w", err)
-	}
-
-	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, fmt.Sprintf("%s/api/chat", c.BaseURL), bytes.NewBuffer(jsonData))
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
-	}
-	httpReq.Header.Set("Content-Type", "application/json")
-
-	resp, err := c.HTTPClient.Do(httpReq)
-	if err != nil {
-		return nil, fmt.Errorf("failed to send request: %w", err)
-	}
-	defer func() { _ = resp.Body.Close() }()
-
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("received non-OK response: %s, body: %s", resp.Status, string(body))
-	}
-
-	body, err := io.ReadAll(resp.Body)
+	resp, err := c.do(ctx, http.MethodPost, "/api/chat", req)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read response body: %w", err)
+		return nil, err
 	}
 
 	var chatResp ChatResponse
-	if err := json.Unmarshal(body, &chatResp); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal response: %w", err)
+	if err := readResponse(ctx, resp, &chatResp); err != nil {
+		return nil, err
 	}
 	if chatResp.Error != "" {
-		return nil, fmt.Errorf("API error: %s", chatResp.Error)
+		return nil, streamError(chatResp.Error)
 	}
 	return &chatResp, nil
 }
@@ -216,78 +323,34 @@ func (c *OllamaClient) ChatStream(ctx context.Context, req *ChatRequest, handler
 	}
 	req.Stream = true
 
-	jsonData, err := json.Marshal(req)
+	resp, err := c.do(ctx, http.MethodPost, "/api/chat", req)
 	if err != nil {
-		return fmt.Errorf("failed to marshal request: %w", err)
+		return err
 	}
 
-	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, fmt.Sprintf("%s/api/chat", c.BaseURL), bytes.NewBuffer(jsonData))
-	if err != nil {
-		return fmt.Errorf("failed to create request: %w", err)
-	}
-	httpReq.Header.Set("Content-Type", "application/json")
-
-	resp, err := c.HTTPClient.Do(httpReq)
-	if err != nil {
-		return fmt.Errorf("failed to send request: %w", err)
-	}
-	defer func() { _ = resp.Body.Close() }()
-
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("received non-OK response: %s, body: %s", resp.Status, string(body))
-	}
-
-	decoder := json.NewDecoder(resp.Body)
-	for {
+	return streamResponse(ctx, resp, func(decode func(v interface{}) error) (bool, error) {
 		var chatResp ChatResponse
-		if err := decoder.Decode(&chatResp); err != nil {
-			if ctx.Err() != nil {
-				return ctx.Err()
-			}
-			break
+		if err := decode(&chatResp); err != nil {
+			return false, err
 		}
 		if chatResp.Error != "" {
-			return fmt.Errorf("API error: %s", chatResp.Error)
+			return false, streamError(chatResp.Error)
 		}
 		handler(&chatResp)
-		if chatResp.Done {
-			break
-		}
-	}
-	return nil
+		return chatResp.Done, nil
+	})
 }
 
-// ListModels lists all available models
+// ListModels lists the names of all available models
 func (c *OllamaClient) ListModels(ctx context.Context) ([]string, error) {
-	httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s/api/tags", c.BaseURL), nil)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
-	}
-
-	resp, err := c.HTTPClient.Do(httpReq)
+	models, err := c.ListLocalModels(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("failed to send request: %w", err)
-	}
-	defer func() { _ = resp.Body.Close() }()
-
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("received non-OK response: %s, body: %s", resp.Status, string(body))
-	}
-
-	var result struct {
-		Models []struct {
-			Name string `json:"name"`
-		} `json:"models"`
-	}
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return nil, fmt.Errorf("failed to decode response: %w", err)
+		return nil, err
 	}
 
-	var models []string
-	for _, m := range result.Models {
-		models = append(models, m.Name)
+	names := make([]string, len(models))
+	for i, m := range models {
+		names[i] = m.Name
 	}
-	return models, nil
+	return names, nil
 }
@@ -0,0 +1,151 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fastRetry retries quickly so tests don't wait for the default backoff
+var fastRetry = RetryPolicy{MaxRetries: 2, InitialBackoff: time.Millisecond, MaxBackoff: 4 * time.Millisecond}
+
+func TestRetry(t *testing.T) {
+	tests := []struct {
+		name     string
+		statuses []int // Status of each attempt; later attempts succeed
+		attempts int32
+		wantErr  bool
+	}{
+		{"success", nil, 1, false},
+		{"recovers", []int{http.StatusServiceUnavailable, http.StatusBadGateway}, 3, false},
+		{"too many failures", []int{503, 503, 503, 503}, 3, true},
+		{"not retryable", []int{http.StatusBadRequest}, 1, true},
+		{"missing model", []int{http.StatusNotFound}, 1, true},
+	}
+	for _, tt := range tests {
+		var attempts int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			n := atomic.AddInt32(&attempts, 1)
+			if int(n) <= len(tt.statuses) {
+				w.WriteHeader(tt.statuses[n-1])
+				fmt.Fprint(w, `{"error": "failed"}`)
+				return
+			}
+			fmt.Fprint(w, `{"model": "m", "message": {"role": "assistant", "content": "ok"}, "done": true}`)
+		}))
+
+		client := NewClient(server.URL, "m")
+		client.Retry = fastRetry
+		resp, err := client.Chat(context.Background(), &ChatRequest{Messages: []ChatMessage{{Role: RoleUser, Content: "hi"}}})
+		server.Close()
+
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: error = %v", tt.name, err)
+		}
+		if err == nil && resp.Message.Content != "ok" {
+			t.Errorf("%s: answer = %+v", tt.name, resp.Message)
+		}
+		if attempts != tt.attempts {
+			t.Errorf("%s: %d attempts, want %d", tt.name, attempts, tt.attempts)
+		}
+	}
+}
+
+func TestRetryStopsWhenCanceled(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "m")
+	client.Retry = RetryPolicy{MaxRetries: 5, InitialBackoff: time.Hour}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	_, err := client.Chat(ctx, &ChatRequest{})
+	if !errors.Is(err, ErrCanceled) {
+		t.Errorf("error = %v, want ErrCanceled", err)
+	}
+}
+
+func TestConnectionRefused(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	url := server.URL
+	server.Close()
+
+	client := NewClient(url, "m")
+	client.Retry = RetryPolicy{}
+	if _, err := client.ListModels(context.Background()); !errors.Is(err, ErrConnectionRefused) {
+		t.Errorf("error = %v, want ErrConnectionRefused", err)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	policy := RetryPolicy{InitialBackoff: 100 * time.Millisecond, MaxBackoff: time.Second}
+	tests := []struct {
+		attempt int
+		want    time.Duration
+	}{
+		{1, 100 * time.Millisecond},
+		{2, 200 * time.Millisecond},
+		{4, 800 * time.Millisecond},
+		{5, time.Second},
+		{30, time.Second},
+	}
+	for _, tt := range tests {
+		if got := policy.backoff(tt.attempt); got != tt.want {
+			t.Errorf("backoff(%d) = %s, want %s", tt.attempt, got, tt.want)
+		}
+	}
+	if got := (RetryPolicy{}).backoff(1); got != DefaultRetryPolicy.InitialBackoff {
+		t.Errorf("backoff without an initial delay = %s", got)
+	}
+}
+
+func TestStreamErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		stream string
+		check  func(err error) bool
+	}{
+		{
+			"error inside the stream",
+			`{"message": {"content": "a"}, "done": false}` + "\n" + `{"error": "model crashed"}` + "\n",
+			func(err error) bool {
+				var apiErr *APIError
+				return errors.As(err, &apiErr) && apiErr.StatusCode == 0 && apiErr.Message == "model crashed"
+			},
+		},
+		{
+			"ends before done",
+			`{"message": {"content": "a"}, "done": false}` + "\n",
+			func(err error) bool { return errors.Is(err, ErrIncompleteResponse) && IsRetryable(err) },
+		},
+		{
+			"cut off inside an object",
+			`{"message": {"content": "a"}, "done": false}` + "\n" + `{"message": {"con`,
+			func(err error) bool { return errors.Is(err, ErrIncompleteResponse) },
+		},
+		{
+			"complete",
+			`{"message": {"content": "a"}, "done": false}` + "\n" + `{"done": true}` + "\n",
+			func(err error) bool { return err == nil },
+		},
+	}
+	for _, tt := range tests {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, tt.stream)
+		}))
+		client := NewClient(server.URL, "m")
+		err := client.ChatStream(context.Background(), &ChatRequest{}, func(interface{}) {})
+		server.Close()
+		if !tt.check(err) {
+			t.Errorf("%s: error = %v", tt.name, err)
+		}
+	}
+}
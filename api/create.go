@@ -0,0 +1,72 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// CreateRequest creates a model from an existing model or from uploaded files, with the
+// template, system prompt, parameters and messages of a Modelfile
+type CreateRequest struct {
+	Model      string                 `json:"model"`
+	From       string                 `json:"from,omitempty"`     // Base model name
+	Files      map[string]string      `json:"files,omitempty"`    // File name to blob digest, for models made from local weights
+	Adapters   map[string]string      `json:"adapters,omitempty"` // File name to blob digest of LoRA adapters
+	Template   string                 `json:"template,omitempty"`
+	License    []string               `json:"license,omitempty"`
+	System     string                 `json:"system,omitempty"`
+	Parameters map[string]interface{} `json:"parameters,omitempty"`
+	Messages   []ChatMessage          `json:"messages,omitempty"`
+	Quantize   string                 `json:"quantize,omitempty"` // Quantization type such as "q4_K_M"
+	Stream     bool                   `json:"stream"`
+}
+
+// Create creates a model, reporting progress to handler
+func (c *OllamaClient) Create(ctx context.Context, req *CreateRequest, handler ProgressHandler) error {
+	req.Stream = true
+	resp, err := c.do(ctx, http.MethodPost, "/api/create", req)
+	if err != nil {
+		return err
+	}
+	return streamProgress(ctx, resp, handler)
+}
+
+// BlobExists reports whether the server already has the blob with the given digest
+// ("sha256:<hex>")
+func (c *OllamaClient) BlobExists(ctx context.Context, digest string) (bool, error) {
+	resp, err := c.do(ctx, http.MethodHead, "/api/blobs/"+digest, nil)
+	if err != nil {
+		var apiErr *APIError
+		if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound {
+			return false, nil
+		}
+		return false, err
+	}
+	_ = resp.Body.Close()
+	return true, nil
+}
+
+// CreateBlob uploads size bytes from r as the blob with the given digest. The upload is
+// not retried and only bounded by ctx, since model weights can take long to send.
+func (c *OllamaClient) CreateBlob(ctx context.Context, digest string, r io.Reader, size int64) error {
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, c.BaseURL+"/api/blobs/"+digest, r)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	httpReq.ContentLength = size
+	httpReq.Header.Set("Content-Type", "application/octet-stream")
+
+	resp, err := c.HTTPClient.Do(httpReq)
+	if err != nil {
+		return classifyTransportError(ctx, c.BaseURL, err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+		return newAPIError(resp, body)
+	}
+	return nil
+}
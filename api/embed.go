@@ -0,0 +1,37 @@
+package api
+
+import (
+	"context"
+	"net/http"
+)
+
+// EmbedRequest represents a request to the Ollama API for embeddings
+type EmbedRequest struct {
+	Model     string   `json:"model"`
+	Input     []string `json:"input"`
+	Truncate  *bool    `json:"truncate,omitempty"`
+	Options   *Options `json:"options,omitempty"`
+	KeepAlive string   `json:"keep_alive,omitempty"`
+}
+
+// EmbedResponse represents a response from the Ollama API for embeddings
+type EmbedResponse struct {
+	Model           string      `json:"model"`
+	Embeddings      [][]float32 `json:"embeddings"`
+	TotalDuration   int64       `json:"total_duration,omitempty"`
+	PromptEvalCount int         `json:"prompt_eval_count,omitempty"`
+}
+
+// Embed returns one embedding vector per input text
+func (c *OllamaClient) Embed(ctx context.Context, req *EmbedRequest) (*EmbedResponse, error) {
+	resp, err := c.do(ctx, http.MethodPost, "/api/embed", req)
+	if err != nil {
+		return nil, err
+	}
+
+	var embedResp EmbedResponse
+	if err := readResponse(ctx, resp, &embedResp); err != nil {
+		return nil, err
+	}
+	return &embedResp, nil
+}
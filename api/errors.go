@@ -0,0 +1,131 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Sentinel errors for the failures callers most often need to tell apart
+var (
+	ErrConnectionRefused = errors.New("connection refused")
+	ErrModelNotFound     = errors.New("model not found")
+	ErrCanceled          = errors.New("request canceled")
+	// ErrIncompleteResponse is returned when a stream ends before the server marked it
+	// done, e.g. because the connection was dropped during generation
+	ErrIncompleteResponse = errors.New("the response ended before it was complete")
+)
+
+// APIError is a non-successful response from the Ollama API
+type APIError struct {
+	StatusCode int    // HTTP status code, or 0 for errors reported inside a stream
+	Status     string // HTTP status text
+	Message    string // Error message returned by Ollama
+	Retryable  bool   // Whether repeating the request may succeed
+	RetryAfter time.Duration
+}
+
+// Error implements the error interface
+func (e *APIError) Error() string {
+	if e.StatusCode == 0 {
+		return "ollama API error: " + e.Message
+	}
+	if e.Message == "" {
+		return fmt.Sprintf("ollama API error: %s", e.Status)
+	}
+	return fmt.Sprintf("ollama API error (%d): %s", e.StatusCode, e.Message)
+}
+
+// Is lets errors.Is match an APIError against the sentinel errors
+func (e *APIError) Is(target error) bool {
+	if target == ErrModelNotFound {
+		msg := strings.ToLower(e.Message)
+		return (strings.Contains(msg, "not found") || strings.Contains(msg, "does not exist")) && (e.StatusCode == http.StatusNotFound || strings.Contains(msg, "model"))
+	}
+	return false
+}
+
+// IsRetryable reports whether an error is worth retrying
+func IsRetryable(err error) bool {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.Retryable
+	}
+	var timeoutErr *TimeoutError
+	if errors.As(err, &timeoutErr) {
+		return timeoutErr.Phase == TimeoutConnect
+	}
+	if errors.Is(err, ErrCanceled) {
+		return false
+	}
+	if errors.Is(err, ErrConnectionRefused) || errors.Is(err, ErrIncompleteResponse) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// newAPIError builds an APIError from a non-OK HTTP response body
+func newAPIError(resp *http.Response, body []byte) *APIError {
+	apiErr := &APIError{
+		StatusCode: resp.StatusCode,
+		Status:     resp.Status,
+		Message:    strings.TrimSpace(string(body)),
+	}
+
+	// Ollama reports {"error": "message"}, OpenAI-compatible servers {"error": {"message": ...}}
+	var payload struct {
+		Error json.RawMessage `json:"error"`
+	}
+	if err := json.Unmarshal(body, &payload); err == nil && len(payload.Error) > 0 {
+		var message string
+		var object struct {
+			Message string `json:"message"`
+		}
+		if json.Unmarshal(payload.Error, &message) == nil && message != "" {
+			apiErr.Message = message
+		} else if json.Unmarshal(payload.Error, &object) == nil && object.Message != "" {
+			apiErr.Message = object.Message
+		}
+	}
+
+	switch resp.StatusCode {
+	case http.StatusRequestTimeout, http.StatusTooManyRequests, http.StatusBadGateway,
+		http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		apiErr.Retryable = true
+	}
+
+	if seconds := resp.Header.Get("Retry-After"); seconds != "" {
+		var n int
+		if _, err := fmt.Sscanf(seconds, "%d", &n); err == nil && n > 0 {
+			apiErr.RetryAfter = time.Duration(n) * time.Second
+		}
+	}
+	return apiErr
+}
+
+// streamError builds an APIError from an error reported inside a response body
+func streamError(message string) *APIError {
+	return &APIError{Message: message}
+}
+
+// classifyTransportError maps a failed HTTP round trip onto the sentinel errors
+func classifyTransportError(ctx context.Context, baseURL string, err error) error {
+	if ctx.Err() != nil {
+		return fmt.Errorf("%w: %w", ErrCanceled, ctx.Err())
+	}
+	var timeoutErr *TimeoutError
+	if errors.As(err, &timeoutErr) {
+		return timeoutErr
+	}
+	if errors.Is(err, syscall.ECONNREFUSED) {
+		return fmt.Errorf("could not connect to %s (is Ollama running?): %w", baseURL, ErrConnectionRefused)
+	}
+	return fmt.Errorf("failed to send request: %w", err)
+}
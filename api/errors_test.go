@@ -0,0 +1,98 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestNewAPIError(t *testing.T) {
+	tests := []struct {
+		status     int
+		body       string
+		retryAfter string
+		message    string
+		retryable  bool
+		delay      time.Duration
+	}{
+		{http.StatusNotFound, `{"error": "model \"llama9\" not found, try pulling it first"}`, "", `model "llama9" not found, try pulling it first`, false, 0},
+		{http.StatusBadRequest, `{"error": {"message": "invalid temperature"}}`, "", "invalid temperature", false, 0},
+		{http.StatusInternalServerError, "plain text failure\n", "", "plain text failure", false, 0},
+		{http.StatusRequestTimeout, "", "", "", true, 0},
+		{http.StatusTooManyRequests, `{"error": "busy"}`, "3", "busy", true, 3 * time.Second},
+		{http.StatusBadGateway, "", "", "", true, 0},
+		{http.StatusServiceUnavailable, "", "soon", "", true, 0},
+		{http.StatusGatewayTimeout, "", "", "", true, 0},
+	}
+	for _, tt := range tests {
+		resp := &http.Response{StatusCode: tt.status, Status: http.StatusText(tt.status), Header: http.Header{}}
+		if tt.retryAfter != "" {
+			resp.Header.Set("Retry-After", tt.retryAfter)
+		}
+		apiErr := newAPIError(resp, []byte(tt.body))
+		if apiErr.Message != tt.message || apiErr.Retryable != tt.retryable || apiErr.RetryAfter != tt.delay {
+			t.Errorf("%d %s: got message %q, retryable %v, retry after %s", tt.status, tt.body, apiErr.Message, apiErr.Retryable, apiErr.RetryAfter)
+		}
+	}
+}
+
+func TestAPIErrorIsModelNotFound(t *testing.T) {
+	tests := []struct {
+		err  *APIError
+		want bool
+	}{
+		{&APIError{StatusCode: http.StatusNotFound, Message: `model "x" not found, try pulling it first`}, true},
+		{&APIError{Message: `model "x" not found`}, true}, // Reported inside a stream
+		{&APIError{StatusCode: http.StatusBadRequest, Message: "model does not exist"}, true},
+		{&APIError{StatusCode: http.StatusNotFound, Message: "page not found"}, true},
+		{&APIError{StatusCode: http.StatusBadRequest, Message: "file not found"}, false},
+		{&APIError{StatusCode: http.StatusInternalServerError, Message: "out of memory"}, false},
+	}
+	for _, tt := range tests {
+		if got := errors.Is(tt.err, ErrModelNotFound); got != tt.want {
+			t.Errorf("errors.Is(%v, ErrModelNotFound) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestAPIErrorMessage(t *testing.T) {
+	tests := []struct {
+		err  *APIError
+		want string
+	}{
+		{&APIError{Message: "boom"}, "ollama API error: boom"},
+		{&APIError{StatusCode: 502, Status: "502 Bad Gateway"}, "ollama API error: 502 Bad Gateway"},
+		{&APIError{StatusCode: 400, Status: "400 Bad Request", Message: "invalid"}, "ollama API error (400): invalid"},
+	}
+	for _, tt := range tests {
+		if got := tt.err.Error(); got != tt.want {
+			t.Errorf("Error() = %q, want %q", got, tt.want)
+		}
+	}
+}
+
+func TestIsRetryable(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"retryable API error", &APIError{StatusCode: 503, Retryable: true}, true},
+		{"other API error", &APIError{StatusCode: 400}, false},
+		{"wrapped API error", fmt.Errorf("chat: %w", &APIError{StatusCode: 429, Retryable: true}), true},
+		{"connection refused", fmt.Errorf("could not connect: %w", ErrConnectionRefused), true},
+		{"canceled", fmt.Errorf("%w: %w", ErrCanceled, context.Canceled), false},
+		{"connect timeout", &TimeoutError{Phase: TimeoutConnect, Limit: time.Second}, true},
+		{"first-token timeout", &TimeoutError{Phase: TimeoutFirstToken, Limit: time.Second}, false},
+		{"idle timeout", &TimeoutError{Phase: TimeoutIdle, Limit: time.Second}, false},
+		{"other", errors.New("failed to decode response"), false},
+	}
+	for _, tt := range tests {
+		if got := IsRetryable(tt.err); got != tt.want {
+			t.Errorf("%s: IsRetryable = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package api
+
+// PromptFilter rewrites text before it leaves the process, e.g. to mask secrets
+type PromptFilter func(text string) string
+
+// filterPayload returns a copy of a request with the client's filter applied to every
+// prompt, message, tool call argument, embedding input and text of a model being created;
+// other payloads are unchanged
+func (c *baseClient) filterPayload(payload interface{}) interface{} {
+	if c.Filter == nil {
+		return payload
+	}
+
+	switch req := payload.(type) {
+	case *ChatRequest:
+		filtered := *req
+		filtered.Messages = c.filterMessages(req.Messages)
+		return &filtered
+
+	case *GenerateRequest:
+		filtered := *req
+		filtered.Prompt = c.Filter(req.Prompt)
+		filtered.System = c.Filter(req.System)
+		return &filtered
+
+	case *EmbedRequest:
+		filtered := *req
+		filtered.Input = make([]string, len(req.Input))
+		for i, text := range req.Input {
+			filtered.Input[i] = c.Filter(text)
+		}
+		return &filtered
+
+	case *CreateRequest:
+		filtered := *req
+		filtered.System = c.Filter(req.System)
+		filtered.Template = c.Filter(req.Template)
+		if req.License != nil {
+			filtered.License = make([]string, len(req.License))
+			for i, text := range req.License {
+				filtered.License[i] = c.Filter(text)
+			}
+		}
+		filtered.Messages = c.filterMessages(req.Messages)
+		return &filtered
+	}
+	return payload
+}
+
+// filterMessages applies the filter to the content and tool call arguments of messages
+func (c *baseClient) filterMessages(messages []ChatMessage) []ChatMessage {
+	if messages == nil {
+		return nil
+	}
+	filtered := make([]ChatMessage, len(messages))
+	for i, msg := range messages {
+		msg.Content = c.Filter(msg.Content)
+		if len(msg.ToolCalls) > 0 {
+			calls := make([]ToolCall, len(msg.ToolCalls))
+			for j, call := range msg.ToolCalls {
+				call.Function.Arguments = c.filterArguments(call.Function.Arguments)
+				calls[j] = call
+			}
+			msg.ToolCalls = calls
+		}
+		filtered[i] = msg
+	}
+	return filtered
+}
+
+// filterArguments applies the filter to the string values of tool call arguments
+func (c *baseClient) filterArguments(args ToolCallArguments) ToolCallArguments {
+	if args == nil {
+		return nil
+	}
+	filtered := make(ToolCallArguments, len(args))
+	for key, value := range args {
+		if s, ok := value.(string); ok {
+			value = c.Filter(s)
+		}
+		filtered[key] = value
+	}
+	return filtered
+}
@@ -0,0 +1,51 @@
+package api
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFilterPayload(t *testing.T) {
+	c := &baseClient{Filter: func(text string) string { return strings.ReplaceAll(text, "hunter2", "MASKED") }}
+	call := ToolCall{Function: ToolCallFunction{Name: "write_file", Arguments: ToolCallArguments{"content": "pw hunter2", "line": 3}}}
+	messages := []ChatMessage{{Role: RoleUser, Content: "my password is hunter2"}, {Role: RoleAssistant, ToolCalls: []ToolCall{call}}}
+
+	chat := c.filterPayload(&ChatRequest{Model: "m", Messages: messages}).(*ChatRequest)
+	if chat.Messages[0].Content != "my password is MASKED" || chat.Messages[1].ToolCalls[0].Function.Arguments.String("content") != "pw MASKED" {
+		t.Errorf("chat request not filtered: %+v", chat.Messages)
+	}
+	if chat.Messages[1].ToolCalls[0].Function.Arguments["line"] != 3 {
+		t.Errorf("non-string argument changed: %v", chat.Messages[1].ToolCalls[0].Function.Arguments)
+	}
+
+	generate := c.filterPayload(&GenerateRequest{Prompt: "hunter2", System: "s hunter2"}).(*GenerateRequest)
+	if generate.Prompt != "MASKED" || generate.System != "s MASKED" {
+		t.Errorf("generate request not filtered: %+v", generate)
+	}
+
+	embed := c.filterPayload(&EmbedRequest{Input: []string{"a hunter2", "b"}}).(*EmbedRequest)
+	if !reflect.DeepEqual(embed.Input, []string{"a MASKED", "b"}) {
+		t.Errorf("embed request not filtered: %v", embed.Input)
+	}
+
+	create := c.filterPayload(&CreateRequest{
+		Model:    "m",
+		From:     "llama3",
+		System:   "The password is hunter2",
+		Template: "{{ .Prompt }} hunter2",
+		License:  []string{"hunter2"},
+		Messages: messages,
+	}).(*CreateRequest)
+	if create.System != "The password is MASKED" || create.Template != "{{ .Prompt }} MASKED" || create.License[0] != "MASKED" {
+		t.Errorf("create request not filtered: %+v", create)
+	}
+	if create.From != "llama3" || create.Messages[0].Content != "my password is MASKED" {
+		t.Errorf("create request messages not filtered: %+v", create)
+	}
+
+	// The caller's request is left alone
+	if messages[0].Content != "my password is hunter2" || call.Function.Arguments.String("content") != "pw hunter2" {
+		t.Error("the original request was modified")
+	}
+}
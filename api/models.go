@@ -0,0 +1,193 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ModelDetails describes the format and size of a model
+type ModelDetails struct {
+	ParentModel       string   `json:"parent_model,omitempty"`
+	Format            string   `json:"format,omitempty"`
+	Family            string   `json:"family,omitempty"`
+	Families          []string `json:"families,omitempty"`
+	ParameterSize     string   `json:"parameter_size,omitempty"`
+	QuantizationLevel string   `json:"quantization_level,omitempty"`
+}
+
+// Model is a model available on the server
+type Model struct {
+	Name       string       `json:"name"`
+	Model      string       `json:"model"`
+	ModifiedAt time.Time    `json:"modified_at"`
+	Size       int64        `json:"size"`
+	Digest     string       `json:"digest"`
+	Details    ModelDetails `json:"details"`
+}
+
+// RunningModel is a model loaded into memory
+type RunningModel struct {
+	Name          string       `json:"name"`
+	Model         string       `json:"model"`
+	Size          int64        `json:"size"`
+	SizeVRAM      int64        `json:"size_vram"`
+	Digest        string       `json:"digest"`
+	Details       ModelDetails `json:"details"`
+	ExpiresAt     time.Time    `json:"expires_at"`
+	ContextLength int          `json:"context_length,omitempty"`
+}
+
+// ShowResponse describes a model in detail
+type ShowResponse struct {
+	License      string                 `json:"license,omitempty"`
+	Modelfile    string                 `json:"modelfile,omitempty"`
+	Parameters   string                 `json:"parameters,omitempty"`
+	Template     string                 `json:"template,omitempty"`
+	System       string                 `json:"system,omitempty"`
+	Details      ModelDetails           `json:"details"`
+	ModelInfo    map[string]interface{} `json:"model_info,omitempty"`
+	Capabilities []string               `json:"capabilities,omitempty"`
+	ModifiedAt   time.Time              `json:"modified_at"`
+}
+
+// HasModel reports whether a model is among the names listed by a server; a name without
+// a tag matches the "latest" tag
+func HasModel(names []string, model string) bool {
+	for _, name := range names {
+		if name == model || (!strings.Contains(model, ":") && name == model+":latest") {
+			return true
+		}
+	}
+	return false
+}
+
+// ContextLength returns the context window the model was trained with, from the
+// "<architecture>.context_length" entry of its model info, or 0 if it is not known
+func (s *ShowResponse) ContextLength() int {
+	for key, value := range s.ModelInfo {
+		if n, ok := value.(float64); ok && strings.HasSuffix(key, ".context_length") {
+			return int(n)
+		}
+	}
+	return 0
+}
+
+// ProgressResponse is a progress update of a pull, push or create
+type ProgressResponse struct {
+	Status    string `json:"status"`
+	Digest    string `json:"digest,omitempty"`
+	Total     int64  `json:"total,omitempty"`
+	Completed int64  `json:"completed,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// ProgressHandler receives progress updates
+type ProgressHandler func(progress ProgressResponse)
+
+// ListLocalModels lists the models available on the server with their details
+func (c *OllamaClient) ListLocalModels(ctx context.Context) ([]Model, error) {
+	resp, err := c.do(ctx, http.MethodGet, "/api/tags", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var result struct {
+		Models []Model `json:"models"`
+	}
+	if err := readResponse(ctx, resp, &result); err != nil {
+		return nil, err
+	}
+	return result.Models, nil
+}
+
+// ListRunning lists the models currently loaded into memory
+func (c *OllamaClient) ListRunning(ctx context.Context) ([]RunningModel, error) {
+	resp, err := c.do(ctx, http.MethodGet, "/api/ps", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var result struct {
+		Models []RunningModel `json:"models"`
+	}
+	if err := readResponse(ctx, resp, &result); err != nil {
+		return nil, err
+	}
+	return result.Models, nil
+}
+
+// Version returns the version of the Ollama server
+func (c *OllamaClient) Version(ctx context.Context) (string, error) {
+	resp, err := c.do(ctx, http.MethodGet, "/api/version", nil)
+	if err != nil {
+		return "", err
+	}
+
+	var result struct {
+		Version string `json:"version"`
+	}
+	if err := readResponse(ctx, resp, &result); err != nil {
+		return "", err
+	}
+	return result.Version, nil
+}
+
+// Show returns the details, parameters, template and capabilities of a model
+func (c *OllamaClient) Show(ctx context.Context, model string) (*ShowResponse, error) {
+	resp, err := c.do(ctx, http.MethodPost, "/api/show", map[string]string{"model": model})
+	if err != nil {
+		return nil, err
+	}
+
+	var showResp ShowResponse
+	if err := readResponse(ctx, resp, &showResp); err != nil {
+		return nil, err
+	}
+	return &showResp, nil
+}
+
+// Pull downloads a model from the registry, reporting progress to handler
+func (c *OllamaClient) Pull(ctx context.Context, model string, handler ProgressHandler) error {
+	resp, err := c.do(ctx, http.MethodPost, "/api/pull", map[string]interface{}{"model": model, "stream": true})
+	if err != nil {
+		return err
+	}
+	return streamProgress(ctx, resp, handler)
+}
+
+// Delete removes a model from the server
+func (c *OllamaClient) Delete(ctx context.Context, model string) error {
+	resp, err := c.do(ctx, http.MethodDelete, "/api/delete", map[string]string{"model": model})
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+// Copy creates a model under a new name from an existing one
+func (c *OllamaClient) Copy(ctx context.Context, source string, destination string) error {
+	resp, err := c.do(ctx, http.MethodPost, "/api/copy", map[string]string{"source": source, "destination": destination})
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+// streamProgress reads progress updates until the stream ends, failing on an error update
+func streamProgress(ctx context.Context, resp *http.Response, handler ProgressHandler) error {
+	return streamResponse(ctx, resp, func(decode func(v interface{}) error) (bool, error) {
+		var progress ProgressResponse
+		if err := decode(&progress); err != nil {
+			return false, err
+		}
+		if progress.Error != "" {
+			return false, streamError(progress.Error)
+		}
+		if handler != nil {
+			handler(progress)
+		}
+		return progress.Status == "success", nil
+	})
+}
@@ -0,0 +1,129 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestPullProgress(t *testing.T) {
+	tests := []struct {
+		name    string
+		stream  string
+		updates []ProgressResponse
+		wantErr string
+	}{
+		{
+			"success",
+			`{"status": "pulling manifest"}
+{"status": "pulling abc", "digest": "sha256:abc", "total": 100, "completed": 40}
+{"status": "pulling abc", "digest": "sha256:abc", "total": 100, "completed": 100}
+{"status": "success"}
+`,
+			[]ProgressResponse{
+				{Status: "pulling manifest"},
+				{Status: "pulling abc", Digest: "sha256:abc", Total: 100, Completed: 40},
+				{Status: "pulling abc", Digest: "sha256:abc", Total: 100, Completed: 100},
+				{Status: "success"},
+			},
+			"",
+		},
+		{
+			"error update",
+			`{"status": "pulling manifest"}
+{"error": "pull model manifest: file does not exist"}
+`,
+			[]ProgressResponse{{Status: "pulling manifest"}},
+			"ollama API error: pull model manifest: file does not exist",
+		},
+	}
+	for _, tt := range tests {
+		var request map[string]interface{}
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/api/pull" {
+				t.Errorf("path = %s", r.URL.Path)
+			}
+			_ = json.NewDecoder(r.Body).Decode(&request)
+			fmt.Fprint(w, tt.stream)
+		}))
+		client := NewClient(server.URL, "m")
+
+		var updates []ProgressResponse
+		err := client.Pull(context.Background(), "llama3.2", func(p ProgressResponse) { updates = append(updates, p) })
+		server.Close()
+
+		if tt.wantErr == "" && err != nil || tt.wantErr != "" && (err == nil || err.Error() != tt.wantErr) {
+			t.Errorf("%s: error = %v, want %q", tt.name, err, tt.wantErr)
+		}
+		if !reflect.DeepEqual(updates, tt.updates) {
+			t.Errorf("%s: updates = %+v", tt.name, updates)
+		}
+		if request["model"] != "llama3.2" || request["stream"] != true {
+			t.Errorf("%s: request = %v", tt.name, request)
+		}
+	}
+}
+
+func TestModelRequests(t *testing.T) {
+	type call struct {
+		method, path string
+		body         map[string]interface{}
+	}
+	var calls []call
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		calls = append(calls, call{r.Method, r.URL.Path, body})
+		switch r.URL.Path {
+		case "/api/show":
+			fmt.Fprint(w, `{"details": {"family": "llama"}, "model_info": {"general.architecture": "llama", "llama.context_length": 131072}, "capabilities": ["completion", "tools"]}`)
+		case "/api/ps":
+			fmt.Fprint(w, `{"models": [{"name": "llama3.2:latest", "size_vram": 2048}]}`)
+		}
+	}))
+	defer server.Close()
+	client := NewClient(server.URL, "m")
+	ctx := context.Background()
+
+	show, err := client.Show(ctx, "llama3.2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if show.ContextLength() != 131072 || !reflect.DeepEqual(show.Capabilities, []string{"completion", "tools"}) {
+		t.Errorf("Show = %+v", show)
+	}
+	running, err := client.ListRunning(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(running) != 1 || running[0].Name != "llama3.2:latest" || running[0].SizeVRAM != 2048 {
+		t.Errorf("ListRunning = %+v", running)
+	}
+	if err := client.Copy(ctx, "llama3.2", "backup"); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Delete(ctx, "backup"); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []call{
+		{http.MethodPost, "/api/show", map[string]interface{}{"model": "llama3.2"}},
+		{http.MethodGet, "/api/ps", nil},
+		{http.MethodPost, "/api/copy", map[string]interface{}{"source": "llama3.2", "destination": "backup"}},
+		{http.MethodDelete, "/api/delete", map[string]interface{}{"model": "backup"}},
+	}
+	if !reflect.DeepEqual(calls, want) {
+		t.Errorf("calls:\n got %+v\nwant %+v", calls, want)
+	}
+}
+
+func TestShowContextLengthUnknown(t *testing.T) {
+	show := &ShowResponse{ModelInfo: map[string]interface{}{"general.architecture": "bert"}}
+	if n := show.ContextLength(); n != 0 {
+		t.Errorf("ContextLength = %d, want 0", n)
+	}
+}
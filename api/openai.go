@@ -0,0 +1,354 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// OpenAIClient is a client for servers implementing the OpenAI chat completions API, such
+// as the llama.cpp server, vLLM and LM Studio. BaseURL includes the version prefix, e.g.
+// "http://localhost:8080/v1".
+type OpenAIClient struct {
+	baseClient
+	DefaultModel string
+}
+
+// NewOpenAIClient creates an OpenAIClient; apiKey is sent as a bearer token if it is set
+func NewOpenAIClient(baseURL string, apiKey string, defaultModel string) *OpenAIClient {
+	c := &OpenAIClient{DefaultModel: defaultModel}
+	c.init(strings.TrimRight(baseURL, "/"))
+	if apiKey != "" {
+		c.Header = http.Header{"Authorization": []string{"Bearer " + apiKey}}
+	}
+	return c
+}
+
+// openAIChatRequest is the body of POST /chat/completions. Options without an equivalent,
+// such as num_ctx, are set when the server is started and are not sent.
+type openAIChatRequest struct {
+	Model            string                `json:"model"`
+	Messages         []openAIMessage       `json:"messages"`
+	Tools            []Tool                `json:"tools,omitempty"`
+	Stream           bool                  `json:"stream"`
+	Temperature      *float64              `json:"temperature,omitempty"`
+	TopP             float64               `json:"top_p,omitempty"`
+	TopK             int                   `json:"top_k,omitempty"` // Not in the OpenAI API, but accepted by llama.cpp and vLLM
+	MinP             float64               `json:"min_p,omitempty"`
+	MaxTokens        int                   `json:"max_tokens,omitempty"`
+	Stop             []string              `json:"stop,omitempty"`
+	Seed             *int                  `json:"seed,omitempty"`
+	PresencePenalty  float64               `json:"presence_penalty,omitempty"`
+	FrequencyPenalty float64               `json:"frequency_penalty,omitempty"`
+	ResponseFormat   *openAIResponseFormat `json:"response_format,omitempty"`
+}
+
+type openAIMessage struct {
+	Role       string           `json:"role,omitempty"`
+	Content    string           `json:"content"`
+	ToolCalls  []openAIToolCall `json:"tool_calls,omitempty"`
+	ToolCallID string           `json:"tool_call_id,omitempty"`
+}
+
+type openAIToolCall struct {
+	Index    int    `json:"index,omitempty"` // Position of a call in a response, used to assemble streamed calls
+	ID       string `json:"id,omitempty"`
+	Type     string `json:"type,omitempty"`
+	Function struct {
+		Name      string `json:"name,omitempty"`
+		Arguments string `json:"arguments"` // JSON-encoded; streamed in pieces
+	} `json:"function"`
+}
+
+type openAIResponseFormat struct {
+	Type       string      `json:"type"`
+	JSONSchema interface{} `json:"json_schema,omitempty"`
+}
+
+type openAIChatResponse struct {
+	Model   string `json:"model"`
+	Choices []struct {
+		Message      openAIMessage `json:"message"`
+		Delta        openAIMessage `json:"delta"`
+		FinishReason string        `json:"finish_reason"`
+	} `json:"choices"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error,omitempty"`
+}
+
+// newOpenAIChatRequest translates a chat request, applying the prompt filter first
+func (c *OpenAIClient) newOpenAIChatRequest(req *ChatRequest, stream bool) *openAIChatRequest {
+	if req.Model == "" {
+		req.Model = c.DefaultModel
+	}
+	filtered := c.filterPayload(req).(*ChatRequest)
+
+	out := &openAIChatRequest{
+		Model:    filtered.Model,
+		Messages: openAIMessages(filtered.Messages),
+		Tools:    filtered.Tools,
+		Stream:   stream,
+	}
+	if o := filtered.Options; o != nil {
+		out.Temperature = o.Temperature
+		out.TopP = o.TopP
+		out.TopK = o.TopK
+		out.MinP = o.MinP
+		if o.NumPredict > 0 {
+			out.MaxTokens = o.NumPredict
+		}
+		out.Stop = o.Stop
+		out.Seed = o.Seed
+		out.PresencePenalty = o.PresencePenalty
+		out.FrequencyPenalty = o.FrequencyPenalty
+	}
+	switch format := filtered.Format.(type) {
+	case nil:
+	case string:
+		if format == "json" {
+			out.ResponseFormat = &openAIResponseFormat{Type: "json_object"}
+		}
+	default:
+		out.ResponseFormat = &openAIResponseFormat{
+			Type:       "json_schema",
+			JSONSchema: map[string]interface{}{"name": "response", "schema": format},
+		}
+	}
+	return out
+}
+
+// openAIMessages translates chat messages. Tool results name the tool rather than the
+// call, so they are matched to the calls of the preceding assistant message in order, and
+// calls without an ID are given one.
+func openAIMessages(messages []ChatMessage) []openAIMessage {
+	type pendingCall struct{ id, name string }
+	var pending []pendingCall
+
+	out := make([]openAIMessage, 0, len(messages))
+	for i, msg := range messages {
+		m := openAIMessage{Role: msg.Role, Content: msg.Content}
+		if len(msg.ToolCalls) > 0 {
+			pending = pending[:0]
+			for j, call := range msg.ToolCalls {
+				id := call.ID
+				if id == "" {
+					id = fmt.Sprintf("call_%d_%d", i, j)
+				}
+				args := call.Function.Arguments
+				if args == nil {
+					args = ToolCallArguments{}
+				}
+				encoded, _ := json.Marshal(args)
+
+				tc := openAIToolCall{ID: id, Type: "function"}
+				tc.Function.Name = call.Function.Name
+				tc.Function.Arguments = string(encoded)
+				m.ToolCalls = append(m.ToolCalls, tc)
+				pending = append(pending, pendingCall{id: id, name: call.Function.Name})
+			}
+		}
+		if msg.Role == RoleTool {
+			for k, call := range pending {
+				if msg.ToolName == "" || call.name == msg.ToolName {
+					m.ToolCallID = call.id
+					pending = append(pending[:k], pending[k+1:]...)
+					break
+				}
+			}
+		}
+		out = append(out, m)
+	}
+	return out
+}
+
+// toolCalls translates tool calls from a response, in the order of their index
+func toolCalls(calls []openAIToolCall) []ToolCall {
+	sort.SliceStable(calls, func(i, j int) bool { return calls[i].Index < calls[j].Index })
+
+	var out []ToolCall
+	for _, call := range calls {
+		args := ToolCallArguments{}
+		if strings.TrimSpace(call.Function.Arguments) != "" {
+			_ = json.Unmarshal([]byte(call.Function.Arguments), &args)
+		}
+		out = append(out, ToolCall{
+			ID:       call.ID,
+			Function: ToolCallFunction{Index: call.Index, Name: call.Function.Name, Arguments: args},
+		})
+	}
+	return out
+}
+
+// Chat sends a chat request to the chat completions endpoint
+func (c *OpenAIClient) Chat(ctx context.Context, req *ChatRequest) (*ChatResponse, error) {
+	resp, err := c.do(ctx, http.MethodPost, "/chat/completions", c.newOpenAIChatRequest(req, false))
+	if err != nil {
+		return nil, err
+	}
+
+	var chatResp openAIChatResponse
+	if err := readResponse(ctx, resp, &chatResp); err != nil {
+		return nil, err
+	}
+	if chatResp.Error != nil {
+		return nil, streamError(chatResp.Error.Message)
+	}
+	if len(chatResp.Choices) == 0 {
+		return nil, errors.New("the server returned no answer")
+	}
+	choice := chatResp.Choices[0]
+	return &ChatResponse{
+		Model: chatResp.Model,
+		Message: ChatMessage{
+			Role:      RoleAssistant,
+			Content:   choice.Message.Content,
+			ToolCalls: toolCalls(choice.Message.ToolCalls),
+		},
+		Done:       true,
+		DoneReason: choice.FinishReason,
+	}, nil
+}
+
+// ChatStream sends a chat request to the chat completions endpoint and streams the
+// server-sent events. Tool calls arrive in pieces and are passed on, complete, with the
+// final response.
+func (c *OpenAIClient) ChatStream(ctx context.Context, req *ChatRequest, handler StreamHandler) error {
+	resp, err := c.do(ctx, http.MethodPost, "/chat/completions", c.newOpenAIChatRequest(req, true))
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	var model, doneReason string
+	finished := false
+	calls := make(map[int]*openAIToolCall)
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data:")
+		if !ok {
+			// Blank separators, comments and event names
+			continue
+		}
+		data = strings.TrimSpace(data)
+		if data == "[DONE]" {
+			finished = true
+			break
+		}
+
+		var chunk openAIChatResponse
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return fmt.Errorf("failed to decode stream: %w", err)
+		}
+		if chunk.Error != nil {
+			return streamError(chunk.Error.Message)
+		}
+		if chunk.Model != "" {
+			model = chunk.Model
+		}
+		for _, choice := range chunk.Choices {
+			if choice.FinishReason != "" {
+				doneReason = choice.FinishReason
+			}
+			for _, delta := range choice.Delta.ToolCalls {
+				call, ok := calls[delta.Index]
+				if !ok {
+					call = &openAIToolCall{Index: delta.Index}
+					calls[delta.Index] = call
+				}
+				if delta.ID != "" {
+					call.ID = delta.ID
+				}
+				call.Function.Name += delta.Function.Name
+				call.Function.Arguments += delta.Function.Arguments
+			}
+			if choice.Delta.Content != "" {
+				handler(&ChatResponse{Model: model, Message: ChatMessage{Role: RoleAssistant, Content: choice.Delta.Content}})
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		if ctx.Err() != nil {
+			return fmt.Errorf("%w: %w", ErrCanceled, ctx.Err())
+		}
+		if errors.Is(err, ErrTimeout) {
+			return err
+		}
+		return fmt.Errorf("failed to read stream: %w", err)
+	}
+	// Some servers close the stream after the last choice without sending [DONE]
+	if !finished && doneReason == "" {
+		return fmt.Errorf("%w: %w", ErrIncompleteResponse, io.ErrUnexpectedEOF)
+	}
+
+	streamed := make([]openAIToolCall, 0, len(calls))
+	for _, call := range calls {
+		streamed = append(streamed, *call)
+	}
+	handler(&ChatResponse{
+		Model:      model,
+		Message:    ChatMessage{Role: RoleAssistant, ToolCalls: toolCalls(streamed)},
+		Done:       true,
+		DoneReason: doneReason,
+	})
+	return nil
+}
+
+// ListModels lists the names of the models the server offers
+func (c *OpenAIClient) ListModels(ctx context.Context) ([]string, error) {
+	resp, err := c.do(ctx, http.MethodGet, "/models", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var result struct {
+		Data []struct {
+			ID string `json:"id"`
+		} `json:"data"`
+	}
+	if err := readResponse(ctx, resp, &result); err != nil {
+		return nil, err
+	}
+	names := make([]string, len(result.Data))
+	for i, m := range result.Data {
+		names[i] = m.ID
+	}
+	return names, nil
+}
+
+// Embed returns one embedding vector per input text from the embeddings endpoint
+func (c *OpenAIClient) Embed(ctx context.Context, req *EmbedRequest) (*EmbedResponse, error) {
+	filtered := c.filterPayload(req).(*EmbedRequest)
+	resp, err := c.do(ctx, http.MethodPost, "/embeddings", map[string]interface{}{
+		"model": filtered.Model,
+		"input": filtered.Input,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var result struct {
+		Model string `json:"model"`
+		Data  []struct {
+			Index     int       `json:"index"`
+			Embedding []float32 `json:"embedding"`
+		} `json:"data"`
+	}
+	if err := readResponse(ctx, resp, &result); err != nil {
+		return nil, err
+	}
+	embeddings := make([][]float32, len(filtered.Input))
+	for _, d := range result.Data {
+		if d.Index >= 0 && d.Index < len(embeddings) {
+			embeddings[d.Index] = d.Embedding
+		}
+	}
+	return &EmbedResponse{Model: result.Model, Embeddings: embeddings}, nil
+}
@@ -0,0 +1,205 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestOpenAIMessagesMapsToolCallIDs(t *testing.T) {
+	messages := []ChatMessage{
+		{Role: RoleUser, Content: "Look at both files"},
+		{Role: RoleAssistant, ToolCalls: []ToolCall{
+			{ID: "call_a", Function: ToolCallFunction{Name: "read_file", Arguments: ToolCallArguments{"path": "a.go"}}},
+			{Function: ToolCallFunction{Name: "read_file", Arguments: ToolCallArguments{"path": "b.go"}}},
+			{Function: ToolCallFunction{Name: "list_dir"}},
+		}},
+		NewToolResultMessage("list_dir", "a.go b.go"),
+		NewToolResultMessage("read_file", "package a"),
+		NewToolResultMessage("read_file", "package b"),
+	}
+	out := openAIMessages(messages)
+
+	ids := []string{out[2].ToolCallID, out[3].ToolCallID, out[4].ToolCallID}
+	if want := []string{"call_1_2", "call_a", "call_1_1"}; !reflect.DeepEqual(ids, want) {
+		t.Errorf("tool result IDs = %v, want %v", ids, want)
+	}
+	calls := out[1].ToolCalls
+	if len(calls) != 3 || calls[1].ID != "call_1_1" || calls[1].Type != "function" || calls[1].Function.Arguments != `{"path":"b.go"}` {
+		t.Errorf("tool calls = %+v", calls)
+	}
+	if calls[2].Function.Arguments != "{}" {
+		t.Errorf("arguments of a call without any = %q", calls[2].Function.Arguments)
+	}
+}
+
+func TestNewOpenAIChatRequest(t *testing.T) {
+	temperature := 0.2
+	client := NewOpenAIClient("http://localhost:8080/v1/", "", "default")
+	tests := []struct {
+		name string
+		req  *ChatRequest
+		want func(r *openAIChatRequest) bool
+	}{
+		{
+			"default model and options",
+			&ChatRequest{Options: &Options{Temperature: &temperature, NumPredict: 100, NumCtx: 8192, Stop: []string{"END"}}},
+			func(r *openAIChatRequest) bool {
+				return r.Model == "default" && r.Temperature == &temperature && r.MaxTokens == 100 && reflect.DeepEqual(r.Stop, []string{"END"})
+			},
+		},
+		{
+			"json format",
+			&ChatRequest{Model: "m", Format: "json"},
+			func(r *openAIChatRequest) bool {
+				return r.Model == "m" && r.ResponseFormat != nil && r.ResponseFormat.Type == "json_object"
+			},
+		},
+		{
+			"schema format",
+			&ChatRequest{Format: map[string]interface{}{"type": "object"}},
+			func(r *openAIChatRequest) bool {
+				return r.ResponseFormat != nil && r.ResponseFormat.Type == "json_schema" && r.ResponseFormat.JSONSchema != nil
+			},
+		},
+	}
+	for _, tt := range tests {
+		if r := client.newOpenAIChatRequest(tt.req, true); !tt.want(r) || !r.Stream {
+			t.Errorf("%s: request = %+v", tt.name, r)
+		}
+	}
+	if client.BaseURL != "http://localhost:8080/v1" {
+		t.Errorf("BaseURL = %q", client.BaseURL)
+	}
+}
+
+// sseServer serves events as a chat completions stream and records the request
+func sseServer(t *testing.T, events []string, request *openAIChatRequest) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/chat/completions" {
+			t.Errorf("path = %s", r.URL.Path)
+		}
+		if got := r.Header.Get("Authorization"); got != "Bearer secret-key" {
+			t.Errorf("Authorization = %q", got)
+		}
+		if request != nil {
+			_ = json.NewDecoder(r.Body).Decode(request)
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, event := range events {
+			fmt.Fprint(w, event+"\n\n")
+		}
+	}))
+}
+
+func TestOpenAIChatStream(t *testing.T) {
+	events := []string{
+		": keep-alive comment",
+		`data: {"model": "qwen", "choices": [{"delta": {"role": "assistant", "content": "Hel"}}]}`,
+		`data: {"choices": [{"delta": {"content": "lo"}}]}`,
+		`data: {"choices": [{"delta": {"tool_calls": [{"index": 1, "id": "call_2", "type": "function", "function": {"name": "grep", "arguments": "{\"pat"}}]}}]}`,
+		`data: {"choices": [{"delta": {"tool_calls": [{"index": 0, "id": "call_1", "type": "function", "function": {"name": "read_file", "arguments": ""}}]}}]}`,
+		`data: {"choices": [{"delta": {"tool_calls": [{"index": 1, "function": {"arguments": "tern\": \"x\"}"}}]}}]}`,
+		`data: {"choices": [{"delta": {"tool_calls": [{"index": 0, "function": {"arguments": "{\"path\": \"a.go\"}"}}]}}]}`,
+		`data: {"choices": [{"delta": {}, "finish_reason": "tool_calls"}]}`,
+		`data: [DONE]`,
+	}
+	var request openAIChatRequest
+	server := sseServer(t, events, &request)
+	defer server.Close()
+
+	client := NewOpenAIClient(server.URL+"/v1", "secret-key", "qwen")
+	var responses []*ChatResponse
+	err := client.ChatStream(context.Background(), &ChatRequest{Messages: []ChatMessage{{Role: RoleUser, Content: "hi"}}}, func(resp interface{}) {
+		responses = append(responses, resp.(*ChatResponse))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !request.Stream || request.Model != "qwen" || len(request.Messages) != 1 {
+		t.Errorf("request = %+v", request)
+	}
+
+	var answer ChatMessage
+	for _, resp := range responses {
+		answer.Append(resp.Message)
+	}
+	last := responses[len(responses)-1]
+	if !last.Done || last.DoneReason != "tool_calls" || last.Model != "qwen" {
+		t.Errorf("last response = %+v", last)
+	}
+	if answer.Content != "Hello" {
+		t.Errorf("content = %q", answer.Content)
+	}
+	want := []ToolCall{
+		{ID: "call_1", Function: ToolCallFunction{Index: 0, Name: "read_file", Arguments: ToolCallArguments{"path": "a.go"}}},
+		{ID: "call_2", Function: ToolCallFunction{Index: 1, Name: "grep", Arguments: ToolCallArguments{"pattern": "x"}}},
+	}
+	if !reflect.DeepEqual(answer.ToolCalls, want) {
+		t.Errorf("tool calls:\n got %+v\nwant %+v", answer.ToolCalls, want)
+	}
+}
+
+func TestOpenAIChatStreamEnd(t *testing.T) {
+	tests := []struct {
+		name   string
+		events []string
+		check  func(err error) bool
+	}{
+		{
+			"finish reason without [DONE]",
+			[]string{`data: {"choices": [{"delta": {"content": "a"}, "finish_reason": "stop"}]}`},
+			func(err error) bool { return err == nil },
+		},
+		{
+			"error event",
+			[]string{`data: {"error": {"message": "context length exceeded"}}`},
+			func(err error) bool {
+				var apiErr *APIError
+				return errors.As(err, &apiErr) && apiErr.Message == "context length exceeded"
+			},
+		},
+		{
+			"cut off",
+			[]string{`data: {"choices": [{"delta": {"content": "a"}}]}`},
+			func(err error) bool { return errors.Is(err, ErrIncompleteResponse) },
+		},
+		{
+			"invalid event",
+			[]string{`data: {not json`},
+			func(err error) bool { return err != nil && !errors.Is(err, ErrIncompleteResponse) },
+		},
+	}
+	for _, tt := range tests {
+		server := sseServer(t, tt.events, nil)
+		client := NewOpenAIClient(server.URL+"/v1", "secret-key", "m")
+		err := client.ChatStream(context.Background(), &ChatRequest{}, func(interface{}) {})
+		server.Close()
+		if !tt.check(err) {
+			t.Errorf("%s: error = %v", tt.name, err)
+		}
+	}
+}
+
+func TestOpenAIChat(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"model": "qwen", "choices": [{"message": {"role": "assistant", "content": "", "tool_calls": [`+
+			`{"id": "c1", "type": "function", "function": {"name": "list_dir", "arguments": "{\"path\": \".\"}"}}]}, "finish_reason": "tool_calls"}]}`)
+	}))
+	defer server.Close()
+
+	client := NewOpenAIClient(server.URL, "", "qwen")
+	resp, err := client.Chat(context.Background(), &ChatRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []ToolCall{{ID: "c1", Function: ToolCallFunction{Name: "list_dir", Arguments: ToolCallArguments{"path": "."}}}}
+	if !resp.Done || resp.DoneReason != "tool_calls" || !reflect.DeepEqual(resp.Message.ToolCalls, want) {
+		t.Errorf("Chat = %+v", resp)
+	}
+}
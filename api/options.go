@@ -0,0 +1,45 @@
+package api
+
+// Options are the model parameters accepted by the Ollama generate and chat endpoints.
+// Zero values are omitted so that the model's own defaults apply; Temperature and Seed
+// are pointers because zero is a meaningful value for both.
+type Options struct {
+	// Context and output length
+	NumCtx     int `json:"num_ctx,omitempty"`
+	NumPredict int `json:"num_predict,omitempty"`
+	NumKeep    int `json:"num_keep,omitempty"`
+
+	// Sampling
+	Temperature *float64 `json:"temperature,omitempty"`
+	TopK        int      `json:"top_k,omitempty"`
+	TopP        float64  `json:"top_p,omitempty"`
+	MinP        float64  `json:"min_p,omitempty"`
+	TypicalP    float64  `json:"typical_p,omitempty"`
+	Seed        *int     `json:"seed,omitempty"`
+	Stop        []string `json:"stop,omitempty"`
+
+	// Repetition control
+	RepeatLastN      int     `json:"repeat_last_n,omitempty"`
+	RepeatPenalty    float64 `json:"repeat_penalty,omitempty"`
+	PresencePenalty  float64 `json:"presence_penalty,omitempty"`
+	FrequencyPenalty float64 `json:"frequency_penalty,omitempty"`
+
+	// Mirostat sampling (0 = disabled, 1 = Mirostat, 2 = Mirostat 2.0)
+	Mirostat    int     `json:"mirostat,omitempty"`
+	MirostatTau float64 `json:"mirostat_tau,omitempty"`
+	MirostatEta float64 `json:"mirostat_eta,omitempty"`
+
+	// Runtime
+	NumGPU    int `json:"num_gpu,omitempty"`
+	NumThread int `json:"num_thread,omitempty"`
+}
+
+// Float64 returns a pointer to v, for optional option fields
+func Float64(v float64) *float64 {
+	return &v
+}
+
+// Int returns a pointer to v, for optional option fields
+func Int(v int) *int {
+	return &v
+}
@@ -0,0 +1,338 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultHealthTimeout bounds the health check of a single endpoint
+const DefaultHealthTimeout = 3 * time.Second
+
+// ErrNoEndpoints is returned by a pool without endpoints
+var ErrNoEndpoints = errors.New("no endpoints configured")
+
+// Endpoint is an Ollama server of a pool. Endpoints with a lower Priority are preferred.
+type Endpoint struct {
+	Client   *OllamaClient
+	Priority int
+}
+
+// EndpointStatus is what the last health check and the last request found out about an
+// endpoint
+type EndpointStatus struct {
+	URL       string
+	Priority  int
+	Checked   bool     // Whether a health check has completed
+	Healthy   bool     // Answering requests; assumed before the first check
+	Version   string   // Ollama version
+	Loaded    []string // Models loaded into memory
+	Models    []string // Models available on the server
+	Error     string   // Why the endpoint is unhealthy
+	CheckedAt time.Time
+}
+
+// endpointState is an endpoint with its status
+type endpointState struct {
+	Endpoint
+	index  int // Position in the configuration, the last tie breaker
+	status EndpointStatus
+}
+
+// Pool spreads requests over several Ollama servers. Each request goes to the endpoint
+// that has the model loaded, or else has it available, preferring healthy endpoints with
+// a lower priority. When an endpoint fails, the request is repeated on the next one; a
+// streamed answer that was cut off is continued there from where it stopped.
+type Pool struct {
+	DefaultModel  string
+	HealthTimeout time.Duration
+	// OnFailover, if set, is called when a request moves from one endpoint to the next
+	OnFailover func(from string, to string, err error)
+
+	mu        sync.Mutex
+	endpoints []*endpointState
+}
+
+// NewPool creates a pool of endpoints
+func NewPool(endpoints []Endpoint, defaultModel string) *Pool {
+	p := &Pool{DefaultModel: defaultModel, HealthTimeout: DefaultHealthTimeout}
+	for i, e := range endpoints {
+		p.endpoints = append(p.endpoints, &endpointState{
+			Endpoint: e,
+			index:    i,
+			status:   EndpointStatus{URL: e.Client.BaseURL, Priority: e.Priority, Healthy: true},
+		})
+	}
+	return p
+}
+
+// Start checks the health of all endpoints, then keeps checking them in the background
+// every interval until ctx is done. With an interval of 0 only the first check is made.
+func (p *Pool) Start(ctx context.Context, interval time.Duration) {
+	p.Check(ctx)
+	if interval <= 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				p.Check(ctx)
+			}
+		}
+	}()
+}
+
+// Check asks every endpoint for its version, loaded models and available models, all at once
+func (p *Pool) Check(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, e := range p.endpoints {
+		wg.Add(1)
+		go func(e *endpointState) {
+			defer wg.Done()
+			status := p.probe(ctx, e)
+			p.mu.Lock()
+			e.status = status
+			p.mu.Unlock()
+		}(e)
+	}
+	wg.Wait()
+}
+
+// probe checks one endpoint with a single attempt per request
+func (p *Pool) probe(ctx context.Context, e *endpointState) EndpointStatus {
+	client := *e.Client
+	client.Retry.MaxRetries = 0
+	timeout := p.HealthTimeout
+	if timeout <= 0 {
+		timeout = DefaultHealthTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	status := EndpointStatus{URL: e.Client.BaseURL, Priority: e.Priority, Checked: true, CheckedAt: time.Now()}
+	version, err := client.Version(ctx)
+	if err != nil {
+		status.Error = err.Error()
+		return status
+	}
+	status.Healthy = true
+	status.Version = version
+
+	if running, err := client.ListRunning(ctx); err == nil {
+		for _, m := range running {
+			status.Loaded = append(status.Loaded, m.Name)
+		}
+	}
+	if models, err := client.ListModels(ctx); err == nil {
+		status.Models = models
+	}
+	return status
+}
+
+// Status returns the status of every endpoint in the order they are preferred for a model
+func (p *Pool) Status(model string) []EndpointStatus {
+	candidates := p.candidates(model)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	statuses := make([]EndpointStatus, len(candidates))
+	for i, e := range candidates {
+		statuses[i] = e.status
+	}
+	return statuses
+}
+
+// Client returns the client of the endpoint preferred for a model, for the requests a pool
+// does not route itself, such as pulling or showing a model
+func (p *Pool) Client(model string) (*OllamaClient, error) {
+	candidates := p.candidates(model)
+	if len(candidates) == 0 {
+		return nil, ErrNoEndpoints
+	}
+	return candidates[0].Client, nil
+}
+
+// candidates returns the endpoints to try for a model, best first: healthy endpoints that
+// have the model loaded, then those that have it available, those not checked yet, the
+// other healthy ones, and finally the unhealthy ones, each by priority
+func (p *Pool) candidates(model string) []*endpointState {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	rank := func(e *endpointState) int {
+		switch {
+		case !e.status.Healthy:
+			return 4
+		case HasModel(e.status.Loaded, model):
+			return 0
+		case HasModel(e.status.Models, model):
+			return 1
+		case !e.status.Checked:
+			return 2
+		}
+		return 3
+	}
+	candidates := make([]*endpointState, len(p.endpoints))
+	copy(candidates, p.endpoints)
+	sort.SliceStable(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		if ra, rb := rank(a), rank(b); ra != rb {
+			return ra < rb
+		}
+		if a.Priority != b.Priority {
+			return a.Priority < b.Priority
+		}
+		return a.index < b.index
+	})
+	return candidates
+}
+
+// failed records that a request to an endpoint failed
+func (p *Pool) failed(e *endpointState, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	e.status.Healthy = false
+	e.status.Error = err.Error()
+}
+
+// succeeded records that a request to an endpoint succeeded
+func (p *Pool) succeeded(e *endpointState) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	e.status.Healthy = true
+	e.status.Error = ""
+}
+
+// shouldFailover reports whether a failed request may succeed on another endpoint: the
+// endpoint could not be reached or broke off, answered with a server error or 429 Too
+// Many Requests, or does not have the model. Errors the model reported inside a stream,
+// invalid requests and cancellation would be the same anywhere.
+func shouldFailover(err error) bool {
+	if errors.Is(err, ErrCanceled) {
+		return false
+	}
+	if errors.Is(err, ErrModelNotFound) {
+		return true
+	}
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode >= 500 || apiErr.StatusCode == http.StatusTooManyRequests
+	}
+	return true
+}
+
+// try runs a request on each candidate endpoint for a model until one succeeds or fails in
+// a way another endpoint would not fix
+func (p *Pool) try(model string, request func(client *OllamaClient) error) error {
+	if model == "" {
+		model = p.DefaultModel
+	}
+	candidates := p.candidates(model)
+	if len(candidates) == 0 {
+		return ErrNoEndpoints
+	}
+	var err error
+	for i, e := range candidates {
+		if err = request(e.Client); err == nil {
+			p.succeeded(e)
+			return nil
+		}
+		if !shouldFailover(err) {
+			return err
+		}
+		if !errors.Is(err, ErrModelNotFound) {
+			p.failed(e, err)
+		}
+		if i+1 < len(candidates) && p.OnFailover != nil {
+			p.OnFailover(e.Client.BaseURL, candidates[i+1].Client.BaseURL, err)
+		}
+	}
+	if len(candidates) > 1 {
+		return fmt.Errorf("all %d endpoints failed, the last with: %w", len(candidates), err)
+	}
+	return err
+}
+
+// Chat sends a chat request to the best endpoint for the model
+func (p *Pool) Chat(ctx context.Context, req *ChatRequest) (*ChatResponse, error) {
+	var resp *ChatResponse
+	err := p.try(req.Model, func(client *OllamaClient) error {
+		attempt := *req
+		var err error
+		resp, err = client.Chat(ctx, &attempt)
+		return err
+	})
+	return resp, err
+}
+
+// ChatStream streams a chat request from the best endpoint for the model. If the stream
+// breaks off, the next endpoint is asked to continue the partial answer, which is sent
+// as the start of the assistant's message with the tool calls received so far.
+func (p *Pool) ChatStream(ctx context.Context, req *ChatRequest, handler StreamHandler) error {
+	var partial ChatMessage
+	return p.try(req.Model, func(client *OllamaClient) error {
+		attempt := *req
+		if partial.Content != "" || len(partial.ToolCalls) > 0 {
+			partial.Role = RoleAssistant
+			attempt.Messages = append(append([]ChatMessage(nil), req.Messages...), partial)
+		}
+		return client.ChatStream(ctx, &attempt, func(resp interface{}) {
+			if chatResp, ok := resp.(*ChatResponse); ok {
+				partial.Append(chatResp.Message)
+			}
+			handler(resp)
+		})
+	})
+}
+
+// Embed computes embeddings on the best endpoint for the embedding model
+func (p *Pool) Embed(ctx context.Context, req *EmbedRequest) (*EmbedResponse, error) {
+	var resp *EmbedResponse
+	err := p.try(req.Model, func(client *OllamaClient) error {
+		var err error
+		resp, err = client.Embed(ctx, req)
+		return err
+	})
+	return resp, err
+}
+
+// ListModels lists the models available on any healthy endpoint
+func (p *Pool) ListModels(ctx context.Context) ([]string, error) {
+	seen := make(map[string]bool)
+	var names []string
+	candidates := p.candidates(p.DefaultModel)
+	if len(candidates) == 0 {
+		return nil, ErrNoEndpoints
+	}
+	var lastErr error
+	for _, e := range candidates {
+		models, err := e.Client.ListModels(ctx)
+		if err != nil {
+			if errors.Is(err, ErrCanceled) {
+				return nil, err
+			}
+			p.failed(e, err)
+			lastErr = err
+			continue
+		}
+		p.succeeded(e)
+		for _, name := range models {
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	if names == nil && lastErr != nil {
+		return nil, lastErr
+	}
+	return names, nil
+}
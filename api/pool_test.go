@@ -0,0 +1,232 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync/atomic"
+	"testing"
+)
+
+// testPool creates a pool of clients for the given URLs without retries
+func testPool(urls ...string) *Pool {
+	endpoints := make([]Endpoint, len(urls))
+	for i, url := range urls {
+		client := NewClient(url, "m")
+		client.Retry.MaxRetries = 0
+		endpoints[i] = Endpoint{Client: client}
+	}
+	return NewPool(endpoints, "m")
+}
+
+// candidateURLs returns the URLs of the candidates for a model in order
+func candidateURLs(p *Pool, model string) []string {
+	var urls []string
+	for _, e := range p.candidates(model) {
+		urls = append(urls, e.Client.BaseURL)
+	}
+	return urls
+}
+
+func TestPoolCandidates(t *testing.T) {
+	p := testPool("down", "other", "unchecked", "available", "loaded", "loaded-low", "loaded-latest")
+	statuses := []EndpointStatus{
+		{Checked: true, Healthy: false, Loaded: []string{"m"}},
+		{Checked: true, Healthy: true, Models: []string{"x"}},
+		{Healthy: true},
+		{Checked: true, Healthy: true, Models: []string{"m"}},
+		{Checked: true, Healthy: true, Loaded: []string{"m"}},
+		{Checked: true, Healthy: true, Loaded: []string{"m"}},
+		{Checked: true, Healthy: true, Loaded: []string{"m:latest"}},
+	}
+	for i, e := range p.endpoints {
+		statuses[i].URL = e.Client.BaseURL
+		e.status = statuses[i]
+	}
+	p.endpoints[5].Priority = -1
+
+	want := []string{"loaded-low", "loaded", "loaded-latest", "available", "unchecked", "other", "down"}
+	if got := candidateURLs(p, "m"); !reflect.DeepEqual(got, want) {
+		t.Errorf("candidates = %v, want %v", got, want)
+	}
+	// For a model no endpoint has, unchecked endpoints come first, then the others by priority
+	want = []string{"unchecked", "loaded-low", "other", "available", "loaded", "loaded-latest", "down"}
+	if got := candidateURLs(p, "y"); !reflect.DeepEqual(got, want) {
+		t.Errorf("candidates for another model = %v, want %v", got, want)
+	}
+}
+
+func TestPoolWithoutEndpoints(t *testing.T) {
+	p := NewPool(nil, "m")
+	if _, err := p.Client("m"); !errors.Is(err, ErrNoEndpoints) {
+		t.Errorf("Client error = %v, want ErrNoEndpoints", err)
+	}
+	if _, err := p.Chat(context.Background(), &ChatRequest{Model: "m"}); !errors.Is(err, ErrNoEndpoints) {
+		t.Errorf("Chat error = %v, want ErrNoEndpoints", err)
+	}
+	if _, err := p.ListModels(context.Background()); !errors.Is(err, ErrNoEndpoints) {
+		t.Errorf("ListModels error = %v, want ErrNoEndpoints", err)
+	}
+}
+
+func TestShouldFailover(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"connection refused", fmt.Errorf("could not connect: %w", ErrConnectionRefused), true},
+		{"stream cut off", ErrIncompleteResponse, true},
+		{"timeout", &TimeoutError{Phase: TimeoutFirstToken}, true},
+		{"server error", &APIError{StatusCode: http.StatusInternalServerError, Message: "out of memory"}, true},
+		{"unavailable", &APIError{StatusCode: http.StatusServiceUnavailable, Retryable: true}, true},
+		{"too many requests", &APIError{StatusCode: http.StatusTooManyRequests, Retryable: true}, true},
+		{"missing model", &APIError{StatusCode: http.StatusNotFound, Message: `model "m" not found, try pulling it first`}, true},
+		{"missing model in a stream", streamError("model 'm' not found"), true},
+		{"error in a stream", streamError("template: bad function call"), false},
+		{"bad request", &APIError{StatusCode: http.StatusBadRequest, Message: "invalid options"}, false},
+		{"request timeout", &APIError{StatusCode: http.StatusRequestTimeout, Retryable: true}, false},
+		{"canceled", fmt.Errorf("%w: %w", ErrCanceled, context.Canceled), false},
+	}
+	for _, tt := range tests {
+		if got := shouldFailover(tt.err); got != tt.want {
+			t.Errorf("%s: shouldFailover = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestPoolFailover(t *testing.T) {
+	var failing, healthy int32
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&failing, 1)
+		w.WriteHeader(http.StatusInternalServerError)
+		fmt.Fprint(w, `{"error": "out of memory"}`)
+	}))
+	defer down.Close()
+	up := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&healthy, 1)
+		fmt.Fprint(w, `{"model": "m", "message": {"role": "assistant", "content": "ok"}, "done": true}`)
+	}))
+	defer up.Close()
+
+	p := testPool(down.URL, up.URL)
+	var moves []string
+	p.OnFailover = func(from string, to string, err error) { moves = append(moves, from+" -> "+to) }
+
+	resp, err := p.Chat(context.Background(), &ChatRequest{Model: "m"})
+	if err != nil || resp.Message.Content != "ok" {
+		t.Fatalf("Chat = %+v, %v", resp, err)
+	}
+	if !reflect.DeepEqual(moves, []string{down.URL + " -> " + up.URL}) {
+		t.Errorf("failovers = %v", moves)
+	}
+	// The failed endpoint is tried last from now on
+	if got := candidateURLs(p, "m"); !reflect.DeepEqual(got, []string{up.URL, down.URL}) {
+		t.Errorf("candidates after a failure = %v", got)
+	}
+	if _, err := p.Chat(context.Background(), &ChatRequest{Model: "m"}); err != nil || failing != 1 || healthy != 2 {
+		t.Errorf("second Chat: %v, %d requests to the failed endpoint, %d to the healthy one", err, failing, healthy)
+	}
+}
+
+func TestPoolNoFailoverOnModelError(t *testing.T) {
+	var second int32
+	first := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, `{"error": "template: bad function call"}`)
+	}))
+	defer first.Close()
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&second, 1)
+	}))
+	defer other.Close()
+
+	p := testPool(first.URL, other.URL)
+	err := p.ChatStream(context.Background(), &ChatRequest{Model: "m"}, func(interface{}) {})
+	if err == nil || second != 0 {
+		t.Errorf("ChatStream error = %v, %d requests to the second endpoint, want the model's error only", err, second)
+	}
+	if status := p.Status("m"); !status[0].Healthy || status[0].URL != first.URL {
+		t.Errorf("an endpoint was marked down for the model's error: %+v", status)
+	}
+}
+
+func TestPoolChatStreamKeepsToolCalls(t *testing.T) {
+	broken := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, `{"message": {"role": "assistant", "content": "Let me look. "}, "done": false}`)
+		fmt.Fprintln(w, `{"message": {"role": "assistant", "tool_calls": [{"function": {"name": "read_file", "arguments": {"path": "a.go"}}}]}, "done": false}`)
+	}))
+	defer broken.Close()
+	var continued ChatRequest
+	next := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&continued); err != nil {
+			t.Error(err)
+		}
+		fmt.Fprintln(w, `{"message": {"role": "assistant", "content": ""}, "done": true}`)
+	}))
+	defer next.Close()
+
+	p := testPool(broken.URL, next.URL)
+	var reply ChatMessage
+	err := p.ChatStream(context.Background(), &ChatRequest{Model: "m", Messages: []ChatMessage{{Role: RoleUser, Content: "Read a.go"}}}, func(resp interface{}) {
+		reply.Append(resp.(*ChatResponse).Message)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reply.Content != "Let me look. " || len(reply.ToolCalls) != 1 {
+		t.Errorf("reply = %+v", reply)
+	}
+
+	if len(continued.Messages) != 2 {
+		t.Fatalf("continued with %d messages, want the question and the partial answer", len(continued.Messages))
+	}
+	partial := continued.Messages[1]
+	if partial.Role != RoleAssistant || partial.Content != "Let me look. " || len(partial.ToolCalls) != 1 || partial.ToolCalls[0].Function.Name != "read_file" {
+		t.Errorf("partial answer sent to the next endpoint = %+v", partial)
+	}
+}
+
+func TestPoolHealthRecovery(t *testing.T) {
+	var up atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !up.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		switch r.URL.Path {
+		case "/api/version":
+			fmt.Fprint(w, `{"version": "0.5.0"}`)
+		case "/api/ps":
+			fmt.Fprint(w, `{"models": [{"name": "m:latest"}]}`)
+		case "/api/tags":
+			fmt.Fprint(w, `{"models": [{"name": "m:latest"}]}`)
+		}
+	}))
+	defer server.Close()
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/version" {
+			fmt.Fprint(w, `{"version": "0.5.0"}`)
+			return
+		}
+		fmt.Fprint(w, `{"models": []}`)
+	}))
+	defer other.Close()
+
+	p := testPool(server.URL, other.URL)
+	p.Check(context.Background())
+	status := p.Status("m")
+	if status[0].URL != other.URL || status[1].Healthy || status[1].Error == "" {
+		t.Fatalf("status while down = %+v", status)
+	}
+
+	up.Store(true)
+	p.Check(context.Background())
+	status = p.Status("m")
+	if status[0].URL != server.URL || !status[0].Healthy || status[0].Version != "0.5.0" || !reflect.DeepEqual(status[0].Loaded, []string{"m:latest"}) {
+		t.Errorf("status after recovery = %+v", status)
+	}
+}
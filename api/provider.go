@@ -0,0 +1,24 @@
+package api
+
+import "context"
+
+// Provider is a backend that serves chat and embeddings: Ollama, or a server speaking
+// another protocol. Model management (pull, show, create, ...) is specific to Ollama and
+// not part of it.
+type Provider interface {
+	// Chat sends a chat request and returns the complete answer
+	Chat(ctx context.Context, req *ChatRequest) (*ChatResponse, error)
+	// ChatStream sends a chat request and passes each part of the answer to handler as a
+	// *ChatResponse, the last one with Done set
+	ChatStream(ctx context.Context, req *ChatRequest, handler StreamHandler) error
+	// ListModels lists the names of the models the server offers
+	ListModels(ctx context.Context) ([]string, error)
+	// Embed returns one embedding vector per input text
+	Embed(ctx context.Context, req *EmbedRequest) (*EmbedResponse, error)
+}
+
+var (
+	_ Provider = (*OllamaClient)(nil)
+	_ Provider = (*OpenAIClient)(nil)
+	_ Provider = (*Pool)(nil)
+)
@@ -0,0 +1,173 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+// Timeout phases reported by TimeoutError
+const (
+	TimeoutConnect    = "connect"
+	TimeoutFirstToken = "first token"
+	TimeoutIdle       = "idle"
+)
+
+// ErrTimeout matches every TimeoutError with errors.Is
+var ErrTimeout = errors.New("timeout")
+
+// Timeouts bound the phases of a request. A zero value disables that timeout.
+type Timeouts struct {
+	Connect    time.Duration // Establishing the TCP connection
+	FirstToken time.Duration // Until the response starts arriving, including model loading; Idle applies from then on
+	Idle       time.Duration // Between two reads of the response body once it has started
+}
+
+// DefaultTimeouts is used by NewClient
+var DefaultTimeouts = Timeouts{
+	Connect:    10 * time.Second,
+	FirstToken: 5 * time.Minute,
+	Idle:       time.Minute,
+}
+
+// TimeoutError reports which timeout stopped a request
+type TimeoutError struct {
+	Phase string
+	Limit time.Duration
+}
+
+// Error implements the error interface
+func (e *TimeoutError) Error() string {
+	switch e.Phase {
+	case TimeoutConnect:
+		return fmt.Sprintf("connect timeout: could not connect to Ollama within %s", e.Limit)
+	case TimeoutFirstToken:
+		return fmt.Sprintf("first-token timeout: the model did not start responding within %s (it may still be loading; raise first_token_timeout)", e.Limit)
+	case TimeoutIdle:
+		return fmt.Sprintf("idle timeout: no output from the model for %s", e.Limit)
+	}
+	return fmt.Sprintf("%s timeout after %s", e.Phase, e.Limit)
+}
+
+// Is lets errors.Is match a TimeoutError against ErrTimeout
+func (e *TimeoutError) Is(target error) bool {
+	return target == ErrTimeout
+}
+
+// Timeout reports true, as for net.Error
+func (e *TimeoutError) Timeout() bool {
+	return true
+}
+
+// dialContext returns a dialer that applies the client's current connect timeout
+func (c *baseClient) dialContext(ctx context.Context, network string, addr string) (net.Conn, error) {
+	dialer := net.Dialer{Timeout: c.Timeouts.Connect, KeepAlive: 30 * time.Second}
+	conn, err := dialer.DialContext(ctx, network, addr)
+	var netErr net.Error
+	if err != nil && c.Timeouts.Connect > 0 && ctx.Err() == nil && errors.As(err, &netErr) && netErr.Timeout() {
+		return nil, &TimeoutError{Phase: TimeoutConnect, Limit: c.Timeouts.Connect}
+	}
+	return conn, err
+}
+
+// watchdog cancels a request when the first-token or idle timeout expires
+type watchdog struct {
+	mu       sync.Mutex
+	cancel   context.CancelCauseFunc
+	timer    *time.Timer
+	idle     time.Duration
+	started  bool
+	finished bool
+}
+
+// newWatchdog derives a request context that is canceled with a TimeoutError when the
+// response does not start within timeouts.FirstToken
+func newWatchdog(ctx context.Context, timeouts Timeouts) (context.Context, *watchdog) {
+	reqCtx, cancel := context.WithCancelCause(ctx)
+	w := &watchdog{cancel: cancel, idle: timeouts.Idle}
+	if timeouts.FirstToken > 0 {
+		w.timer = w.afterFunc(&TimeoutError{Phase: TimeoutFirstToken, Limit: timeouts.FirstToken})
+	}
+	return reqCtx, w
+}
+
+// afterFunc cancels the request with cause once its limit has passed
+func (w *watchdog) afterFunc(cause *TimeoutError) *time.Timer {
+	return time.AfterFunc(cause.Limit, func() { w.cancel(cause) })
+}
+
+// progress records that response data arrived, switching to or restarting the idle timeout
+func (w *watchdog) progress() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.finished {
+		return
+	}
+	if !w.started {
+		w.started = true
+		if w.timer != nil {
+			w.timer.Stop()
+			w.timer = nil
+		}
+		if w.idle > 0 {
+			w.timer = w.afterFunc(&TimeoutError{Phase: TimeoutIdle, Limit: w.idle})
+		}
+		return
+	}
+	if w.timer != nil {
+		w.timer.Reset(w.idle)
+	}
+}
+
+// stop releases the timer and the request context
+func (w *watchdog) stop() {
+	w.mu.Lock()
+	w.finished = true
+	if w.timer != nil {
+		w.timer.Stop()
+	}
+	w.mu.Unlock()
+	w.cancel(nil)
+}
+
+// timeoutCause returns the TimeoutError that canceled ctx, if any
+func timeoutCause(ctx context.Context) error {
+	var timeoutErr *TimeoutError
+	if errors.As(context.Cause(ctx), &timeoutErr) {
+		return timeoutErr
+	}
+	return nil
+}
+
+// watchedBody feeds response reads to the watchdog and reports timeouts instead of
+// the bare cancellation error
+type watchedBody struct {
+	io.ReadCloser
+	ctx context.Context
+	w   *watchdog
+}
+
+// Read implements io.Reader
+func (b *watchedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if n > 0 {
+		b.w.progress()
+	}
+	if err != nil && err != io.EOF {
+		if timeoutErr := timeoutCause(b.ctx); timeoutErr != nil {
+			return n, timeoutErr
+		}
+	}
+	return n, err
+}
+
+// Close implements io.Closer
+func (b *watchedBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.w.stop()
+	return err
+}
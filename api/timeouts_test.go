@@ -0,0 +1,119 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// wait blocks for d or until the client goes away, which the server only notices once
+// the request body has been read
+func wait(r *http.Request, d time.Duration) {
+	_, _ = io.Copy(io.Discard, r.Body)
+	select {
+	case <-time.After(d):
+	case <-r.Context().Done():
+	}
+}
+
+func TestTimeouts(t *testing.T) {
+	chunk := `{"message": {"content": "a"}, "done": false}` + "\n"
+	tests := []struct {
+		name    string
+		handler func(w http.ResponseWriter, r *http.Request)
+		phase   string // Expected timeout; empty for success
+	}{
+		{
+			"slow to start",
+			func(w http.ResponseWriter, r *http.Request) {
+				wait(r, 2*time.Second)
+			},
+			TimeoutFirstToken,
+		},
+		{
+			"stalls after the first token",
+			func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprint(w, chunk)
+				w.(http.Flusher).Flush()
+				wait(r, 2*time.Second)
+			},
+			TimeoutIdle,
+		},
+		{
+			"slow but steady",
+			func(w http.ResponseWriter, r *http.Request) {
+				// Longer in total than both timeouts, but never idle for long
+				for i := 0; i < 8; i++ {
+					fmt.Fprint(w, chunk)
+					w.(http.Flusher).Flush()
+					wait(r, 40*time.Millisecond)
+				}
+				fmt.Fprint(w, `{"done": true}`+"\n")
+			},
+			"",
+		},
+	}
+	for _, tt := range tests {
+		var attempts int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&attempts, 1)
+			tt.handler(w, r)
+		}))
+		client := NewClient(server.URL, "m")
+		client.Retry = fastRetry
+		client.Timeouts = Timeouts{Connect: time.Second, FirstToken: 150 * time.Millisecond, Idle: 150 * time.Millisecond}
+
+		err := client.ChatStream(context.Background(), &ChatRequest{}, func(interface{}) {})
+		server.Close()
+
+		if tt.phase == "" {
+			if err != nil {
+				t.Errorf("%s: %v", tt.name, err)
+			}
+			continue
+		}
+		var timeoutErr *TimeoutError
+		if !errors.As(err, &timeoutErr) || timeoutErr.Phase != tt.phase || !errors.Is(err, ErrTimeout) {
+			t.Errorf("%s: error = %v, want a %s timeout", tt.name, err, tt.phase)
+		}
+		if attempts != 1 {
+			t.Errorf("%s: %d attempts; timeouts after the request was sent must not be retried", tt.name, attempts)
+		}
+	}
+}
+
+func TestTimeoutsDisabled(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		wait(r, 100*time.Millisecond)
+		fmt.Fprint(w, `{"message": {"content": "late"}, "done": true}`)
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "m")
+	client.Timeouts = Timeouts{}
+	if _, err := client.Chat(context.Background(), &ChatRequest{}); err != nil {
+		t.Errorf("Chat with no timeouts: %v", err)
+	}
+}
+
+func TestTimeoutErrorMessage(t *testing.T) {
+	tests := []struct {
+		err  *TimeoutError
+		want string
+	}{
+		{&TimeoutError{Phase: TimeoutConnect, Limit: 10 * time.Second}, "connect timeout: could not connect to Ollama within 10s"},
+		{&TimeoutError{Phase: TimeoutIdle, Limit: time.Minute}, "idle timeout: no output from the model for 1m0s"},
+		{&TimeoutError{Phase: "other", Limit: time.Second}, "other timeout after 1s"},
+	}
+	for _, tt := range tests {
+		if got := tt.err.Error(); got != tt.want {
+			t.Errorf("Error() = %q, want %q", got, tt.want)
+		}
+	}
+}
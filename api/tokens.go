@@ -0,0 +1,32 @@
+package api
+
+// Rough token accounting used to keep prompts within a model's context window.
+// Most code-oriented tokenizers average close to four characters per token.
+const (
+	charsPerToken     = 4
+	tokensPerMessage  = 4
+	tokensPerToolCall = 16
+)
+
+// EstimateTokens returns an approximate token count for a piece of text
+func EstimateTokens(text string) int {
+	if text == "" {
+		return 0
+	}
+	return (len(text) + charsPerToken - 1) / charsPerToken
+}
+
+// EstimateMessageTokens returns an approximate token count for a list of chat messages
+func EstimateMessageTokens(messages []ChatMessage) int {
+	total := 0
+	for _, msg := range messages {
+		total += tokensPerMessage + EstimateTokens(msg.Content)
+		for _, call := range msg.ToolCalls {
+			total += tokensPerToolCall + EstimateTokens(call.Function.Name)
+			for name := range call.Function.Arguments {
+				total += EstimateTokens(name) + EstimateTokens(call.Function.Arguments.String(name))
+			}
+		}
+	}
+	return total
+}
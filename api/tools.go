@@ -0,0 +1,156 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+// Chat message roles understood by the Ollama chat API
+const (
+	RoleSystem    = "system"
+	RoleUser      = "user"
+	RoleAssistant = "assistant"
+	RoleTool      = "tool"
+)
+
+// Tool describes a function the model is allowed to call
+type Tool struct {
+	Type     string       `json:"type"`
+	Function ToolFunction `json:"function"`
+}
+
+// ToolFunction is the name, description and parameter schema of a callable function
+type ToolFunction struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description"`
+	Parameters  ToolParameters `json:"parameters"`
+}
+
+// ToolParameters is the JSON schema object describing a function's arguments
+type ToolParameters struct {
+	Type       string                  `json:"type"`
+	Required   []string                `json:"required,omitempty"`
+	Properties map[string]ToolProperty `json:"properties"`
+}
+
+// ToolProperty is the JSON schema of a single function argument
+type ToolProperty struct {
+	Type        string        `json:"type"`
+	Description string        `json:"description,omitempty"`
+	Enum        []string      `json:"enum,omitempty"`
+	Items       *ToolProperty `json:"items,omitempty"`
+}
+
+// ToolCall is a function call requested by the model
+type ToolCall struct {
+	ID       string           `json:"id,omitempty"`
+	Function ToolCallFunction `json:"function"`
+}
+
+// ToolCallFunction holds the name and arguments of a requested function call
+type ToolCallFunction struct {
+	Index     int               `json:"index,omitempty"`
+	Name      string            `json:"name"`
+	Arguments ToolCallArguments `json:"arguments"`
+}
+
+// ToolCallArguments are the decoded arguments of a tool call
+type ToolCallArguments map[string]interface{}
+
+// NewTool creates a function tool with an object parameter schema
+func NewTool(name, description string, properties map[string]ToolProperty, required ...string) Tool {
+	if properties == nil {
+		properties = map[string]ToolProperty{}
+	}
+	return Tool{
+		Type: "function",
+		Function: ToolFunction{
+			Name:        name,
+			Description: description,
+			Parameters: ToolParameters{
+				Type:       "object",
+				Required:   required,
+				Properties: properties,
+			},
+		},
+	}
+}
+
+// NewToolResultMessage creates a "tool" role message carrying the result of a tool call
+func NewToolResultMessage(toolName, content string) ChatMessage {
+	return ChatMessage{
+		Role:     RoleTool,
+		Content:  content,
+		ToolName: toolName,
+	}
+}
+
+// Append merges a streamed chunk into the message, concatenating content and collecting tool calls
+func (m *ChatMessage) Append(chunk ChatMessage) {
+	if m.Role == "" {
+		m.Role = chunk.Role
+	}
+	m.Content += chunk.Content
+	m.ToolCalls = append(m.ToolCalls, chunk.ToolCalls...)
+}
+
+// UnmarshalJSON accepts arguments either as a JSON object or as a JSON-encoded string,
+// since some models emit the latter
+func (a *ToolCallArguments) UnmarshalJSON(data []byte) error {
+	var encoded string
+	if err := json.Unmarshal(data, &encoded); err == nil {
+		if encoded == "" {
+			*a = ToolCallArguments{}
+			return nil
+		}
+		data = []byte(encoded)
+	}
+
+	var args map[string]interface{}
+	if err := json.Unmarshal(data, &args); err != nil {
+		return fmt.Errorf("invalid tool call arguments: %w", err)
+	}
+	*a = args
+	return nil
+}
+
+// String returns the argument as a string, or an empty string if it is missing
+func (a ToolCallArguments) String(name string) string {
+	switch v := a[name].(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// Int returns the argument as an int, or def if it is missing or not numeric
+func (a ToolCallArguments) Int(name string, def int) int {
+	switch v := a[name].(type) {
+	case float64:
+		return int(v)
+	case string:
+		if n, err := strconv.Atoi(v); err == nil {
+			return n
+		}
+	}
+	return def
+}
+
+// Bool returns the argument as a bool, or def if it is missing
+func (a ToolCallArguments) Bool(name string, def bool) bool {
+	switch v := a[name].(type) {
+	case bool:
+		return v
+	case string:
+		if b, err := strconv.ParseBool(v); err == nil {
+			return b
+		}
+	}
+	return def
+}
@@ -0,0 +1,158 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestToolCallArgumentsUnmarshal(t *testing.T) {
+	tests := []struct {
+		name string
+		json string
+		want ToolCallArguments
+	}{
+		{"object", `{"path": "main.go", "line": 3}`, ToolCallArguments{"path": "main.go", "line": float64(3)}},
+		{"encoded string", `"{\"path\": \"main.go\"}"`, ToolCallArguments{"path": "main.go"}},
+		{"empty string", `""`, ToolCallArguments{}},
+		{"empty object", `{}`, ToolCallArguments{}},
+	}
+	for _, tt := range tests {
+		var args ToolCallArguments
+		if err := json.Unmarshal([]byte(tt.json), &args); err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(args, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, args, tt.want)
+		}
+	}
+
+	for _, invalid := range []string{`"not json"`, `[1, 2]`, `42`} {
+		var args ToolCallArguments
+		if err := json.Unmarshal([]byte(invalid), &args); err == nil {
+			t.Errorf("Unmarshal(%s) accepted invalid arguments: %v", invalid, args)
+		}
+	}
+}
+
+func TestToolCallArgumentAccessors(t *testing.T) {
+	args := ToolCallArguments{
+		"path":    "main.go",
+		"line":    float64(42),
+		"ratio":   1.5,
+		"count":   "7",
+		"force":   true,
+		"recurse": "false",
+		"list":    []interface{}{"a"},
+	}
+
+	texts := []struct{ name, want string }{
+		{"path", "main.go"},
+		{"line", "42"},
+		{"ratio", "1.5"},
+		{"force", "true"},
+		{"list", "[a]"},
+		{"missing", ""},
+	}
+	for _, tt := range texts {
+		if got := args.String(tt.name); got != tt.want {
+			t.Errorf("String(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+
+	ints := []struct {
+		name string
+		want int
+	}{
+		{"line", 42},
+		{"count", 7},
+		{"path", -1},
+		{"missing", -1},
+	}
+	for _, tt := range ints {
+		if got := args.Int(tt.name, -1); got != tt.want {
+			t.Errorf("Int(%q) = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+
+	bools := []struct {
+		name string
+		want bool
+	}{
+		{"force", true},
+		{"recurse", false},
+		{"path", true},
+		{"missing", true},
+	}
+	for _, tt := range bools {
+		if got := args.Bool(tt.name, true); got != tt.want {
+			t.Errorf("Bool(%q) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestChatMessageAppend(t *testing.T) {
+	var msg ChatMessage
+	msg.Append(ChatMessage{Role: RoleAssistant, Content: "Let me "})
+	msg.Append(ChatMessage{Content: "look."})
+	msg.Append(ChatMessage{ToolCalls: []ToolCall{{Function: ToolCallFunction{Name: "read_file"}}}})
+
+	if msg.Role != RoleAssistant || msg.Content != "Let me look." || len(msg.ToolCalls) != 1 {
+		t.Errorf("Append = %+v", msg)
+	}
+}
+
+func TestChatStreamToolCalls(t *testing.T) {
+	var received ChatRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&received); err != nil {
+			t.Errorf("failed to decode request: %v", err)
+		}
+		fmt.Fprintln(w, `{"message": {"role": "assistant", "content": "Reading"}, "done": false}`)
+		fmt.Fprintln(w, `{"message": {"role": "assistant", "content": "", "tool_calls": [`+
+			`{"function": {"name": "read_file", "arguments": {"path": "main.go"}}},`+
+			`{"function": {"name": "grep", "arguments": "{\"pattern\": \"TODO\"}"}}]}, "done": false}`)
+		fmt.Fprintln(w, `{"message": {"role": "assistant", "content": ""}, "done": true, "done_reason": "stop"}`)
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "llama3.2")
+	tools := []Tool{NewTool("read_file", "Read a file", map[string]ToolProperty{"path": {Type: "string"}}, "path")}
+	var answer ChatMessage
+	err := client.ChatStream(context.Background(), &ChatRequest{
+		Messages: []ChatMessage{{Role: RoleUser, Content: "What does main.go do?"}},
+		Tools:    tools,
+	}, func(resp interface{}) {
+		answer.Append(resp.(*ChatResponse).Message)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if received.Model != "llama3.2" || !received.Stream || !reflect.DeepEqual(received.Tools, tools) {
+		t.Errorf("request = %+v", received)
+	}
+	if answer.Content != "Reading" {
+		t.Errorf("content = %q", answer.Content)
+	}
+	want := []ToolCall{
+		{Function: ToolCallFunction{Name: "read_file", Arguments: ToolCallArguments{"path": "main.go"}}},
+		{Function: ToolCallFunction{Name: "grep", Arguments: ToolCallArguments{"pattern": "TODO"}}},
+	}
+	if !reflect.DeepEqual(answer.ToolCalls, want) {
+		t.Errorf("tool calls = %+v, want %+v", answer.ToolCalls, want)
+	}
+}
+
+func TestNewToolResultMessage(t *testing.T) {
+	msg := NewToolResultMessage("read_file", "package main")
+	want := ChatMessage{Role: RoleTool, Content: "package main", ToolName: "read_file"}
+	if !reflect.DeepEqual(msg, want) {
+		t.Errorf("NewToolResultMessage = %+v", msg)
+	}
+}
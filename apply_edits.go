@@ -0,0 +1,170 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ai-in-pm/Ollama-Code/agent"
+	"github.com/ai-in-pm/Ollama-Code/edits"
+	"github.com/ai-in-pm/Ollama-Code/ui"
+	"github.com/spf13/cobra"
+)
+
+// Tasks whose answers are expected to contain applicable edits
+var editTasks = map[string]bool{
+	"refactor": true,
+	"debug":    true,
+	"doc":      true,
+}
+
+// resolveEditPath maps a file name proposed by the model to a path inside the project.
+// Names are tried relative to the project root first, then to the working directory.
+func resolveEditPath(root string, name string) (string, error) {
+	ws := &agent.Workspace{Root: root}
+	path, err := ws.Resolve(name)
+	if err != nil {
+		return "", err
+	}
+	if _, err := os.Stat(path); err == nil || filepath.IsAbs(name) {
+		return path, nil
+	}
+
+	if workDir, err := os.Getwd(); err == nil {
+		if candidate, err := ws.Resolve(filepath.Join(workDir, name)); err == nil {
+			if _, err := os.Stat(candidate); err == nil {
+				return candidate, nil
+			}
+		}
+	}
+	return path, nil
+}
+
+// applyProposedEdits parses search/replace blocks from a model response, shows each as a
+// diff and writes the accepted ones with a backup that can be restored with undo
+func applyProposedEdits(terminal *ui.TerminalUI, targetFile string, response string) {
+	absTarget, err := filepath.Abs(targetFile)
+	if err != nil {
+		terminal.AddMessage("system", "Error: "+err.Error())
+		return
+	}
+	root := findProjectRoot(absTarget)
+
+	blocks := edits.ParseBlocks(response, absTarget)
+	if len(blocks) == 0 {
+		return
+	}
+
+	// Accepted contents per file; later blocks build on earlier accepted ones
+	updated := make(map[string]string)
+	var order []string
+	accepted := 0
+
+	for i, block := range blocks {
+		path, err := resolveEditPath(root, block.Path)
+		if err != nil {
+			terminal.AddMessage("system", fmt.Sprintf("Skipping block %d: %v", i+1, err))
+			continue
+		}
+
+		current, ok := updated[path]
+		if !ok {
+			data, err := os.ReadFile(path)
+			if err != nil && !os.IsNotExist(err) {
+				terminal.AddMessage("system", fmt.Sprintf("Skipping block %d: %v", i+1, err))
+				continue
+			}
+			current = string(data)
+		}
+
+		block.Path = path
+		// The model saw masked secrets; the search text has to match the real file
+		block.Search = unmaskSearch(block.Search)
+		block.Replace = restoreSecrets(block.Replace)
+		next, err := edits.ApplyBlock(current, block)
+		if err != nil {
+			terminal.AddMessage("system", fmt.Sprintf("Skipping block %d: %v", i+1, err))
+			continue
+		}
+
+		relPath, _ := filepath.Rel(root, path)
+		terminal.ShowDiff(edits.Diff(relPath, current, next))
+		if !terminal.Confirm(fmt.Sprintf("Apply block %d of %d to %s?", i+1, len(blocks), relPath)) {
+			continue
+		}
+
+		if _, seen := updated[path]; !seen {
+			order = append(order, path)
+		}
+		updated[path] = next
+		accepted++
+	}
+
+	if accepted == 0 {
+		terminal.AddMessage("system", "No changes applied")
+		return
+	}
+
+	cs, err := edits.Apply(config.BackupDir, updated)
+	if err != nil {
+		terminal.AddMessage("system", "Error applying changes: "+err.Error())
+		if cs != nil {
+			terminal.AddMessage("system", "Some files may have been written; run 'undo' to restore them")
+		}
+		return
+	}
+
+	var names []string
+	for _, path := range order {
+		relPath, _ := filepath.Rel(root, path)
+		names = append(names, relPath)
+	}
+	terminal.AddMessage("system", fmt.Sprintf("Applied %d block(s) to %s. Use undo to revert.", accepted, strings.Join(names, ", ")))
+}
+
+// undoLastChange restores the files changed by the most recent apply. Unless force is set,
+// it fails with an *edits.ModifiedError if they were changed since.
+func undoLastChange(force bool) (string, error) {
+	cs, err := edits.Undo(config.BackupDir, force)
+	if err != nil {
+		if errors.Is(err, edits.ErrNothingToUndo) {
+			return "Nothing to undo", nil
+		}
+		return "", err
+	}
+
+	var names []string
+	for _, file := range cs.Files {
+		names = append(names, file.Path)
+	}
+	return fmt.Sprintf("Restored %d file(s) changed at %s:\n  %s", len(names), cs.Time.Local().Format(time.DateTime), strings.Join(names, "\n  ")), nil
+}
+
+// newUndoCmd creates the undo command
+func newUndoCmd() *cobra.Command {
+	var force bool
+	cmd := &cobra.Command{
+		Use:   "undo",
+		Short: "Revert the last change set applied by refactor, debug or doc",
+		Long: `Reverts the last change set applied by refactor, debug or doc. If one of its files was
+changed again since, nothing is reverted unless --force is given, which discards those changes.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			message, err := undoLastChange(force)
+			var modified *edits.ModifiedError
+			if errors.As(err, &modified) {
+				return fmt.Errorf("%w; use --force to restore them anyway", err)
+			}
+			if err != nil {
+				return err
+			}
+			fmt.Println(message)
+			return nil
+		},
+	}
+	cmd.Flags().BoolVar(&force, "force", false, "Restore the files even if they were changed since the change set was applied")
+	return cmd
+}
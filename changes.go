@@ -0,0 +1,72 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"strings"
+
+	"github.com/ai-in-pm/Ollama-Code/api"
+	"github.com/ai-in-pm/Ollama-Code/context_manager"
+	"github.com/ai-in-pm/Ollama-Code/ui"
+	"github.com/spf13/cobra"
+)
+
+// defaultChangesCommits is the number of recent commits listed with the changes
+const defaultChangesCommits = 5
+
+// defaultChangesQuestion is asked when the user asks about the changes without a question
+const defaultChangesQuestion = "Review these changes. Point out anything that could break or behave differently than before."
+
+// changesContext returns the uncommitted changes and recent commits of the repository
+// containing the working directory
+func changesContext(staged bool, commits int) (string, error) {
+	workDir, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+	root := findMarkedRoot(workDir)
+	if root == "" {
+		root = workDir
+	}
+
+	cm := context_manager.NewContextManager(root)
+	cm.SetTokenBudget(fileContextBudget())
+	changes, err := cm.ChangesContext(context_manager.ChangesOptions{StagedOnly: staged, Commits: commits})
+	if errors.Is(err, context_manager.ErrNotGitRepository) {
+		return "", errors.New("the current directory is not in a git repository")
+	}
+	return changes, err
+}
+
+// runChanges asks a question about the uncommitted changes, e.g. why something broke
+func runChanges(client api.Provider, terminal *ui.TerminalUI, userInput string, question string, staged bool, commits int) {
+	changes, err := changesContext(staged, commits)
+	if err != nil {
+		terminal.AddMessage("system", "Error: "+err.Error())
+		return
+	}
+	if question == "" {
+		question = defaultChangesQuestion
+	}
+	handlePrompt(client, terminal, "debug", userInput, buildPrompt("debug", "Unknown", changes, question))
+}
+
+// newChangesCmd creates the changes command
+func newChangesCmd() *cobra.Command {
+	var staged bool
+	var commits int
+
+	cmd := &cobra.Command{
+		Use:   "changes [question]",
+		Short: "Ask about the uncommitted changes and recent commits",
+		Long: `Sends the staged and unstaged changes of the git repository, new files that are not yet
+added and the most recent commits to the model together with the question, for example
+"why does the login test fail now?". Without a question the changes are reviewed.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			runChanges(newProvider(), ui.NewTerminalUI(), "", strings.Join(args, " "), staged, commits)
+		},
+	}
+	cmd.Flags().BoolVar(&staged, "staged", false, "Only include the staged changes")
+	cmd.Flags().IntVar(&commits, "commits", defaultChangesCommits, "Number of recent commits to list")
+	return cmd
+}
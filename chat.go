@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/ai-in-pm/Ollama-Code/api"
+	"github.com/ai-in-pm/Ollama-Code/history"
+	"github.com/ai-in-pm/Ollama-Code/ui"
+)
+
+// summarySystemPrompt instructs the model how to condense earlier turns of a conversation
+const summarySystemPrompt = "You condense conversations between a developer and a coding assistant. " +
+	"Write a concise summary of the conversation below that preserves file names, code identifiers, " +
+	"decisions, errors and open questions. Reply with the summary only."
+
+// minRecentMessages is the number of latest messages that are never summarized
+const minRecentMessages = 2
+
+// systemPrompt returns the configured system prompt for a task
+func systemPrompt(task string) string {
+	if systemMsg, ok := config.SystemPrompts[task]; ok {
+		return systemMsg
+	}
+	return "You are a helpful AI coding assistant."
+}
+
+// conversationMessages returns the messages sent to the model: the system prompt,
+// a summary of earlier turns and the turns that still fit in the context window
+func conversationMessages(session *history.Session, systemMsg string) []api.ChatMessage {
+	content := systemMsg
+	if session.Summary != "" {
+		content += "\n\nSummary of the earlier conversation:\n" + session.Summary
+	}
+
+	messages := []api.ChatMessage{{Role: api.RoleSystem, Content: content}}
+	return append(messages, session.RecentMessages()...)
+}
+
+// contextBudget returns the number of prompt tokens available after reserving room for the
+// answer: num_predict tokens, or a quarter of the window when generation is unlimited
+func contextBudget() int {
+	window := contextSize()
+	reserve := config.MaxTokens
+	if reserve <= 0 {
+		reserve = window / 4
+	}
+	budget := window - reserve
+	if budget < window/2 {
+		budget = window / 2
+	}
+	return budget
+}
+
+// compactConversation summarizes the oldest turns of the session until the conversation and
+// the pending message fit in the context window. If the model cannot produce a summary the
+// oldest turns are dropped from the prompt instead; they remain in the saved history.
+func compactConversation(ctx context.Context, client api.Provider, terminal *ui.TerminalUI, session *history.Session, systemMsg string, pending api.ChatMessage) {
+	budget := contextBudget()
+
+	for {
+		messages := append(conversationMessages(session, systemMsg), pending)
+		if api.EstimateMessageTokens(messages) <= budget {
+			return
+		}
+
+		recent := len(session.Messages) - session.SummarizedCount
+		if recent <= minRecentMessages {
+			return
+		}
+
+		// Fold the older half of the recent turns into the summary
+		count := recent / 2
+		if recent-count < minRecentMessages {
+			count = recent - minRecentMessages
+		}
+		end := session.SummarizedCount + count
+		// Only cut at the start of a user turn so replies and tool results stay with their prompt,
+		// and never fold the latest minRecentMessages
+		limit := len(session.Messages) - minRecentMessages
+		for end <= limit && session.Messages[end].Role != api.RoleUser {
+			end++
+		}
+		if end > limit {
+			return
+		}
+
+		terminal.SetLoading(true, "Summarizing earlier conversation...")
+		summary, err := summarizeMessages(ctx, client, session.Summary, session.Messages[session.SummarizedCount:end])
+		terminal.SetLoading(false, "")
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			terminal.AddMessage("system", fmt.Sprintf("Could not summarize earlier conversation (%v); dropping %d old messages from the prompt", err, end-session.SummarizedCount))
+			summary = session.Summary
+		}
+
+		session.Summary = summary
+		session.SummarizedCount = end
+	}
+}
+
+// summarizeMessages asks the model to fold messages into an existing summary
+func summarizeMessages(ctx context.Context, client api.Provider, previous string, messages []history.Message) (string, error) {
+	var transcript strings.Builder
+	if previous != "" {
+		transcript.WriteString("Summary so far:\n" + previous + "\n\nNew messages:\n")
+	}
+	for _, msg := range messages {
+		content := msg.Content
+		if msg.Role == api.RoleTool {
+			content = fmt.Sprintf("(result of %s) %s", msg.ToolName, content)
+		}
+		for _, call := range msg.ToolCalls {
+			content += fmt.Sprintf("\n(called %s %v)", call.Function.Name, map[string]interface{}(call.Function.Arguments))
+		}
+		transcript.WriteString(fmt.Sprintf("%s: %s\n\n", msg.Role, content))
+	}
+
+	// Never ask for a summary larger than the context window allows
+	text := trimTranscript(transcript.String(), contextBudget()*3)
+
+	resp, err := client.Chat(ctx, &api.ChatRequest{
+		Model: config.Model,
+		Messages: []api.ChatMessage{
+			{Role: api.RoleSystem, Content: summarySystemPrompt},
+			{Role: api.RoleUser, Content: text},
+		},
+		Options: generationOptions(),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	summary := strings.TrimSpace(resp.Message.Content)
+	if summary == "" {
+		return "", fmt.Errorf("model returned an empty summary")
+	}
+	return summary, nil
+}
+
+// trimTranscript keeps the end of a transcript that is longer than maxChars bytes, starting
+// after a line break, or at least at the start of a character, so no line is cut in half
+func trimTranscript(text string, maxChars int) string {
+	if len(text) <= maxChars {
+		return text
+	}
+	start := len(text) - maxChars
+	if i := strings.IndexByte(text[start:], '\n'); i >= 0 && i < len(text)-start-1 {
+		return text[start+i+1:]
+	}
+	for start < len(text) && !utf8.RuneStart(text[start]) {
+		start++
+	}
+	return text[start:]
+}
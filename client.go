@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/ai-in-pm/Ollama-Code/api"
+)
+
+// Providers selectable with the provider setting
+const (
+	providerOllama = "ollama"
+	providerOpenAI = "openai" // OpenAI-compatible chat completions, e.g. llama.cpp server, vLLM, LM Studio
+)
+
+// The pool of the configured endpoints, shared by all requests of the process
+var (
+	poolOnce sync.Once
+	pool     *api.Pool
+)
+
+// newProvider creates the client of the configured provider
+func newProvider() api.Provider {
+	if config.Provider == providerOpenAI {
+		client := api.NewOpenAIClient(config.ApiURL, config.ApiKey, config.Model)
+		configureClient(&client.Retry, &client.Timeouts, &client.Filter)
+		return client
+	}
+	if p := endpointPool(); p != nil {
+		return p
+	}
+	return newClient()
+}
+
+// endpointPool returns the pool of the configured Ollama endpoints, checked when it is
+// first used and then every health_check_interval seconds, or nil if there is no list
+func endpointPool() *api.Pool {
+	poolOnce.Do(func() {
+		if len(config.Endpoints) == 0 || config.Provider == providerOpenAI {
+			return
+		}
+		endpoints := make([]api.Endpoint, len(config.Endpoints))
+		for i, e := range config.Endpoints {
+			client := api.NewClient(e.URL, config.Model)
+			configureClient(&client.Retry, &client.Timeouts, &client.Filter)
+			endpoints[i] = api.Endpoint{Client: client, Priority: e.Priority}
+		}
+		pool = api.NewPool(endpoints, config.Model)
+		pool.OnFailover = func(from string, to string, err error) {
+			fmt.Fprintf(os.Stderr, "\nWarning: request to %s failed (%v); trying %s\n", from, err, to)
+		}
+		pool.Start(context.Background(), time.Duration(max(config.HealthCheckInterval, 0))*time.Second)
+	})
+	return pool
+}
+
+// newClient creates an Ollama client from the configuration
+func newClient() *api.OllamaClient {
+	client := api.NewClient(config.ApiURL, config.Model)
+	configureClient(&client.Retry, &client.Timeouts, &client.Filter)
+	return client
+}
+
+// ollamaClient returns an Ollama client for the model management only Ollama offers: the
+// endpoint preferred for the configured model if there are several. It is an error if
+// another provider is configured.
+func ollamaClient() (*api.OllamaClient, error) {
+	return ollamaClientFor(config.Model)
+}
+
+// ollamaClientFor is ollamaClient for the endpoint preferred for a given model
+func ollamaClientFor(model string) (*api.OllamaClient, error) {
+	if config.Provider != "" && config.Provider != providerOllama {
+		return nil, fmt.Errorf("managing models needs an Ollama server, but the %s provider is configured", config.Provider)
+	}
+	if p := endpointPool(); p != nil {
+		return p.Client(model)
+	}
+	return newClient(), nil
+}
+
+// configureClient applies the configured retries, timeouts and secret redaction to a client
+func configureClient(retry *api.RetryPolicy, timeouts *api.Timeouts, filter *api.PromptFilter) {
+	retry.MaxRetries = max(config.MaxRetries, 0)
+	if config.RetryBackoffMs > 0 {
+		retry.InitialBackoff = time.Duration(config.RetryBackoffMs) * time.Millisecond
+	}
+	*timeouts = api.Timeouts{
+		Connect:    time.Duration(max(config.ConnectTimeout, 0)) * time.Second,
+		FirstToken: time.Duration(max(config.FirstTokenTimeout, 0)) * time.Second,
+		Idle:       time.Duration(max(config.IdleTimeout, 0)) * time.Second,
+	}
+	if r := secretRedactor(); r != nil {
+		*filter = r.Redact
+	}
+}
+
+// describeError turns client errors into a message with a hint on how to fix them
+func describeError(err error) string {
+	switch {
+	case errors.Is(err, api.ErrCanceled):
+		return "Request canceled"
+	case errors.Is(err, api.ErrConnectionRefused) && config.Provider == providerOpenAI:
+		return fmt.Sprintf("Cannot connect to the server at %s. Start it or set the URL with --api.", config.ApiURL)
+	case errors.Is(err, api.ErrConnectionRefused) && endpointPool() != nil:
+		return "Cannot connect to any of the Ollama endpoints. Check them with 'ollama-code endpoints' or /endpoints."
+	case errors.Is(err, api.ErrConnectionRefused):
+		return fmt.Sprintf("Cannot connect to Ollama at %s. Start it with 'ollama serve' or set the URL with --api.", config.ApiURL)
+	case errors.Is(err, api.ErrModelNotFound) && config.Provider == providerOpenAI:
+		return fmt.Sprintf("Model '%s' is not offered by the server at %s. Choose another with --model or /model.", config.Model, config.ApiURL)
+	case errors.Is(err, api.ErrModelNotFound):
+		return fmt.Sprintf("Model '%s' is not available. Download it with 'ollama-code models pull %s' (/pull in interactive mode) or choose another with /model.", config.Model, config.Model)
+	}
+	return err.Error()
+}
@@ -0,0 +1,459 @@
+package context_manager
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrNotGitRepository is returned when a directory is not inside a git work tree
+var ErrNotGitRepository = errors.New("not a git repository")
+
+// Number of commits touching a file that are included as context
+const gitHistoryCommits = 3
+
+// GitRepo reads the changes and history of a git work tree with the git command
+type GitRepo struct {
+	Root string // Top-level directory of the work tree
+}
+
+// Commit is a commit from the log, with the files it touched or its patch
+type Commit struct {
+	Hash    string
+	Author  string
+	Date    time.Time
+	Subject string
+	Files   []string // Changed files, relative to the work tree root
+	Patch   string   // The diff, when requested
+}
+
+// ShortHash returns the abbreviated commit hash
+func (c Commit) ShortHash() string {
+	if len(c.Hash) > 7 {
+		return c.Hash[:7]
+	}
+	return c.Hash
+}
+
+// BlameLine is a line of a file with the commit that last changed it
+type BlameLine struct {
+	Line    int
+	Hash    string
+	Author  string
+	Date    time.Time
+	Summary string
+	Text    string
+}
+
+// Committed reports whether the line has been committed
+func (b BlameLine) Committed() bool {
+	return strings.Trim(b.Hash, "0") != ""
+}
+
+// OpenGitRepo finds the git work tree containing dir
+func OpenGitRepo(dir string) (*GitRepo, error) {
+	if _, err := exec.LookPath("git"); err != nil {
+		return nil, fmt.Errorf("git is not installed: %w", err)
+	}
+	out, err := runGit(dir, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return nil, ErrNotGitRepository
+	}
+	return &GitRepo{Root: strings.TrimSpace(out)}, nil
+}
+
+// runGit runs a git command in dir and returns its output
+func runGit(dir string, args ...string) (string, error) {
+	cmd := exec.Command("git", append([]string{"-C", dir, "--no-pager", "-c", "core.quotepath=off"}, args...)...)
+	// Reading must not take the index lock from a git command the user is running
+	cmd.Env = append(os.Environ(), "GIT_OPTIONAL_LOCKS=0")
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		message := strings.TrimSpace(stderr.String())
+		if message == "" {
+			message = err.Error()
+		}
+		return "", fmt.Errorf("git %s failed: %s", args[0], message)
+	}
+	return string(out), nil
+}
+
+// git runs a git command at the root of the work tree
+func (g *GitRepo) git(args ...string) (string, error) {
+	return runGit(g.Root, args...)
+}
+
+// hasCommits reports whether HEAD points to a commit, which it doesn't in a new repository
+func (g *GitRepo) hasCommits() bool {
+	_, err := g.git("rev-parse", "--verify", "--quiet", "HEAD")
+	return err == nil
+}
+
+// Diff returns the unstaged changes in the work tree, or the staged changes, limited to
+// paths if any are given
+func (g *GitRepo) Diff(staged bool, paths ...string) (string, error) {
+	args := []string{"diff", "--no-color", "--no-ext-diff"}
+	if staged {
+		args = append(args, "--cached")
+	}
+	return g.git(append(append(args, "--"), paths...)...)
+}
+
+// ChangedFiles returns the files with unstaged changes, or with staged changes, relative to
+// the work tree root; a renamed file is listed under both names
+func (g *GitRepo) ChangedFiles(staged bool) ([]string, error) {
+	args := []string{"diff", "--name-only", "--no-renames", "-z"}
+	if staged {
+		args = append(args, "--cached")
+	}
+	out, err := g.git(args...)
+	if err != nil {
+		return nil, err
+	}
+	return splitNul(out), nil
+}
+
+// RangeDiff returns the changes between two revisions, as in "git diff A..B"
+func (g *GitRepo) RangeDiff(revisions string, paths ...string) (string, error) {
+	args := []string{"diff", "--no-color", "--no-ext-diff", revisions, "--"}
+	return g.git(append(args, paths...)...)
+}
+
+// Show returns a file as it is in a revision, or in the index when revision is empty;
+// path is relative to the work tree root
+func (g *GitRepo) Show(revision string, path string) (string, error) {
+	return g.git("show", revision+":"+filepath.ToSlash(path))
+}
+
+// Log returns the last n commits touching paths (all commits if none are given), newest
+// first, with their patches or with the names of the files they changed
+func (g *GitRepo) Log(n int, patch bool, paths ...string) ([]Commit, error) {
+	if !g.hasCommits() {
+		return nil, nil
+	}
+
+	// Records start with \x1e and fields are separated by \x1f
+	args := []string{"log", "--no-color", "--no-ext-diff", "-n", strconv.Itoa(n), "--format=%x1e%H%x1f%an%x1f%aI%x1f%s"}
+	if patch {
+		args = append(args, "--patch")
+	} else {
+		args = append(args, "--name-only")
+	}
+	if len(paths) == 1 {
+		args = append(args, "--follow")
+	}
+	out, err := g.git(append(append(args, "--"), paths...)...)
+	if err != nil {
+		return nil, err
+	}
+
+	var commits []Commit
+	for _, record := range strings.Split(out, "\x1e") {
+		header, body, _ := strings.Cut(record, "\n")
+		fields := strings.Split(header, "\x1f")
+		if len(fields) != 4 {
+			continue
+		}
+		date, _ := time.Parse(time.RFC3339, fields[2])
+		commit := Commit{Hash: fields[0], Author: fields[1], Date: date, Subject: fields[3]}
+		body = strings.Trim(body, "\n")
+		if patch {
+			commit.Patch = body
+		} else if body != "" {
+			commit.Files = strings.Split(body, "\n")
+		}
+		commits = append(commits, commit)
+	}
+	return commits, nil
+}
+
+// Untracked returns the files that are not tracked and not ignored, relative to the work
+// tree root
+func (g *GitRepo) Untracked() ([]string, error) {
+	out, err := g.git("ls-files", "--others", "--exclude-standard", "-z")
+	if err != nil {
+		return nil, err
+	}
+	return splitNul(out), nil
+}
+
+// splitNul splits the NUL-terminated file names git prints with -z
+func splitNul(out string) []string {
+	var files []string
+	for _, file := range strings.Split(out, "\x00") {
+		if file != "" {
+			files = append(files, file)
+		}
+	}
+	return files
+}
+
+// LiteralPathspec makes git match a path exactly rather than as a glob
+func LiteralPathspec(path string) string {
+	return ":(literal)" + filepath.ToSlash(path)
+}
+
+// Blame returns the lines of a file in the given range with the commit that last changed
+// each of them; uncommitted lines have a hash of zeros
+func (g *GitRepo) Blame(path string, lines LineRange) ([]BlameLine, error) {
+	args := []string{"blame", "--porcelain"}
+	if lines.Start > 0 {
+		args = append(args, "-L", fmt.Sprintf("%d,%d", lines.Start, lines.End))
+	}
+	out, err := g.git(append(args, "--", path)...)
+	if err != nil {
+		return nil, err
+	}
+	return parseBlame(out), nil
+}
+
+// parseBlame reads the lines of git blame --porcelain output
+func parseBlame(out string) []BlameLine {
+	// Commit details are given only the first time a commit appears
+	commits := make(map[string]*BlameLine)
+	var result []BlameLine
+	var current *BlameLine
+	for _, line := range strings.Split(out, "\n") {
+		if current == nil {
+			fields := strings.Fields(line)
+			if len(fields) < 3 || !isObjectName(fields[0]) {
+				continue
+			}
+			n, _ := strconv.Atoi(fields[2])
+			info, ok := commits[fields[0]]
+			if !ok {
+				info = &BlameLine{Hash: fields[0]}
+				commits[fields[0]] = info
+			}
+			current = &BlameLine{Line: n, Hash: fields[0]}
+			continue
+		}
+
+		info := commits[current.Hash]
+		if text, ok := strings.CutPrefix(line, "\t"); ok {
+			current.Author, current.Date, current.Summary = info.Author, info.Date, info.Summary
+			current.Text = text
+			result = append(result, *current)
+			current = nil
+			continue
+		}
+		key, value, _ := strings.Cut(line, " ")
+		switch key {
+		case "author":
+			info.Author = value
+		case "author-time":
+			if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+				info.Date = time.Unix(seconds, 0)
+			}
+		case "summary":
+			info.Summary = value
+		}
+	}
+	return result
+}
+
+// isObjectName reports whether s is a full SHA-1 or SHA-256 object name
+func isObjectName(s string) bool {
+	if len(s) != 40 && len(s) != 64 {
+		return false
+	}
+	for _, c := range s {
+		if !strings.ContainsRune("0123456789abcdef", c) {
+			return false
+		}
+	}
+	return true
+}
+
+// FormatCommits lists commits with their subjects followed by their patches or files
+func FormatCommits(commits []Commit) string {
+	var sb strings.Builder
+	for _, c := range commits {
+		sb.WriteString(fmt.Sprintf("commit %s %s %s: %s\n", c.ShortHash(), c.Date.Format("2006-01-02"), c.Author, c.Subject))
+		if c.Patch != "" {
+			sb.WriteString(c.Patch + "\n\n")
+		}
+		for _, file := range c.Files {
+			sb.WriteString("  " + file + "\n")
+		}
+	}
+	return strings.TrimRight(sb.String(), "\n")
+}
+
+// FormatBlame lists the commits that last changed the lines, then the numbered lines
+// with the commit of each
+func FormatBlame(lines []BlameLine) string {
+	var sb strings.Builder
+	seen := make(map[string]bool)
+	for _, b := range lines {
+		if seen[b.Hash] {
+			continue
+		}
+		seen[b.Hash] = true
+		if b.Committed() {
+			sb.WriteString(fmt.Sprintf("%s %s %s: %s\n", b.Hash[:7], b.Date.Format("2006-01-02"), b.Author, b.Summary))
+		} else {
+			sb.WriteString("0000000 (not committed yet)\n")
+		}
+	}
+	sb.WriteString("\n")
+
+	width := 0
+	if len(lines) > 0 {
+		width = len(fmt.Sprint(lines[len(lines)-1].Line))
+	}
+	for _, b := range lines {
+		sb.WriteString(b.Hash[:7] + " " + numberLine(b.Line, width, b.Text) + "\n")
+	}
+	return strings.TrimRight(sb.String(), "\n")
+}
+
+// Git returns the git work tree of the project
+func (cm *ContextManager) Git() (*GitRepo, error) {
+	return OpenGitRepo(cm.rootPath)
+}
+
+// gitSnippets returns the uncommitted changes and recent commits of a file, and the blame
+// of the focus if there is one. Nothing is returned outside a git work tree.
+func (cm *ContextManager) gitSnippets(filePath string, focus LineRange, focusLabel string) []Snippet {
+	repo, err := cm.Git()
+	if err != nil || cm.ShouldIgnore(filePath) {
+		return nil
+	}
+	relPath, _ := filepath.Rel(cm.rootPath, filePath)
+
+	var snippets []Snippet
+	// The file name may contain glob characters such as [id]
+	pathspec := LiteralPathspec(filePath)
+	if diff, err := repo.Diff(true, pathspec); err == nil && diff != "" {
+		snippets = append(snippets, Snippet{Label: "Staged changes", Path: relPath, Content: strings.TrimRight(diff, "\n"), Priority: PriorityChanges})
+	}
+	if diff, err := repo.Diff(false, pathspec); err == nil && diff != "" {
+		snippets = append(snippets, Snippet{Label: "Unstaged changes", Path: relPath, Content: strings.TrimRight(diff, "\n"), Priority: PriorityChanges})
+	}
+	if focus.Start > 0 {
+		if lines, err := repo.Blame(filePath, focus); err == nil && len(lines) > 0 {
+			label := fmt.Sprintf("%s:%d-%d", relPath, focus.Start, lines[len(lines)-1].Line)
+			if focusLabel != "" {
+				label += " (" + focusLabel + ")"
+			}
+			snippets = append(snippets, Snippet{Label: "Blame", Path: label, Content: FormatBlame(lines), Priority: PriorityChanges})
+		}
+	}
+	if commits, err := repo.Log(gitHistoryCommits, true, pathspec); err == nil && len(commits) > 0 {
+		snippets = append(snippets, Snippet{Label: "Recent commits", Path: relPath, Content: FormatCommits(commits), Priority: PriorityHistory})
+	}
+	return snippets
+}
+
+// ChangesOptions selects what ChangesContext includes
+type ChangesOptions struct {
+	StagedOnly bool // Only the staged changes, as they would be committed
+	Commits    int  // Recent commits to list with the files they changed
+}
+
+// ChangesContext returns the uncommitted changes of the project with the files changed
+// recently, packed into the token budget, for questions about what changed
+func (cm *ContextManager) ChangesContext(opts ChangesOptions) (string, error) {
+	repo, err := cm.Git()
+	if err != nil {
+		return "", err
+	}
+
+	name := filepath.Base(repo.Root)
+	var snippets []Snippet
+	staged, err := cm.visibleDiff(repo, true)
+	if err != nil {
+		return "", err
+	}
+	if staged != "" {
+		snippets = append(snippets, Snippet{Label: "Staged changes", Path: name, Content: strings.TrimRight(staged, "\n"), Priority: PriorityTarget})
+	}
+	if !opts.StagedOnly {
+		unstaged, err := cm.visibleDiff(repo, false)
+		if err != nil {
+			return "", err
+		}
+		if unstaged != "" {
+			snippets = append(snippets, Snippet{Label: "Unstaged changes", Path: name, Content: strings.TrimRight(unstaged, "\n"), Priority: PriorityTarget})
+		}
+	}
+	if !opts.StagedOnly {
+		untracked, err := repo.Untracked()
+		if err != nil {
+			return "", err
+		}
+		untracked = cm.visibleFiles(repo, untracked)
+		if len(untracked) > 0 {
+			snippets = append(snippets, Snippet{Label: "New files not yet added", Path: name, Content: strings.Join(untracked, "\n"), Priority: PriorityRelated})
+		}
+	}
+	if len(snippets) == 0 {
+		snippets = append(snippets, Snippet{Content: "There are no uncommitted changes.", Priority: PriorityTarget})
+	}
+
+	if opts.Commits > 0 {
+		commits, err := repo.Log(opts.Commits, false)
+		if err != nil {
+			return "", err
+		}
+		for i := range commits {
+			commits[i].Files = cm.visibleFiles(repo, commits[i].Files)
+		}
+		if len(commits) > 0 {
+			snippets = append(snippets, Snippet{Label: "Recent commits", Path: name, Content: FormatCommits(commits), Priority: PriorityHistory})
+		}
+	}
+	return cm.pack(snippets), nil
+}
+
+// ShouldIgnoreGitPath is ShouldIgnore for a path relative to the work tree root, as git
+// prints it. The project root may be a subdirectory of the work tree, or reach it through
+// a symlink.
+func (cm *ContextManager) ShouldIgnoreGitPath(repo *GitRepo, file string) bool {
+	root := cm.rootPath
+	if real, err := filepath.EvalSymlinks(root); err == nil {
+		root = real
+	}
+	rel, err := filepath.Rel(root, filepath.Join(repo.Root, filepath.FromSlash(file)))
+	if err != nil {
+		return cm.ShouldIgnore(filepath.Join(repo.Root, filepath.FromSlash(file)))
+	}
+	return cm.ShouldIgnore(filepath.Join(cm.rootPath, rel))
+}
+
+// visibleFiles drops the ignored files from paths relative to the work tree root
+func (cm *ContextManager) visibleFiles(repo *GitRepo, files []string) []string {
+	var visible []string
+	for _, file := range files {
+		if !cm.ShouldIgnoreGitPath(repo, file) {
+			visible = append(visible, file)
+		}
+	}
+	return visible
+}
+
+// visibleDiff returns the unstaged or staged changes to the files that are not ignored
+func (cm *ContextManager) visibleDiff(repo *GitRepo, staged bool) (string, error) {
+	files, err := repo.ChangedFiles(staged)
+	if err != nil {
+		return "", err
+	}
+	files = cm.visibleFiles(repo, files)
+	if len(files) == 0 {
+		return "", nil
+	}
+	pathspecs := make([]string, len(files))
+	for i, file := range files {
+		pathspecs[i] = LiteralPathspec(file)
+	}
+	return repo.Diff(staged, pathspecs...)
+}
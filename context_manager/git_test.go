@@ -0,0 +1,61 @@
+package context_manager
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseBlame(t *testing.T) {
+	sha1 := strings.Repeat("a1", 20)
+	sha256 := strings.Repeat("b2", 32)
+	zeros := strings.Repeat("0", 40)
+	out := sha1 + " 1 1 1\nauthor Ann\nauthor-time 1700000000\nsummary First\nfilename f.go\n\tline one\n" +
+		sha256 + " 2 2 1\nauthor Bob\nauthor-time 1700000000\nsummary Second\nfilename f.go\n\tline two\n" +
+		sha1 + " 3 3 1\n\tline three\n" +
+		zeros + " 4 4 1\nauthor Not Committed Yet\nsummary Version of f.go from f.go\nfilename f.go\n\tline four\n"
+
+	lines := parseBlame(out)
+	want := []struct {
+		line   int
+		hash   string
+		author string
+		text   string
+	}{
+		{1, sha1, "Ann", "line one"},
+		{2, sha256, "Bob", "line two"},
+		{3, sha1, "Ann", "line three"},
+		{4, zeros, "Not Committed Yet", "line four"},
+	}
+	if len(lines) != len(want) {
+		t.Fatalf("parseBlame returned %d lines, want %d: %+v", len(lines), len(want), lines)
+	}
+	for i, w := range want {
+		got := lines[i]
+		if got.Line != w.line || got.Hash != w.hash || got.Author != w.author || got.Text != w.text {
+			t.Errorf("line %d = %+v, want %+v", i, got, w)
+		}
+	}
+	if lines[3].Committed() || !lines[1].Committed() {
+		t.Error("Committed() is wrong")
+	}
+}
+
+func TestIsObjectName(t *testing.T) {
+	tests := []struct {
+		name string
+		want bool
+	}{
+		{strings.Repeat("a", 40), true},
+		{strings.Repeat("0", 64), true},
+		{strings.Repeat("a", 39), false},
+		{strings.Repeat("a", 41), false},
+		{strings.Repeat("A", 40), false},
+		{strings.Repeat("g", 40), false},
+		{"author", false},
+	}
+	for _, tt := range tests {
+		if got := isObjectName(tt.name); got != tt.want {
+			t.Errorf("isObjectName(%q) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,328 @@
+package context_manager
+
+import (
+	"bufio"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// maxFallbackPackageFiles caps the files included from an imported package when none of
+// them defines a symbol the importing file uses (e.g. blank or dot imports)
+const maxFallbackPackageFiles = 3
+
+// goModules maps module paths to the local directories that contain them
+type goModules map[string]string
+
+// findGoModules reads the go.work (or else go.mod) governing dir, including modules used
+// by the workspace and local replace directives
+func findGoModules(dir string) goModules {
+	modules := make(goModules)
+	var modFile string
+	for current := dir; ; {
+		if _, err := os.Stat(filepath.Join(current, "go.work")); err == nil {
+			for _, use := range readGoWorkUses(filepath.Join(current, "go.work")) {
+				modules.addModule(filepath.Join(current, use, "go.mod"))
+			}
+			return modules
+		}
+		if modFile == "" {
+			if _, err := os.Stat(filepath.Join(current, "go.mod")); err == nil {
+				modFile = filepath.Join(current, "go.mod")
+			}
+		}
+		parent := filepath.Dir(current)
+		if parent == current {
+			break
+		}
+		current = parent
+	}
+
+	if modFile != "" {
+		modules.addModule(modFile)
+	}
+	return modules
+}
+
+// addModule registers the module declared in a go.mod file and its local replacements
+func (m goModules) addModule(modFile string) {
+	dir := filepath.Dir(modFile)
+	directives := readGoModDirectives(modFile)
+	for _, args := range directives["module"] {
+		if len(args) > 0 {
+			m[unquote(args[0])] = dir
+		}
+	}
+	for _, args := range directives["replace"] {
+		// old [version] => new [version]
+		arrow := -1
+		for i, arg := range args {
+			if arg == "=>" {
+				arrow = i
+			}
+		}
+		if arrow < 1 || arrow+1 >= len(args) {
+			continue
+		}
+		target := unquote(args[arrow+1])
+		if !strings.HasPrefix(target, "./") && !strings.HasPrefix(target, "../") && !filepath.IsAbs(target) {
+			continue
+		}
+		if !filepath.IsAbs(target) {
+			target = filepath.Join(dir, target)
+		}
+		if _, exists := m[unquote(args[0])]; !exists {
+			m[unquote(args[0])] = target
+		}
+	}
+}
+
+// resolve maps an import path to a local package directory, using the longest matching
+// module path
+func (m goModules) resolve(importPath string) (string, bool) {
+	best := ""
+	for modPath := range m {
+		if (importPath == modPath || strings.HasPrefix(importPath, modPath+"/")) && len(modPath) > len(best) {
+			best = modPath
+		}
+	}
+	if best == "" {
+		return "", false
+	}
+	return filepath.Join(m[best], filepath.FromSlash(strings.TrimPrefix(importPath, best))), true
+}
+
+// readGoModDirectives returns the arguments of each directive in a go.mod or go.work
+// file, expanding parenthesized blocks into one entry per line
+func readGoModDirectives(filePath string) map[string][][]string {
+	directives := make(map[string][][]string)
+	file, err := os.Open(filePath)
+	if err != nil {
+		return directives
+	}
+	defer func() { _ = file.Close() }()
+
+	block := ""
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.Index(line, "//"); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		switch {
+		case block != "" && fields[0] == ")":
+			block = ""
+		case block != "":
+			directives[block] = append(directives[block], fields)
+		case len(fields) == 2 && fields[1] == "(":
+			block = fields[0]
+		default:
+			directives[fields[0]] = append(directives[fields[0]], fields[1:])
+		}
+	}
+	return directives
+}
+
+// readGoWorkUses returns the module directories listed in a go.work file
+func readGoWorkUses(filePath string) []string {
+	var uses []string
+	for _, args := range readGoModDirectives(filePath)["use"] {
+		if len(args) > 0 {
+			uses = append(uses, unquote(args[0]))
+		}
+	}
+	return uses
+}
+
+// unquote removes the quotes around a go.mod string, if any
+func unquote(s string) string {
+	if unquoted, err := strconv.Unquote(s); err == nil {
+		return unquoted
+	}
+	return s
+}
+
+// findGoRelatedFiles returns the files of the module-local packages a Go file imports and
+// of its own package that define the symbols the file uses, most relevant first
+func findGoRelatedFiles(filePath, content string) []string {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, filePath, content, parser.SkipObjectResolution)
+	if err != nil && file == nil {
+		return nil
+	}
+
+	// Qualified references (pkg.Name) by package name, and every other identifier used
+	qualified := make(map[string]map[string]bool)
+	used := make(map[string]bool)
+	selectors := make(map[*ast.Ident]bool)
+	ast.Inspect(file, func(n ast.Node) bool {
+		switch node := n.(type) {
+		case *ast.SelectorExpr:
+			selectors[node.Sel] = true
+			if ident, ok := node.X.(*ast.Ident); ok {
+				if qualified[ident.Name] == nil {
+					qualified[ident.Name] = make(map[string]bool)
+				}
+				qualified[ident.Name][node.Sel.Name] = true
+			}
+		case *ast.Ident:
+			if !selectors[node] {
+				used[node.Name] = true
+			}
+		}
+		return true
+	})
+
+	var related []string
+	modules := findGoModules(filepath.Dir(filePath))
+	for _, spec := range file.Imports {
+		importPath, err := strconv.Unquote(spec.Path.Value)
+		if err != nil {
+			continue
+		}
+		dir, ok := modules.resolve(importPath)
+		if !ok {
+			continue
+		}
+
+		files := parsePackageDir(dir, "")
+		name := packageName(files, importPath)
+		if spec.Name != nil {
+			name = spec.Name.Name
+		}
+		symbols := qualified[name]
+		if name == "." {
+			symbols = used
+		}
+		related = append(related, rankByDefinedSymbols(files, symbols, maxFallbackPackageFiles)...)
+	}
+
+	// Files of the same package that define identifiers this file uses
+	siblings := parsePackageDir(filepath.Dir(filePath), filePath)
+	related = append(related, rankByDefinedSymbols(siblings, used, 0)...)
+	return related
+}
+
+// goFile is a parsed file with its top-level declarations
+type goFile struct {
+	path    string
+	pkg     string
+	defined map[string]bool
+}
+
+// parsePackageDir parses the non-test Go files in dir, except skip
+func parsePackageDir(dir string, skip string) []goFile {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+
+	var files []goFile
+	fset := token.NewFileSet()
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || filepath.Ext(name) != ".go" || strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+		filePath := filepath.Join(dir, name)
+		if filePath == skip {
+			continue
+		}
+		file, err := parser.ParseFile(fset, filePath, nil, parser.SkipObjectResolution)
+		if err != nil && file == nil {
+			continue
+		}
+		files = append(files, goFile{path: filePath, pkg: file.Name.Name, defined: topLevelNames(file)})
+	}
+	return files
+}
+
+// topLevelNames returns the names of the functions, types, variables and constants
+// declared at the top level of a file
+func topLevelNames(file *ast.File) map[string]bool {
+	names := make(map[string]bool)
+	for _, decl := range file.Decls {
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			if d.Recv == nil {
+				names[d.Name.Name] = true
+			}
+		case *ast.GenDecl:
+			for _, spec := range d.Specs {
+				switch s := spec.(type) {
+				case *ast.TypeSpec:
+					names[s.Name.Name] = true
+				case *ast.ValueSpec:
+					for _, name := range s.Names {
+						names[name.Name] = true
+					}
+				}
+			}
+		}
+	}
+	return names
+}
+
+// packageName returns the package clause shared by the files, or the last element of
+// the import path, ignoring a major version suffix
+func packageName(files []goFile, importPath string) string {
+	for _, f := range files {
+		if f.pkg != "" && f.pkg != "main" {
+			return f.pkg
+		}
+	}
+	name := path.Base(importPath)
+	if strings.HasPrefix(name, "v") && len(name) > 1 {
+		if _, err := strconv.Atoi(name[1:]); err == nil {
+			name = path.Base(path.Dir(importPath))
+		}
+	}
+	return name
+}
+
+// rankByDefinedSymbols orders files by how many of the symbols they define, dropping
+// files that define none; if no file defines any, up to fallback files are returned
+func rankByDefinedSymbols(files []goFile, symbols map[string]bool, fallback int) []string {
+	type ranked struct {
+		path  string
+		count int
+	}
+	var matches []ranked
+	for _, f := range files {
+		count := 0
+		for symbol := range symbols {
+			if f.defined[symbol] {
+				count++
+			}
+		}
+		if count > 0 {
+			matches = append(matches, ranked{path: f.path, count: count})
+		}
+	}
+
+	if len(matches) == 0 {
+		var paths []string
+		for i := 0; i < len(files) && i < fallback; i++ {
+			paths = append(paths, files[i].path)
+		}
+		return paths
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].count > matches[j].count })
+	paths := make([]string, len(matches))
+	for i, m := range matches {
+		paths[i] = m.path
+	}
+	return paths
+}
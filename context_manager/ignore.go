@@ -0,0 +1,262 @@
+package context_manager
+
+import (
+	"bufio"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// OllamaIgnoreFile is a project-level file with gitignore syntax listing paths that must
+// never be sent to the model. Its rules take precedence over .gitignore files.
+const OllamaIgnoreFile = ".ollamaignore"
+
+// ignoreRule is a single gitignore pattern
+type ignoreRule struct {
+	base    string // Slash-separated directory of the file that defined the rule, "" for the root
+	negate  bool
+	dirOnly bool
+	re      *regexp.Regexp
+}
+
+// ignoreMatcher applies gitignore semantics to paths below a root: nested .gitignore
+// files, .git/info/exclude, the project's .ollamaignore and patterns added at runtime
+type ignoreMatcher struct {
+	root    string
+	mutex   sync.Mutex
+	dirs    map[string][]ignoreRule // .gitignore rules by slash-separated directory
+	exclude []ignoreRule            // Rules from .git/info/exclude
+	project []ignoreRule            // Rules from .ollamaignore
+	extra   []ignoreRule            // Patterns added with AddIgnorePattern
+	loaded  bool
+	ignored map[string]bool // Cached decisions for directories
+}
+
+// newIgnoreMatcher creates a matcher for the given project root
+func newIgnoreMatcher(root string) *ignoreMatcher {
+	return &ignoreMatcher{
+		root:    root,
+		dirs:    make(map[string][]ignoreRule),
+		ignored: make(map[string]bool),
+	}
+}
+
+// add appends a root-level pattern with the highest precedence
+func (m *ignoreMatcher) add(pattern string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if rule, ok := parseIgnoreRule(pattern, ""); ok {
+		m.extra = append(m.extra, rule)
+		m.ignored = make(map[string]bool)
+	}
+}
+
+// excluded reports whether a path below the root is ignored, either directly or because
+// one of its parent directories is
+func (m *ignoreMatcher) excluded(absPath string, isDir bool) bool {
+	rel, err := filepath.Rel(m.root, absPath)
+	if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+		return false
+	}
+	rel = filepath.ToSlash(rel)
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.loadProject()
+
+	// A file inside an excluded directory can't be re-included
+	parts := strings.Split(rel, "/")
+	for i := 1; i < len(parts); i++ {
+		if m.ignoredDir(strings.Join(parts[:i], "/")) {
+			return true
+		}
+	}
+	if isDir {
+		return m.ignoredDir(rel)
+	}
+	return m.match(rel, false)
+}
+
+// ignoredDir returns the cached decision for a directory
+func (m *ignoreMatcher) ignoredDir(rel string) bool {
+	if ignored, ok := m.ignored[rel]; ok {
+		return ignored
+	}
+	ignored := m.match(rel, true)
+	m.ignored[rel] = ignored
+	return ignored
+}
+
+// match evaluates the rules that apply to rel; the last matching rule wins
+func (m *ignoreMatcher) match(rel string, isDir bool) bool {
+	ignored := false
+	apply := func(rules []ignoreRule) {
+		for _, rule := range rules {
+			if rule.matches(rel, isDir) {
+				ignored = !rule.negate
+			}
+		}
+	}
+
+	// Rules from .git/info/exclude, then .gitignore files from the root down, then the
+	// .ollamaignore file and runtime patterns, in increasing order of precedence
+	apply(m.exclude)
+	dir := ""
+	apply(m.dirRules(dir))
+	for _, part := range strings.Split(path.Dir(rel), "/") {
+		if part == "." {
+			break
+		}
+		dir = path.Join(dir, part)
+		apply(m.dirRules(dir))
+	}
+	apply(m.project)
+	apply(m.extra)
+	return ignored
+}
+
+// loadProject reads .git/info/exclude and .ollamaignore once
+func (m *ignoreMatcher) loadProject() {
+	if m.loaded {
+		return
+	}
+	m.loaded = true
+	m.exclude = readIgnoreFile(filepath.Join(m.root, ".git", "info", "exclude"), "")
+	m.project = readIgnoreFile(filepath.Join(m.root, OllamaIgnoreFile), "")
+}
+
+// dirRules returns the .gitignore rules of a directory, reading the file on first use
+func (m *ignoreMatcher) dirRules(dir string) []ignoreRule {
+	rules, ok := m.dirs[dir]
+	if !ok {
+		rules = readIgnoreFile(filepath.Join(m.root, filepath.FromSlash(dir), ".gitignore"), dir)
+		m.dirs[dir] = rules
+	}
+	return rules
+}
+
+// readIgnoreFile parses a gitignore-style file, returning no rules if it doesn't exist
+func readIgnoreFile(filePath string, base string) []ignoreRule {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil
+	}
+	defer func() { _ = file.Close() }()
+
+	var rules []ignoreRule
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if rule, ok := parseIgnoreRule(scanner.Text(), base); ok {
+			rules = append(rules, rule)
+		}
+	}
+	return rules
+}
+
+// parseIgnoreRule parses one line of a gitignore file
+func parseIgnoreRule(line string, base string) (ignoreRule, bool) {
+	line = strings.TrimSuffix(line, "\r")
+	// Trailing spaces are ignored unless escaped
+	for strings.HasSuffix(line, " ") && !strings.HasSuffix(line, "\\ ") {
+		line = line[:len(line)-1]
+	}
+	if line == "" || strings.HasPrefix(line, "#") {
+		return ignoreRule{}, false
+	}
+
+	rule := ignoreRule{base: base}
+	if strings.HasPrefix(line, "!") {
+		rule.negate = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, "\\!") || strings.HasPrefix(line, "\\#") {
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		rule.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	if line == "" {
+		return ignoreRule{}, false
+	}
+
+	// A slash anywhere but at the end anchors the pattern to the file's directory
+	anchored := strings.Contains(line, "/")
+	line = strings.TrimPrefix(line, "/")
+
+	expr := globToRegexp(line)
+	if !anchored {
+		expr = "(?:.*/)?" + expr
+	}
+	re, err := regexp.Compile("^" + expr + "$")
+	if err != nil {
+		return ignoreRule{}, false
+	}
+	rule.re = re
+	return rule, true
+}
+
+// globToRegexp converts a gitignore glob into a regular expression
+func globToRegexp(glob string) string {
+	var sb strings.Builder
+	for i := 0; i < len(glob); i++ {
+		c := glob[i]
+		switch {
+		case c == '*' && strings.HasPrefix(glob[i:], "**"):
+			atStart := i == 0 || glob[i-1] == '/'
+			atEnd := i+2 == len(glob)
+			switch {
+			case atStart && i+2 < len(glob) && glob[i+2] == '/':
+				// "**/" matches zero or more directories
+				sb.WriteString("(?:.*/)?")
+				i += 2
+			case atStart && atEnd:
+				// A trailing "/**" matches everything inside
+				sb.WriteString(".*")
+				i++
+			default:
+				// Elsewhere "**" is an ordinary "*"
+				sb.WriteString("[^/]*")
+				i++
+			}
+		case c == '*':
+			sb.WriteString("[^/]*")
+		case c == '?':
+			sb.WriteString("[^/]")
+		case c == '[':
+			end := strings.IndexByte(glob[i+1:], ']')
+			if end < 0 {
+				sb.WriteString(`\[`)
+				continue
+			}
+			class := glob[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			sb.WriteString("[" + strings.ReplaceAll(class, `\`, `\\`) + "]")
+			i += end + 1
+		case c == '\\' && i+1 < len(glob):
+			i++
+			sb.WriteString(regexp.QuoteMeta(string(glob[i])))
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	return sb.String()
+}
+
+// matches reports whether the rule applies to a slash-separated path relative to the root
+func (r ignoreRule) matches(rel string, isDir bool) bool {
+	if r.dirOnly && !isDir {
+		return false
+	}
+	if r.base != "" {
+		if !strings.HasPrefix(rel, r.base+"/") {
+			return false
+		}
+		rel = strings.TrimPrefix(rel, r.base+"/")
+	}
+	return r.re.MatchString(rel)
+}
@@ -0,0 +1,216 @@
+package context_manager
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestIgnoreRuleMatches(t *testing.T) {
+	tests := []struct {
+		pattern string
+		path    string
+		isDir   bool
+		want    bool
+	}{
+		// Unanchored patterns match at any depth
+		{"*.log", "debug.log", false, true},
+		{"*.log", "a/b/debug.log", false, true},
+		{"*.log", "debug.log.txt", false, false},
+		{"secrets.yaml", "config/secrets.yaml", false, true},
+
+		// A slash anchors the pattern to the directory of the ignore file
+		{"/build", "build", true, true},
+		{"/build", "src/build", true, false},
+		{"config/*.yaml", "config/app.yaml", false, true},
+		{"config/*.yaml", "src/config/app.yaml", false, false},
+
+		// "*" and "?" don't cross directories
+		{"src/*.go", "src/a/b.go", false, false},
+		{"file?.txt", "file1.txt", false, true},
+		{"file?.txt", "file/.txt", false, false},
+
+		// "**"
+		{"**/logs", "logs", true, true},
+		{"**/logs", "a/b/logs", true, true},
+		{"a/**/b", "a/b", false, true},
+		{"a/**/b", "a/x/y/b", false, true},
+		{"a/**", "a/x/y", false, true},
+		{"a/**", "b/a/x", false, false},
+		{"a**b", "axxb", false, true},
+		{"a**b", "ax/xb", false, false},
+
+		// A trailing slash matches directories only
+		{"tmp/", "tmp", true, true},
+		{"tmp/", "tmp", false, false},
+
+		// Character classes and escapes
+		{"[abc].txt", "b.txt", false, true},
+		{"[!abc].txt", "b.txt", false, false},
+		{"[!abc].txt", "d.txt", false, true},
+		{`\#notes`, "#notes", false, true},
+		{`\!important`, "!important", false, true},
+		{"a.b", "axb", false, false},
+	}
+	for _, tt := range tests {
+		rule, ok := parseIgnoreRule(tt.pattern, "")
+		if !ok {
+			t.Errorf("parseIgnoreRule(%q) failed", tt.pattern)
+			continue
+		}
+		if got := rule.matches(tt.path, tt.isDir); got != tt.want {
+			t.Errorf("%q matches %q (dir %v) = %v, want %v", tt.pattern, tt.path, tt.isDir, got, tt.want)
+		}
+	}
+}
+
+func TestParseIgnoreRuleSkipsBlankAndComments(t *testing.T) {
+	for _, line := range []string{"", "   ", "# comment", "/", "!"} {
+		if _, ok := parseIgnoreRule(line, ""); ok {
+			t.Errorf("parseIgnoreRule(%q) returned a rule", line)
+		}
+	}
+}
+
+// writeFiles creates files with their content below root
+func writeFiles(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestIgnoreMatcherExcluded(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		".gitignore":          "*.log\n!keep.log\nbuild/\n/out\n",
+		"src/.gitignore":      "generated.go\n!important.log\n",
+		".git/info/exclude":   "local.txt\n",
+		OllamaIgnoreFile:      "secrets.yaml\n!build/\ncerts/**\n",
+		"debug.log":           "",
+		"keep.log":            "",
+		"src/generated.go":    "",
+		"src/important.log":   "",
+		"src/main.go":         "",
+		"build/app":           "",
+		"sub/out":             "",
+		"out":                 "",
+		"local.txt":           "",
+		"config/secrets.yaml": "",
+		"certs/a/key.pem":     "",
+		"generated.go":        "",
+	})
+
+	tests := []struct {
+		path  string
+		isDir bool
+		want  bool
+	}{
+		{"debug.log", false, true},
+		{"keep.log", false, false},           // Negated in the same file
+		{"src/generated.go", false, true},    // Nested .gitignore
+		{"generated.go", false, false},       // Nested rules don't apply above their directory
+		{"src/important.log", false, false},  // Nested negation overrides the root file
+		{"src/main.go", false, false},        // Not matched by anything
+		{"build", true, false},               // .ollamaignore takes precedence over .gitignore
+		{"out", false, true},                 // Anchored
+		{"sub/out", false, false},            // Anchored patterns don't match deeper
+		{"local.txt", false, true},           // .git/info/exclude
+		{"config/secrets.yaml", false, true}, // .ollamaignore
+		{"certs/a/key.pem", false, true},     // Everything inside with "/**"
+	}
+	m := newIgnoreMatcher(root)
+	for _, tt := range tests {
+		if got := m.excluded(filepath.Join(root, filepath.FromSlash(tt.path)), tt.isDir); got != tt.want {
+			t.Errorf("excluded(%q) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestIgnoreMatcherExcludedDirectoryCannotBeReincluded(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		".gitignore":        "vendor/\n!vendor/keep.go\n",
+		"vendor/keep.go":    "",
+		"vendor/a/other.go": "",
+	})
+
+	m := newIgnoreMatcher(root)
+	for _, path := range []string{"vendor/keep.go", "vendor/a/other.go"} {
+		if !m.excluded(filepath.Join(root, filepath.FromSlash(path)), false) {
+			t.Errorf("excluded(%q) = false, want true", path)
+		}
+	}
+}
+
+func TestIgnoreMatcherRuntimePatterns(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		".gitignore": "*.tmp\n",
+		"a.tmp":      "",
+		"b.txt":      "",
+	})
+
+	m := newIgnoreMatcher(root)
+	if m.excluded(filepath.Join(root, "b.txt"), false) {
+		t.Fatal("b.txt excluded before adding a pattern")
+	}
+	m.add("*.txt")
+	m.add("!a.tmp")
+	if !m.excluded(filepath.Join(root, "b.txt"), false) {
+		t.Error("b.txt not excluded by an added pattern")
+	}
+	if m.excluded(filepath.Join(root, "a.tmp"), false) {
+		t.Error("a.tmp excluded despite an added negation")
+	}
+}
+
+func TestIgnoreMatcherOutsideRoot(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{".gitignore": "*\n"})
+
+	m := newIgnoreMatcher(root)
+	if m.excluded(root, true) {
+		t.Error("the root itself is excluded")
+	}
+	if m.excluded(filepath.Join(filepath.Dir(root), "elsewhere.txt"), false) {
+		t.Error("a path outside the root is excluded")
+	}
+}
+
+func TestShouldIgnore(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		OllamaIgnoreFile:         "secrets.yaml\n",
+		"secrets.yaml":           "",
+		"main.go":                "",
+		"node_modules/pkg/a.js":  "",
+		"app.log":                "",
+		"src/node_modules_notes": "",
+	})
+
+	cm := NewContextManager(root)
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{"secrets.yaml", true},
+		{"main.go", false},
+		{"node_modules", true},
+		{"node_modules/pkg/a.js", true}, // Inside a built-in directory, for paths not found by walking
+		{"app.log", true},               // Built-in file pattern
+		{"src/node_modules_notes", false},
+		{"deleted/secrets.yaml", true}, // Paths that no longer exist, as in a diff, are matched as files
+	}
+	for _, tt := range tests {
+		if got := cm.ShouldIgnore(filepath.Join(root, filepath.FromSlash(tt.path))); got != tt.want {
+			t.Errorf("ShouldIgnore(%q) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,307 @@
+package context_manager
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+// BM25 parameters and result limits
+const (
+	bm25K1           = 1.2
+	bm25B            = 0.75
+	maxMatchLines    = 5   // Matching lines reported per file
+	maxMatchLineLen  = 200 // Longer matching lines are cut
+	matchRangeMargin = 3   // Matches closer than this are merged into one range
+)
+
+// LineMatch is a line of a file that contains query terms
+type LineMatch struct {
+	Line int // 1-based line number
+	Text string
+}
+
+// lexicalDoc is the indexed form of a file
+type lexicalDoc struct {
+	modTime int64
+	size    int64
+	length  int
+	terms   map[string]int
+}
+
+// LexicalIndex is an in-memory inverted index over the project's files, ranked with BM25.
+// It needs no model and is refreshed by file modification time before every search.
+type LexicalIndex struct {
+	cm       *ContextManager
+	mutex    sync.Mutex
+	docs     map[string]*lexicalDoc    // Keyed by absolute path
+	postings map[string]map[string]int // Term to path to term frequency
+	totalLen int
+}
+
+// newLexicalIndex creates an empty index for the manager's project
+func newLexicalIndex(cm *ContextManager) *LexicalIndex {
+	return &LexicalIndex{
+		cm:       cm,
+		docs:     make(map[string]*lexicalDoc),
+		postings: make(map[string]map[string]int),
+	}
+}
+
+// Tokenize splits text into lowercase search terms. Identifiers are split at camelCase
+// and snake_case boundaries, and compound identifiers are also kept whole, so that
+// "parseHTTPHeader" yields "parsehttpheader", "parse", "http" and "header".
+func Tokenize(text string) []string {
+	var tokens []string
+	for _, word := range strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_'
+	}) {
+		var parts []string
+		for _, piece := range strings.Split(word, "_") {
+			parts = append(parts, splitCamelCase(piece)...)
+		}
+
+		whole := strings.ToLower(strings.ReplaceAll(word, "_", ""))
+		if len(parts) > 1 && len(whole) >= 2 {
+			tokens = append(tokens, whole)
+		}
+		for _, part := range parts {
+			if len(part) >= 2 {
+				tokens = append(tokens, strings.ToLower(part))
+			}
+		}
+	}
+	return tokens
+}
+
+// splitCamelCase splits an identifier at lower-to-upper transitions and at the end of
+// an acronym ("HTTPServer" becomes "HTTP", "Server")
+func splitCamelCase(word string) []string {
+	runes := []rune(word)
+	var parts []string
+	start := 0
+	for i := 1; i < len(runes); i++ {
+		prev, cur := runes[i-1], runes[i]
+		boundary := unicode.IsLower(prev) && unicode.IsUpper(cur) ||
+			unicode.IsUpper(prev) && unicode.IsUpper(cur) && i+1 < len(runes) && unicode.IsLower(runes[i+1])
+		if boundary {
+			parts = append(parts, string(runes[start:i]))
+			start = i
+		}
+	}
+	if start < len(runes) {
+		parts = append(parts, string(runes[start:]))
+	}
+	return parts
+}
+
+// Refresh indexes new and modified files and drops deleted ones
+func (li *LexicalIndex) Refresh() error {
+	li.mutex.Lock()
+	defer li.mutex.Unlock()
+
+	seen := make(map[string]bool)
+	err := li.cm.walkFiles(func(path string, info os.FileInfo) error {
+		if info.Size() > maxIndexedBytes {
+			return nil
+		}
+		seen[path] = true
+		if doc, ok := li.docs[path]; ok && doc.modTime == info.ModTime().UnixNano() && doc.size == info.Size() {
+			return nil
+		}
+
+		li.remove(path)
+		content, err := li.cm.GetFileContent(path)
+		if err != nil || !isText(content) {
+			return nil
+		}
+
+		// Path components count as terms so that file names match too
+		relPath, _ := filepath.Rel(li.cm.rootPath, path)
+		doc := &lexicalDoc{modTime: info.ModTime().UnixNano(), size: info.Size(), terms: make(map[string]int)}
+		for _, token := range append(Tokenize(relPath), Tokenize(content)...) {
+			doc.terms[token]++
+			doc.length++
+		}
+		li.add(path, doc)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for path := range li.docs {
+		if !seen[path] {
+			li.remove(path)
+		}
+	}
+	return nil
+}
+
+// add inserts a document into the postings
+func (li *LexicalIndex) add(path string, doc *lexicalDoc) {
+	li.docs[path] = doc
+	li.totalLen += doc.length
+	for term, tf := range doc.terms {
+		if li.postings[term] == nil {
+			li.postings[term] = make(map[string]int)
+		}
+		li.postings[term][path] = tf
+	}
+}
+
+// remove deletes a document from the postings, if present
+func (li *LexicalIndex) remove(path string) {
+	doc, ok := li.docs[path]
+	if !ok {
+		return
+	}
+	for term := range doc.terms {
+		delete(li.postings[term], path)
+		if len(li.postings[term]) == 0 {
+			delete(li.postings, term)
+		}
+	}
+	li.totalLen -= doc.length
+	delete(li.docs, path)
+}
+
+// Search refreshes the index and returns the files that best match the query by BM25,
+// with the lines that contain query terms
+func (li *LexicalIndex) Search(query string, maxFiles int) ([]RelevantFile, error) {
+	if err := li.Refresh(); err != nil {
+		return nil, err
+	}
+
+	terms := uniqueTerms(Tokenize(query))
+	if len(terms) == 0 {
+		return nil, nil
+	}
+
+	li.mutex.Lock()
+	scores := make(map[string]float64)
+	if n := len(li.docs); n > 0 {
+		avgLen := float64(li.totalLen) / float64(n)
+		for _, term := range terms {
+			postings := li.postings[term]
+			df := float64(len(postings))
+			if df == 0 {
+				continue
+			}
+			idf := math.Log(1 + (float64(n)-df+0.5)/(df+0.5))
+			for path, tf := range postings {
+				norm := 1 - bm25B + bm25B*float64(li.docs[path].length)/avgLen
+				scores[path] += idf * float64(tf) * (bm25K1 + 1) / (float64(tf) + bm25K1*norm)
+			}
+		}
+	}
+	li.mutex.Unlock()
+
+	results := make([]RelevantFile, 0, len(scores))
+	for path, score := range scores {
+		results = append(results, RelevantFile{Path: path, Score: score})
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].Path < results[j].Path
+	})
+	if len(results) > maxFiles {
+		results = results[:maxFiles]
+	}
+
+	for i := range results {
+		content, err := li.cm.GetFileContent(results[i].Path)
+		if err != nil {
+			continue
+		}
+		results[i].Matches = matchingLines(content, terms)
+		results[i].Ranges = matchRanges(results[i].Matches)
+	}
+	return results, nil
+}
+
+// uniqueTerms removes duplicate terms, keeping their order
+func uniqueTerms(terms []string) []string {
+	seen := make(map[string]bool)
+	var unique []string
+	for _, term := range terms {
+		if !seen[term] {
+			seen[term] = true
+			unique = append(unique, term)
+		}
+	}
+	return unique
+}
+
+// matchingLines returns the lines containing the most distinct query terms, in file order
+func matchingLines(content string, terms []string) []LineMatch {
+	wanted := make(map[string]bool, len(terms))
+	for _, term := range terms {
+		wanted[term] = true
+	}
+
+	type scored struct {
+		match LineMatch
+		hits  int
+	}
+	var candidates []scored
+	for i, line := range strings.Split(content, "\n") {
+		hits := 0
+		for _, term := range uniqueTerms(Tokenize(line)) {
+			if wanted[term] {
+				hits++
+			}
+		}
+		if hits == 0 {
+			continue
+		}
+		text := strings.TrimSpace(line)
+		if len(text) > maxMatchLineLen {
+			text = truncateUTF8(text, maxMatchLineLen) + "..."
+		}
+		candidates = append(candidates, scored{match: LineMatch{Line: i + 1, Text: text}, hits: hits})
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].hits > candidates[j].hits })
+	if len(candidates) > maxMatchLines {
+		candidates = candidates[:maxMatchLines]
+	}
+	sort.Slice(candidates, func(i, j int) bool { return candidates[i].match.Line < candidates[j].match.Line })
+
+	matches := make([]LineMatch, len(candidates))
+	for i, c := range candidates {
+		matches[i] = c.match
+	}
+	return matches
+}
+
+// matchRanges merges nearby matching lines into line ranges
+func matchRanges(matches []LineMatch) []LineRange {
+	var ranges []LineRange
+	for _, m := range matches {
+		if n := len(ranges); n > 0 && m.Line-ranges[n-1].End <= matchRangeMargin {
+			ranges[n-1].End = m.Line
+			continue
+		}
+		ranges = append(ranges, LineRange{Start: m.Line, End: m.Line})
+	}
+	return ranges
+}
+
+// truncateUTF8 cuts text to at most n bytes without splitting a character
+func truncateUTF8(text string, n int) string {
+	if len(text) <= n {
+		return text
+	}
+	for n > 0 && !utf8.RuneStart(text[n]) {
+		n--
+	}
+	return text[:n]
+}
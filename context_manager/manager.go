@@ -1,6 +1,7 @@
 package context_manager
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -10,14 +11,18 @@ import (
 
 // ContextManager handles file and project context for more intelligent responses
 type ContextManager struct {
-	rootPath      string
-	fileCache     map[string]string
-	fileMtimes    map[string]int64
-	mutex         sync.RWMutex
-	ignoreDirs    []string
-	ignoreFiles   []string
-	maxContextLen int
-	isKaliLinux   bool
+	rootPath    string
+	fileCache   map[string]string
+	fileMtimes  map[string]int64
+	mutex       sync.RWMutex
+	ignoreDirs  []string
+	ignoreFiles []string
+	tokenBudget int
+	isKaliLinux bool
+	ignore      *ignoreMatcher
+	semantic    *SemanticIndex
+	lexical     *LexicalIndex
+	symbols     *SymbolIndex
 }
 
 // NewContextManager creates a new context manager for the given root directory
@@ -33,7 +38,7 @@ func NewContextManager(rootPath string) *ContextManager {
 
 	// Default dirs to ignore
 	ignoreDirs := []string{
-		".git", "node_modules", "__pycache__", "venv", ".env", ".venv",
+		".git", "node_modules", "__pycache__", "venv", ".env", ".venv", IndexDir,
 	}
 
 	// Add Kali-specific directories to ignore list
@@ -44,35 +49,57 @@ func NewContextManager(rootPath string) *ContextManager {
 		ignoreDirs = append(ignoreDirs, kaliSpecificDirs...)
 	}
 
-	return &ContextManager{
-		rootPath:      rootPath,
-		fileCache:     make(map[string]string),
-		fileMtimes:    make(map[string]int64),
-		ignoreDirs:    ignoreDirs,
-		ignoreFiles:   []string{".DS_Store", "*.pyc", "*.o", "*.out", "*.log"},
-		maxContextLen: 16384, // Default max context size
-		isKaliLinux:   isKali,
+	cm := &ContextManager{
+		rootPath:    rootPath,
+		fileCache:   make(map[string]string),
+		fileMtimes:  make(map[string]int64),
+		ignoreDirs:  ignoreDirs,
+		ignoreFiles: []string{".DS_Store", "*.pyc", "*.o", "*.out", "*.log"},
+		tokenBudget: 4096, // Default context budget in tokens
+		isKaliLinux: isKali,
+		ignore:      newIgnoreMatcher(rootPath),
 	}
+	cm.lexical = newLexicalIndex(cm)
+	cm.symbols = newSymbolIndex(cm)
+	return cm
 }
 
 // SetMaxContextLength sets the maximum number of characters to include in context
 func (cm *ContextManager) SetMaxContextLength(maxLen int) {
+	cm.SetTokenBudget(maxLen / 4)
+}
+
+// SetTokenBudget sets the number of tokens file context may take up
+func (cm *ContextManager) SetTokenBudget(tokens int) {
 	cm.mutex.Lock()
 	defer cm.mutex.Unlock()
-	cm.maxContextLen = maxLen
+	cm.tokenBudget = tokens
 }
 
-// AddIgnorePattern adds a pattern to the ignore list
-func (cm *ContextManager) AddIgnorePattern(pattern string) {
+// EnableSemanticSearch makes GetRelevantFiles rank files with an embedding model, using
+// an index stored under IndexDir in the project root
+func (cm *ContextManager) EnableSemanticSearch(model string, embed EmbedFunc) {
 	cm.mutex.Lock()
 	defer cm.mutex.Unlock()
+	cm.semantic = newSemanticIndex(cm, model, embed)
+}
 
-	// Determine if it's a directory or file pattern
-	if strings.HasSuffix(pattern, "/") {
-		cm.ignoreDirs = append(cm.ignoreDirs, strings.TrimSuffix(pattern, "/"))
-	} else {
-		cm.ignoreFiles = append(cm.ignoreFiles, pattern)
-	}
+// SemanticIndex returns the semantic index, or nil if semantic search is disabled
+func (cm *ContextManager) SemanticIndex() *SemanticIndex {
+	cm.mutex.RLock()
+	defer cm.mutex.RUnlock()
+	return cm.semantic
+}
+
+// Symbols returns the index of the declarations in the project's source files
+func (cm *ContextManager) Symbols() *SymbolIndex {
+	return cm.symbols
+}
+
+// AddIgnorePattern adds a gitignore-style pattern, relative to the project root, that
+// takes precedence over the project's ignore files
+func (cm *ContextManager) AddIgnorePattern(pattern string) {
+	cm.ignore.add(pattern)
 }
 
 // GetFileContent reads a file and caches its content
@@ -110,23 +137,32 @@ func (cm *ContextManager) GetFileContent(filePath string) (string, error) {
 	return content, nil
 }
 
-// ShouldIgnore checks if a file or directory should be ignored
+// ShouldIgnore checks if a file or directory should be ignored, because of the built-in
+// lists, a .gitignore file, .git/info/exclude or the project's .ollamaignore
 func (cm *ContextManager) ShouldIgnore(path string) bool {
 	// Get the base name of the path
 	base := filepath.Base(path)
 
+	// A path that doesn't exist, such as a file deleted in a diff, is matched as a file
 	info, err := os.Stat(path)
-	if err != nil {
-		return false
-	}
+	isDir := err == nil && info.IsDir()
 
-	if info.IsDir() {
-		for _, ignoreDir := range cm.ignoreDirs {
-			if base == ignoreDir {
-				return true
+	// Files inside the built-in directories are ignored too, for paths not found by walking
+	if rel, err := filepath.Rel(cm.rootPath, path); err == nil && !strings.HasPrefix(rel, "..") {
+		parts := strings.Split(rel, string(os.PathSeparator))
+		if !isDir {
+			parts = parts[:len(parts)-1]
+		}
+		for _, part := range parts {
+			for _, ignoreDir := range cm.ignoreDirs {
+				if part == ignoreDir {
+					return true
+				}
 			}
 		}
-	} else {
+	}
+
+	if !isDir {
 		for _, ignorePattern := range cm.ignoreFiles {
 			matched, err := filepath.Match(ignorePattern, base)
 			if err == nil && matched {
@@ -135,7 +171,7 @@ func (cm *ContextManager) ShouldIgnore(path string) bool {
 		}
 	}
 
-	return false
+	return cm.ignore.excluded(path, isDir)
 }
 
 // GetProjectStructure returns a tree representation of the project structure
@@ -186,89 +222,154 @@ func (cm *ContextManager) GetProjectStructure() (string, error) {
 	return result.String(), nil
 }
 
-// GetRelevantFiles finds files that may be relevant to the given query
-func (cm *ContextManager) GetRelevantFiles(query string, maxFiles int) ([]string, error) {
-	var relevantFiles []string
-
-	err := filepath.Walk(cm.rootPath, func(path string, info os.FileInfo, err error) error {
+// walkFiles calls fn for every regular file in the project that is not ignored
+func (cm *ContextManager) walkFiles(fn func(path string, info os.FileInfo) error) error {
+	return filepath.Walk(cm.rootPath, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return nil // Skip files we can't access
 		}
 
-		// Skip directories
 		if info.IsDir() {
-			if cm.ShouldIgnore(path) {
+			if path != cm.rootPath && cm.ShouldIgnore(path) {
 				return filepath.SkipDir
 			}
 			return nil
 		}
-
-		// Skip ignored files
-		if cm.ShouldIgnore(path) {
+		if !info.Mode().IsRegular() || cm.ShouldIgnore(path) {
 			return nil
 		}
-
-		// For now, use simple substring matching to find relevant files
-		// This could be replaced with more sophisticated techniques like TF-IDF or embeddings
-		relPath, _ := filepath.Rel(cm.rootPath, path)
-		if strings.Contains(strings.ToLower(relPath), strings.ToLower(query)) {
-			relevantFiles = append(relevantFiles, path)
-		}
-
-		// Limit the number of files
-		if len(relevantFiles) >= maxFiles {
-			return filepath.SkipDir
-		}
-
-		return nil
+		return fn(path, info)
 	})
+}
+
+// GetRelevantFiles finds the files most relevant to the given query, best first. With
+// semantic search enabled, files are ranked by embedding similarity and include the
+// line ranges that matched; otherwise they are ranked by keyword relevance (BM25).
+func (cm *ContextManager) GetRelevantFiles(ctx context.Context, query string, maxFiles int) ([]RelevantFile, error) {
+	if semantic := cm.SemanticIndex(); semantic != nil {
+		return semantic.Search(ctx, query, maxFiles)
+	}
+	return cm.lexical.Search(query, maxFiles)
+}
 
-	return relevantFiles, err
+// SearchKeywords ranks files by keyword relevance to the query, without an embedding model
+func (cm *ContextManager) SearchKeywords(query string, maxFiles int) ([]RelevantFile, error) {
+	return cm.lexical.Search(query, maxFiles)
 }
 
 // GetFileContext returns context information about a file and its related files
 func (cm *ContextManager) GetFileContext(filePath string) (string, error) {
-	var context strings.Builder
+	return cm.PackFileContext(filePath, PackOptions{Related: true})
+}
 
-	// Get the content of the main file
-	content, err := cm.GetFileContent(filePath)
-	if err != nil {
-		return "", err
+// PackOptions selects what PackFileContext includes besides the file
+type PackOptions struct {
+	Related    bool      // The files the file depends on and its tests
+	Structure  bool      // The project tree
+	Git        bool      // Uncommitted changes and recent commits of the file, and blame of the focus
+	Focus      LineRange // Lines sent first, numbered, with the whole file as context; zero for none
+	FocusLabel string    // Describes the focus, e.g. "function main"
+	Content    string    // Used instead of the file on disk when set, e.g. a version from git
+}
+
+// PackFileContext returns a file together with the context selected by opts, packed into
+// the token budget by priority. Files that don't fit whole are cut down to the
+// declarations the target uses or to their first lines.
+func (cm *ContextManager) PackFileContext(filePath string, opts PackOptions) (string, error) {
+	content := opts.Content
+	if content == "" {
+		var err error
+		if content, err = cm.GetFileContent(filePath); err != nil {
+			return "", err
+		}
 	}
 
-	// Add the file content to context
+	var snippets []Snippet
+	if opts.Structure {
+		structure, err := cm.GetProjectStructure()
+		if err != nil {
+			return "", err
+		}
+		snippets = append(snippets, Snippet{Content: structure, Priority: PriorityStructure})
+	}
+
+	// Declarations of other files are kept when the target refers to them
+	keep := sourceIdentifiers(filePath, content)
+
 	relPath, _ := filepath.Rel(cm.rootPath, filePath)
-	context.WriteString(fmt.Sprintf("File: %s\n\n```\n%s\n```\n\n", relPath, content))
+	if opts.Focus.Start > 0 {
+		lines := strings.Split(content, "\n")
+		start, end := opts.Focus.Start, min(opts.Focus.End, len(lines))
+		if start > end {
+			return "", fmt.Errorf("line %d is past the end of %s (%d lines)", start, relPath, len(lines))
+		}
+		focus := strings.Join(lines[start-1:end], "\n")
+		opts.Focus.End = end
+
+		label := fmt.Sprintf("%s:%d-%d", relPath, start, end)
+		if opts.FocusLabel != "" {
+			label += " (" + opts.FocusLabel + ")"
+		}
+		snippets = append(snippets, Snippet{Label: "Focus", Path: label, Content: focus, Priority: PriorityFocus, Line: start})
+
+		// The rest of the file and related files are cut down to what the focus uses
+		keep = sourceIdentifiers(filePath, focus)
+		snippets = append(snippets, Snippet{Label: "Surrounding file", Path: relPath, Content: content, Priority: PriorityTarget, Keep: keep, Line: 1})
+	} else {
+		snippets = append(snippets, Snippet{Label: "File", Path: relPath, Content: content, Priority: PriorityTarget})
+	}
+
+	if opts.Git {
+		snippets = append(snippets, cm.gitSnippets(filePath, opts.Focus, opts.FocusLabel)...)
+	}
 
-	// Get imports or dependencies from this file
-	relatedFiles := cm.findRelatedFiles(filePath, content)
+	if !opts.Related {
+		return cm.pack(snippets), nil
+	}
 
-	// Add related files to context
-	totalLength := context.Len()
-	for _, relatedFile := range relatedFiles {
-		relatedContent, err := cm.GetFileContent(relatedFile)
+	for _, related := range cm.findRelatedFiles(filePath, content) {
+		if cm.ShouldIgnore(related.path) {
+			continue
+		}
+		relatedContent, err := cm.GetFileContent(related.path)
 		if err != nil {
 			continue
 		}
+		relPath, _ = filepath.Rel(cm.rootPath, related.path)
+		snippets = append(snippets, Snippet{Label: "Related file", Path: relPath, Content: relatedContent, Priority: related.priority, Keep: keep})
+	}
 
-		relPath, _ = filepath.Rel(cm.rootPath, relatedFile)
-		fileContext := fmt.Sprintf("Related file: %s\n\n```\n%s\n```\n\n", relPath, relatedContent)
-
-		// Check if adding this would exceed the context limit
-		if totalLength+len(fileContext) > cm.maxContextLen {
-			context.WriteString(fmt.Sprintf("(Additional related file %s not included due to context length limits)\n", relPath))
+	for _, test := range findTestFiles(filePath) {
+		if cm.ShouldIgnore(test) {
 			continue
 		}
-
-		context.WriteString(fileContext)
-		totalLength += len(fileContext)
+		testContent, err := cm.GetFileContent(test)
+		if err != nil {
+			continue
+		}
+		relPath, _ = filepath.Rel(cm.rootPath, test)
+		snippets = append(snippets, Snippet{Label: "Test file", Path: relPath, Content: testContent, Priority: PriorityTests})
 	}
 
-	return context.String(), nil
+	return cm.pack(snippets), nil
+}
+
+// pack fits snippets into the token budget
+func (cm *ContextManager) pack(snippets []Snippet) string {
+	cm.mutex.RLock()
+	budget := cm.tokenBudget
+	cm.mutex.RUnlock()
+	return NewPacker(budget).Pack(snippets)
+}
+
+// relatedFile is a file related to the target, with the priority it is packed at
+type relatedFile struct {
+	path     string
+	priority int
 }
 
 // findRelatedFiles attempts to find files related to the given file
-func (cm *ContextManager) findRelatedFiles(filePath, content string) []string {
+func (cm *ContextManager) findRelatedFiles(filePath, content string) []relatedFile {
 	var relatedFiles []string
 	ext := filepath.Ext(filePath)
 	dir := filepath.Dir(filePath)
@@ -276,39 +377,8 @@ func (cm *ContextManager) findRelatedFiles(filePath, content string) []string {
 	// Different strategies based on language
 	switch ext {
 	case ".go":
-		// Find imports in Go files
-		for _, line := range strings.Split(content, "\n") {
-			line = strings.TrimSpace(line)
-			if strings.HasPrefix(line, "import ") || (strings.HasPrefix(line, "import(") && !strings.HasSuffix(line, ")")) {
-				if strings.HasPrefix(line, "import (") {
-					continue
-				}
-
-				importPath := ""
-				if strings.HasPrefix(line, "import \"") {
-					// Single line import
-					importPath = strings.Trim(strings.TrimPrefix(line, "import "), "\"")
-
-					// For local imports
-					if strings.HasPrefix(importPath, "./") || strings.HasPrefix(importPath, "../") {
-						resolvedPath := filepath.Join(dir, importPath)
-						if info, err := os.Stat(resolvedPath); err == nil && !info.IsDir() {
-							relatedFiles = append(relatedFiles, resolvedPath)
-						} else if err == nil && info.IsDir() {
-							// It's a directory, look for .go files
-							entries, err := os.ReadDir(resolvedPath)
-							if err == nil {
-								for _, entry := range entries {
-									if !entry.IsDir() && filepath.Ext(entry.Name()) == ".go" {
-										relatedFiles = append(relatedFiles, filepath.Join(resolvedPath, entry.Name()))
-									}
-								}
-							}
-						}
-					}
-				}
-			}
-		}
+		// Resolve module-local imports through go.mod/go.work and same-package references
+		relatedFiles = append(relatedFiles, findGoRelatedFiles(filePath, content)...)
 
 	case ".js", ".ts":
 		// Find imports in JS/TS files
@@ -387,6 +457,9 @@ func (cm *ContextManager) findRelatedFiles(filePath, content string) []string {
 		}
 	}
 
+	// Imported files define symbols the target uses; the rest are looked up by name
+	imported := len(relatedFiles)
+
 	// Also look for files with the same base name but different extensions
 	baseName := filepath.Base(filePath)
 	baseWithoutExt := strings.TrimSuffix(baseName, ext)
@@ -407,5 +480,61 @@ func (cm *ContextManager) findRelatedFiles(filePath, content string) []string {
 		}
 	}
 
-	return relatedFiles
+	// Drop duplicates, keeping the first (most relevant) occurrence
+	seen := make(map[string]bool)
+	var unique []relatedFile
+	for i, path := range relatedFiles {
+		if seen[path] {
+			continue
+		}
+		seen[path] = true
+		priority := PriorityRelated
+		if i < imported {
+			priority = PrioritySymbols
+		}
+		unique = append(unique, relatedFile{path: path, priority: priority})
+	}
+	return unique
+}
+
+// findTestFiles returns the existing test files of a source file, following the naming
+// conventions of Go, Python and JavaScript/TypeScript
+func findTestFiles(filePath string) []string {
+	dir := filepath.Dir(filePath)
+	ext := filepath.Ext(filePath)
+	base := strings.TrimSuffix(filepath.Base(filePath), ext)
+
+	var candidates []string
+	switch ext {
+	case ".go":
+		if !strings.HasSuffix(base, "_test") {
+			candidates = append(candidates, filepath.Join(dir, base+"_test.go"))
+		}
+	case ".py":
+		if !strings.HasPrefix(base, "test_") && !strings.HasSuffix(base, "_test") {
+			candidates = append(candidates,
+				filepath.Join(dir, "test_"+base+".py"),
+				filepath.Join(dir, base+"_test.py"),
+				filepath.Join(dir, "tests", "test_"+base+".py"),
+				filepath.Join(filepath.Dir(dir), "tests", "test_"+base+".py"),
+			)
+		}
+	case ".js", ".ts", ".jsx", ".tsx":
+		if !strings.HasSuffix(base, ".test") && !strings.HasSuffix(base, ".spec") {
+			for _, suffix := range []string{".test", ".spec"} {
+				candidates = append(candidates,
+					filepath.Join(dir, base+suffix+ext),
+					filepath.Join(dir, "__tests__", base+suffix+ext),
+				)
+			}
+		}
+	}
+
+	var tests []string
+	for _, candidate := range candidates {
+		if info, err := os.Stat(candidate); err == nil && !info.IsDir() {
+			tests = append(tests, candidate)
+		}
+	}
+	return tests
 }
@@ -0,0 +1,375 @@
+package context_manager
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/scanner"
+	"go/token"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/ai-in-pm/Ollama-Code/api"
+)
+
+// Snippet priorities; higher priorities are packed first
+const (
+	PriorityFocus     = 120 // The lines of the file the user asked about
+	PriorityTarget    = 100 // The file the user asked about
+	PriorityChanges   = 90  // Uncommitted changes to the target and blame of the focus
+	PrioritySymbols   = 80  // Files defining symbols the target uses
+	PriorityRelated   = 60  // Other related files
+	PriorityHistory   = 50  // Recent commits touching the target
+	PriorityTests     = 40  // Tests of the target
+	PriorityStructure = 20  // The project tree
+)
+
+// minSnippetTokens is the smallest remainder worth filling with a shortened snippet
+const minSnippetTokens = 200
+
+// Snippet is a candidate piece of context
+type Snippet struct {
+	Label    string // "File", "Related file", ...; empty for text that is included as is
+	Path     string // Path shown after the label
+	Content  string
+	Priority int
+	Keep     map[string]bool // For source files, the declarations to keep when shortening
+	Line     int             // Number the lines from here, as in the file; 0 for no numbers
+}
+
+// render formats a snippet for the prompt
+func (s Snippet) render(content string) string {
+	if s.Label == "" {
+		return content + "\n"
+	}
+	return fmt.Sprintf("%s: %s\n\n```\n%s\n```\n\n", s.Label, s.Path, content)
+}
+
+// Packer fits snippets into a token budget by priority, shortening those that don't fit
+type Packer struct {
+	Budget int // Tokens available for the context
+}
+
+// NewPacker creates a packer with the given token budget
+func NewPacker(budget int) *Packer {
+	return &Packer{Budget: budget}
+}
+
+// Pack selects snippets by priority until the budget is spent and returns them in their
+// original order. A snippet that doesn't fit is cut down to the declarations listed in
+// Keep, or else to its first lines; snippets that still don't fit are listed as omitted.
+// The snippet with the highest priority is always included.
+func (p *Packer) Pack(snippets []Snippet) string {
+	order := make([]int, len(snippets))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool { return snippets[order[a]].Priority > snippets[order[b]].Priority })
+
+	rendered := make([]string, len(snippets))
+	var omitted []string
+	remaining := p.Budget
+	for n, i := range order {
+		s := snippets[i]
+		text := s.render(NumberLines(s.Content, s.Line))
+		cost := api.EstimateTokens(text)
+
+		if cost > remaining && len(s.Keep) > 0 {
+			if shortened, ok := keepDeclarations(s.Path, s.Content, s.Keep, s.Line); ok {
+				text = s.render(shortened)
+				cost = api.EstimateTokens(text)
+			}
+		}
+		// The first snippet is always included, however little of it fits
+		if cost > remaining && (remaining >= minSnippetTokens || n == 0) {
+			overhead := api.EstimateTokens(s.render(""))
+			text = s.render(truncateToTokens(s.Content, remaining-overhead, s.Line))
+			cost = api.EstimateTokens(text)
+		}
+		if cost > remaining && n > 0 {
+			if s.Path != "" {
+				omitted = append(omitted, s.Path)
+			}
+			continue
+		}
+
+		rendered[i] = text
+		remaining -= cost
+	}
+
+	var sb strings.Builder
+	for _, text := range rendered {
+		sb.WriteString(text)
+	}
+	for _, path := range omitted {
+		sb.WriteString(fmt.Sprintf("(Additional related file %s not included due to context length limits)\n", path))
+	}
+	return sb.String()
+}
+
+// NumberLines prefixes each line with its line number, counting from first; with first
+// 0 the content is returned unchanged
+func NumberLines(content string, first int) string {
+	if first <= 0 {
+		return content
+	}
+	lines := strings.Split(content, "\n")
+	width := len(fmt.Sprint(first + len(lines) - 1))
+	for i, line := range lines {
+		lines[i] = numberLine(first+i, width, line)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// numberLine formats a numbered line
+func numberLine(n int, width int, line string) string {
+	return fmt.Sprintf("%*d| %s", width, n, line)
+}
+
+// truncateToTokens keeps as many whole leading lines as fit in the token budget,
+// numbering them from first when first is not 0
+func truncateToTokens(content string, tokens int, first int) string {
+	numbered := NumberLines(content, first)
+	if api.EstimateTokens(numbered) <= tokens {
+		return numbered
+	}
+	lines := strings.Split(numbered, "\n")
+	used := 0
+	for i, line := range lines {
+		used += api.EstimateTokens(line + "\n")
+		if used > tokens {
+			return strings.Join(lines[:i], "\n") + fmt.Sprintf("\n... (%d more lines truncated)", len(lines)-i)
+		}
+	}
+	return numbered
+}
+
+// keepDeclarations shortens source to the declarations named in keep, using go/ast for Go
+// and the ranges of the symbol parsers for the other languages they support
+func keepDeclarations(path, content string, keep map[string]bool, first int) (string, bool) {
+	if strings.ToLower(filepath.Ext(path)) == ".go" {
+		return keepGoDeclarations(content, keep, first)
+	}
+	return keepSymbols(path, content, keep, first)
+}
+
+// keepGoDeclarations shortens Go source to its package clause, imports and the top-level
+// declarations named in keep (methods are kept when their name and receiver type are),
+// marking what was left out. Kept lines are numbered from first when first is not 0.
+func keepGoDeclarations(content string, keep map[string]bool, first int) (string, bool) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "", content, parser.ParseComments|parser.SkipObjectResolution)
+	if err != nil {
+		return "", false
+	}
+
+	lines := strings.Split(content, "\n")
+	line := func(pos token.Pos) int { return fset.Position(pos).Line }
+
+	var sb strings.Builder
+	next := 1 // First line not yet written
+	skipped := 0
+	flushSkipped := func() {
+		if skipped > 0 {
+			sb.WriteString(fmt.Sprintf("// ... (%d lines omitted)\n", skipped))
+			skipped = 0
+		}
+	}
+	width := len(fmt.Sprint(first + len(lines) - 1))
+	write := func(from, to int) {
+		for n := from; n <= to && n <= len(lines); n++ {
+			if first > 0 {
+				sb.WriteString(numberLine(first+n-1, width, lines[n-1]) + "\n")
+			} else {
+				sb.WriteString(lines[n-1] + "\n")
+			}
+		}
+	}
+
+	kept := 0
+	for _, decl := range file.Decls {
+		start, end := line(decl.Pos()), line(decl.End())
+		// Doc comments belong to their declaration
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			if d.Doc != nil {
+				start = line(d.Doc.Pos())
+			}
+		case *ast.GenDecl:
+			if d.Doc != nil {
+				start = line(d.Doc.Pos())
+			}
+		}
+
+		if start > next {
+			if declWanted(decl, keep) || next == 1 {
+				// The package clause, imports and blank lines before a kept declaration stay
+				flushSkipped()
+				write(next, start-1)
+			} else {
+				skipped += start - next
+			}
+		}
+		if declWanted(decl, keep) {
+			flushSkipped()
+			write(start, end)
+			kept++
+		} else {
+			skipped += end - start + 1
+		}
+		next = end + 1
+	}
+	if next <= len(lines) {
+		skipped += len(lines) - next + 1
+	}
+	flushSkipped()
+
+	if kept == 0 {
+		return "", false
+	}
+	return strings.TrimRight(sb.String(), "\n"), true
+}
+
+// keepSymbols shortens non-Go source to the lines before its first declaration (usually
+// imports or includes) and the declarations named in keep; a member is kept when its
+// parent's name is in keep too. Kept lines are numbered from first when first is not 0.
+func keepSymbols(path, content string, keep map[string]bool, first int) (string, bool) {
+	symbols := ExtractSymbols(path, content)
+	if len(symbols) == 0 {
+		return "", false
+	}
+
+	lines := strings.Split(content, "\n")
+	wanted := make([]bool, len(lines)+1) // Indexed by line number
+	firstDecl := len(lines) + 1
+	kept := 0
+	for _, sym := range symbols {
+		firstDecl = min(firstDecl, sym.Start)
+		if !keep[sym.Name] || (sym.Parent != "" && !keep[sym.Parent]) {
+			continue
+		}
+		for n := sym.Start; n <= sym.End && n <= len(lines); n++ {
+			wanted[n] = true
+		}
+		kept++
+	}
+	if kept == 0 {
+		return "", false
+	}
+	for n := 1; n < firstDecl; n++ {
+		wanted[n] = true
+	}
+
+	comment := "//"
+	if strings.ToLower(filepath.Ext(path)) == ".py" {
+		comment = "#"
+	}
+	var sb strings.Builder
+	width := len(fmt.Sprint(first + len(lines) - 1))
+	skipped := 0
+	for n := 1; n <= len(lines); n++ {
+		if !wanted[n] {
+			skipped++
+			continue
+		}
+		if skipped > 0 {
+			sb.WriteString(fmt.Sprintf("%s ... (%d lines omitted)\n", comment, skipped))
+			skipped = 0
+		}
+		if first > 0 {
+			sb.WriteString(numberLine(first+n-1, width, lines[n-1]) + "\n")
+		} else {
+			sb.WriteString(lines[n-1] + "\n")
+		}
+	}
+	if skipped > 0 {
+		sb.WriteString(fmt.Sprintf("%s ... (%d lines omitted)\n", comment, skipped))
+	}
+	return strings.TrimRight(sb.String(), "\n"), true
+}
+
+// declWanted reports whether a declaration is an import or defines a name in keep
+func declWanted(decl ast.Decl, keep map[string]bool) bool {
+	switch d := decl.(type) {
+	case *ast.FuncDecl:
+		if keep[d.Name.Name] && d.Recv == nil {
+			return true
+		}
+		if d.Recv != nil && len(d.Recv.List) > 0 {
+			return keep[d.Name.Name] && keep[receiverType(d.Recv.List[0].Type)]
+		}
+	case *ast.GenDecl:
+		if d.Tok == token.IMPORT {
+			return true
+		}
+		for _, spec := range d.Specs {
+			switch s := spec.(type) {
+			case *ast.TypeSpec:
+				if keep[s.Name.Name] {
+					return true
+				}
+			case *ast.ValueSpec:
+				for _, name := range s.Names {
+					if keep[name.Name] {
+						return true
+					}
+				}
+			}
+		}
+	}
+	return false
+}
+
+// receiverType returns the type name of a method receiver
+func receiverType(expr ast.Expr) string {
+	switch t := expr.(type) {
+	case *ast.StarExpr:
+		return receiverType(t.X)
+	case *ast.IndexExpr:
+		return receiverType(t.X)
+	case *ast.IndexListExpr:
+		return receiverType(t.X)
+	case *ast.Ident:
+		return t.Name
+	}
+	return ""
+}
+
+// sourceIdentifiers returns the identifiers in a slice of a source file, which decide the
+// declarations kept when a file is shortened; nil if the language has no symbol parser
+func sourceIdentifiers(path, src string) map[string]bool {
+	if !SupportsSymbols(path) {
+		return nil
+	}
+	if strings.ToLower(filepath.Ext(path)) == ".go" {
+		return goIdentifiers(src)
+	}
+	names := make(map[string]bool)
+	for _, name := range identifierPattern.FindAllString(src, -1) {
+		names[name] = true
+	}
+	return names
+}
+
+// identifierPattern matches identifiers in the C-like languages and Python
+var identifierPattern = regexp.MustCompile(`[A-Za-z_$][A-Za-z0-9_$]*`)
+
+// goIdentifiers returns every identifier in Go source, which may be any slice of a file,
+// used to decide which declarations of other files matter
+func goIdentifiers(src string) map[string]bool {
+	names := make(map[string]bool)
+	fset := token.NewFileSet()
+	var s scanner.Scanner
+	s.Init(fset.AddFile("", fset.Base(), len(src)), []byte(src), nil, 0)
+	for {
+		_, tok, lit := s.Scan()
+		if tok == token.EOF {
+			return names
+		}
+		if tok == token.IDENT {
+			names[lit] = true
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package context_manager
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPackKeepsOriginalOrder(t *testing.T) {
+	p := NewPacker(10000)
+	got := p.Pack([]Snippet{
+		{Content: "Project: demo", Priority: PriorityStructure},
+		{Label: "File", Path: "main.go", Content: "package main", Priority: PriorityTarget},
+		{Label: "Related file", Path: "util.go", Content: "package util", Priority: PriorityRelated},
+	})
+	want := "Project: demo\n" +
+		"File: main.go\n\n```\npackage main\n```\n\n" +
+		"Related file: util.go\n\n```\npackage util\n```\n\n"
+	if got != want {
+		t.Errorf("Pack = %q, want %q", got, want)
+	}
+}
+
+func TestPackOmitsLowPrioritySnippets(t *testing.T) {
+	big := strings.Repeat("xxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxx\n", 100)
+	p := NewPacker(150)
+	got := p.Pack([]Snippet{
+		{Label: "Related file", Path: "big.go", Content: big, Priority: PriorityRelated},
+		{Label: "File", Path: "main.go", Content: "package main", Priority: PriorityTarget},
+	})
+	if strings.Contains(got, big[:100]) {
+		t.Error("a snippet over the remaining budget was included")
+	}
+	if !strings.Contains(got, "File: main.go") {
+		t.Errorf("the target is missing: %q", got)
+	}
+	if !strings.Contains(got, "(Additional related file big.go not included due to context length limits)") {
+		t.Errorf("the omitted file is not listed: %q", got)
+	}
+}
+
+func TestPackTruncatesToTheBudget(t *testing.T) {
+	var lines []string
+	for i := 0; i < 200; i++ {
+		lines = append(lines, "line of the target file")
+	}
+	p := NewPacker(100)
+	got := p.Pack([]Snippet{{Label: "File", Path: "main.go", Content: strings.Join(lines, "\n"), Priority: PriorityTarget, Line: 1}})
+	if !strings.Contains(got, "  1| line of the target file\n") {
+		t.Errorf("the first lines are missing or not numbered: %q", got)
+	}
+	if !strings.Contains(got, "more lines truncated)") {
+		t.Errorf("the truncation is not marked: %q", got)
+	}
+	if tokens := len(got) / 4; tokens > 110 {
+		t.Errorf("packed about %d tokens into a budget of 100", tokens)
+	}
+}
+
+func TestPackKeepsNeededGoDeclarations(t *testing.T) {
+	src := `package util
+
+import "strings"
+
+// Used is needed by the target
+func Used() string {
+	return strings.ToUpper("used")
+}
+
+// Unused is not
+func Unused() string {
+` + strings.Repeat("\t_ = 1\n", 300) + `	return "unused"
+}
+
+type Server struct{}
+
+// Start is a method of a kept type
+func (s *Server) Start() {}
+`
+	p := NewPacker(300)
+	got := p.Pack([]Snippet{
+		{Label: "File", Path: "main.go", Content: "package main", Priority: PriorityTarget},
+		{
+			Label:    "Related file",
+			Path:     "util.go",
+			Content:  src,
+			Priority: PrioritySymbols,
+			Keep:     map[string]bool{"Used": true, "Server": true, "Start": true},
+		},
+	})
+	for _, want := range []string{"import \"strings\"", "// Used is needed by the target", "func Used() string {", "type Server struct{}", "func (s *Server) Start() {}", "lines omitted)"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("%q missing from %q", want, got)
+		}
+	}
+	if strings.Contains(got, "func Unused") {
+		t.Errorf("an unneeded declaration was kept: %q", got)
+	}
+}
+
+func TestKeepGoDeclarations(t *testing.T) {
+	src := "package p\n\nfunc A() {}\n\nfunc B() {}\n\nfunc (t T) A() {}"
+	tests := []struct {
+		keep map[string]bool
+		want string
+		ok   bool
+	}{
+		{map[string]bool{"A": true}, "package p\n\nfunc A() {}\n// ... (4 lines omitted)", true},
+		{map[string]bool{"A": true, "T": true}, "package p\n\nfunc A() {}\n// ... (2 lines omitted)\n\nfunc (t T) A() {}", true},
+		{map[string]bool{"C": true}, "", false},
+	}
+	for _, tt := range tests {
+		got, ok := keepGoDeclarations(src, tt.keep, 0)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("keepGoDeclarations(%v) = %q, %v, want %q, %v", tt.keep, got, ok, tt.want, tt.ok)
+		}
+	}
+
+	if _, ok := keepGoDeclarations("not go", map[string]bool{"A": true}, 0); ok {
+		t.Error("keepGoDeclarations accepted invalid source")
+	}
+}
+
+func TestKeepSymbols(t *testing.T) {
+	py := "import os\n\ndef a():\n    return 1\n\nclass B:\n    def c(self):\n        pass\n\ndef d():\n    pass"
+	js := "import x from 'x';\n\nfunction a() {\n  return 1;\n}\n\nfunction b() {\n  return 2;\n}"
+	tests := []struct {
+		path string
+		src  string
+		keep map[string]bool
+		want string
+		ok   bool
+	}{
+		{"m.py", py, map[string]bool{"d": true}, "import os\n\n# ... (7 lines omitted)\ndef d():\n    pass", true},
+		{"m.py", py, map[string]bool{"c": true}, "", false}, // A method without its class
+		{"m.py", py, map[string]bool{"B": true, "c": true}, "import os\n\n# ... (3 lines omitted)\nclass B:\n    def c(self):\n        pass\n# ... (3 lines omitted)", true},
+		{"m.js", js, map[string]bool{"a": true}, "import x from 'x';\n\nfunction a() {\n  return 1;\n}\n// ... (4 lines omitted)", true},
+		{"m.js", js, map[string]bool{"z": true}, "", false},
+		{"notes.txt", "a\nb", map[string]bool{"a": true}, "", false},
+	}
+	for _, tt := range tests {
+		got, ok := keepDeclarations(tt.path, tt.src, tt.keep, 0)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("keepDeclarations(%s, %v) = %q, %v, want %q, %v", tt.path, tt.keep, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestNumberLines(t *testing.T) {
+	tests := []struct {
+		content string
+		first   int
+		want    string
+	}{
+		{"a\nb", 0, "a\nb"},
+		{"a\nb", 1, "1| a\n2| b"},
+		{"a\nb", 9, " 9| a\n10| b"},
+	}
+	for _, tt := range tests {
+		if got := NumberLines(tt.content, tt.first); got != tt.want {
+			t.Errorf("NumberLines(%q, %d) = %q, want %q", tt.content, tt.first, got, tt.want)
+		}
+	}
+}
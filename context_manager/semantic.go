@@ -0,0 +1,337 @@
+package context_manager
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Chunking and indexing limits
+const (
+	chunkLines      = 40      // Lines per chunk
+	chunkOverlap    = 8       // Lines shared by consecutive chunks
+	maxChunkChars   = 4000    // Longer chunks are cut before embedding
+	maxIndexedBytes = 1 << 20 // Larger files are not indexed
+	embedBatchSize  = 32      // Chunks sent per embedding request
+
+	// Only chunks scoring close to a file's best chunk are reported as ranges
+	maxRangesPerFile = 3
+	rangeScoreRatio  = 0.9
+)
+
+// indexVersion changes whenever the on-disk format or chunking changes
+const indexVersion = 1
+
+// IndexDir is the directory, relative to the project root, that holds the semantic index
+const IndexDir = ".ollama-code"
+
+// EmbedFunc returns one embedding vector per text
+type EmbedFunc func(ctx context.Context, texts []string) ([][]float32, error)
+
+// LineRange is an inclusive, 1-based range of lines
+type LineRange struct {
+	Start int
+	End   int
+}
+
+// RelevantFile is a file ranked by its relevance to a query
+type RelevantFile struct {
+	Path    string      // Absolute path
+	Score   float64     // Higher is more relevant; only comparable within one search
+	Ranges  []LineRange // The most relevant parts of the file
+	Matches []LineMatch // Lines containing query terms (keyword search only)
+}
+
+// chunk is an embedded part of a file
+type chunk struct {
+	Start  int
+	End    int
+	Vector []float32 // Normalized to unit length
+}
+
+// indexedFile holds the chunks of a file as of its last modification time
+type indexedFile struct {
+	ModTime int64
+	Size    int64
+	Chunks  []chunk
+}
+
+// indexData is the persisted form of a SemanticIndex
+type indexData struct {
+	Version int
+	Model   string
+	Files   map[string]*indexedFile // Keyed by slash-separated path relative to the root
+}
+
+// SemanticIndex is a chunk-level vector index of a project, stored under IndexDir and
+// refreshed incrementally by file modification time
+type SemanticIndex struct {
+	cm    *ContextManager
+	path  string
+	model string
+	embed EmbedFunc
+	mutex sync.Mutex
+	data  *indexData
+}
+
+// newSemanticIndex creates an index for the manager's project; it is loaded lazily
+func newSemanticIndex(cm *ContextManager, model string, embed EmbedFunc) *SemanticIndex {
+	return &SemanticIndex{
+		cm:    cm,
+		path:  filepath.Join(cm.rootPath, IndexDir, "index-"+sanitizeModelName(model)+".gob"),
+		model: model,
+		embed: embed,
+	}
+}
+
+// sanitizeModelName makes a model name safe to use in a file name
+func sanitizeModelName(model string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '.', r == '-':
+			return r
+		}
+		return '_'
+	}, model)
+}
+
+// load reads the index from disk, starting empty if it is missing, outdated or built
+// with another model
+func (si *SemanticIndex) load() {
+	if si.data != nil {
+		return
+	}
+	si.data = &indexData{Version: indexVersion, Model: si.model, Files: make(map[string]*indexedFile)}
+
+	raw, err := os.ReadFile(si.path)
+	if err != nil {
+		return
+	}
+	var data indexData
+	if err := gob.NewDecoder(bytes.NewReader(raw)).Decode(&data); err != nil {
+		return
+	}
+	if data.Version == indexVersion && data.Model == si.model && data.Files != nil {
+		si.data = &data
+	}
+}
+
+// save writes the index to disk atomically
+func (si *SemanticIndex) save() error {
+	dir := filepath.Dir(si.path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create index directory: %w", err)
+	}
+	// Keep the index out of git status, diffs and commits
+	gitignore := filepath.Join(dir, ".gitignore")
+	if _, err := os.Stat(gitignore); os.IsNotExist(err) {
+		if err := os.WriteFile(gitignore, []byte("*\n"), 0644); err != nil {
+			return fmt.Errorf("failed to create index directory: %w", err)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(si.data); err != nil {
+		return fmt.Errorf("failed to encode index: %w", err)
+	}
+
+	tmp := si.path + ".tmp"
+	if err := os.WriteFile(tmp, buf.Bytes(), 0644); err != nil {
+		return fmt.Errorf("failed to write index: %w", err)
+	}
+	if err := os.Rename(tmp, si.path); err != nil {
+		_ = os.Remove(tmp)
+		return fmt.Errorf("failed to write index: %w", err)
+	}
+	return nil
+}
+
+// Refresh embeds new and modified files, drops deleted ones and saves the index,
+// returning the number of files that were (re)indexed
+func (si *SemanticIndex) Refresh(ctx context.Context) (int, error) {
+	si.mutex.Lock()
+	defer si.mutex.Unlock()
+	si.load()
+
+	seen := make(map[string]bool)
+	updated := 0
+	err := si.cm.walkFiles(func(path string, info os.FileInfo) error {
+		if info.Size() > maxIndexedBytes {
+			return nil
+		}
+		relPath, err := filepath.Rel(si.cm.rootPath, path)
+		if err != nil {
+			return nil
+		}
+		key := filepath.ToSlash(relPath)
+		seen[key] = true
+
+		if existing, ok := si.data.Files[key]; ok && existing.ModTime == info.ModTime().UnixNano() && existing.Size == info.Size() {
+			return nil
+		}
+
+		content, err := si.cm.GetFileContent(path)
+		if err != nil || !isText(content) {
+			delete(si.data.Files, key)
+			return nil
+		}
+
+		chunks, err := si.embedFile(ctx, key, content)
+		if err != nil {
+			return err
+		}
+		si.data.Files[key] = &indexedFile{ModTime: info.ModTime().UnixNano(), Size: info.Size(), Chunks: chunks}
+		updated++
+		return nil
+	})
+
+	// Files are only known to be deleted once the whole tree has been walked
+	if err == nil {
+		for key := range si.data.Files {
+			if !seen[key] {
+				delete(si.data.Files, key)
+				updated++
+			}
+		}
+	}
+
+	// Keep whatever was embedded before a failure so the next refresh can resume
+	if updated > 0 {
+		if saveErr := si.save(); saveErr != nil && err == nil {
+			err = saveErr
+		}
+	}
+	return updated, err
+}
+
+// embedFile splits a file into overlapping line chunks and embeds them
+func (si *SemanticIndex) embedFile(ctx context.Context, relPath string, content string) ([]chunk, error) {
+	lines := strings.Split(content, "\n")
+	var chunks []chunk
+	var texts []string
+	for start := 0; start < len(lines); start += chunkLines - chunkOverlap {
+		end := min(start+chunkLines, len(lines))
+		text := strings.Join(lines[start:end], "\n")
+		if strings.TrimSpace(text) != "" {
+			text = truncateUTF8(text, maxChunkChars)
+			chunks = append(chunks, chunk{Start: start + 1, End: end})
+			texts = append(texts, fmt.Sprintf("%s:%d-%d\n%s", relPath, start+1, end, text))
+		}
+		if end == len(lines) {
+			break
+		}
+	}
+
+	for i := 0; i < len(texts); i += embedBatchSize {
+		batch := texts[i:min(i+embedBatchSize, len(texts))]
+		vectors, err := si.embed(ctx, batch)
+		if err != nil {
+			return nil, fmt.Errorf("failed to embed %s: %w", relPath, err)
+		}
+		if len(vectors) != len(batch) {
+			return nil, fmt.Errorf("failed to embed %s: expected %d embeddings, got %d", relPath, len(batch), len(vectors))
+		}
+		for j, vector := range vectors {
+			chunks[i+j].Vector = normalize(vector)
+		}
+	}
+	return chunks, nil
+}
+
+// Search refreshes the index and returns the files whose chunks are most similar to the query
+func (si *SemanticIndex) Search(ctx context.Context, query string, maxFiles int) ([]RelevantFile, error) {
+	if _, err := si.Refresh(ctx); err != nil {
+		return nil, err
+	}
+
+	vectors, err := si.embed(ctx, []string{query})
+	if err != nil {
+		return nil, fmt.Errorf("failed to embed query: %w", err)
+	}
+	if len(vectors) != 1 {
+		return nil, fmt.Errorf("failed to embed query: expected 1 embedding, got %d", len(vectors))
+	}
+	queryVector := normalize(vectors[0])
+
+	type hit struct {
+		path  string
+		score float64
+		lines LineRange
+	}
+	var hits []hit
+
+	si.mutex.Lock()
+	for key, file := range si.data.Files {
+		for _, c := range file.Chunks {
+			hits = append(hits, hit{path: key, score: dot(queryVector, c.Vector), lines: LineRange{Start: c.Start, End: c.End}})
+		}
+	}
+	si.mutex.Unlock()
+
+	sort.Slice(hits, func(i, j int) bool { return hits[i].score > hits[j].score })
+
+	// Group the best chunks by file, keeping the file order of the best hit
+	var results []RelevantFile
+	index := make(map[string]int)
+	for _, h := range hits {
+		i, ok := index[h.path]
+		if !ok {
+			if len(results) >= maxFiles {
+				continue
+			}
+			i = len(results)
+			index[h.path] = i
+			results = append(results, RelevantFile{
+				Path:  filepath.Join(si.cm.rootPath, filepath.FromSlash(h.path)),
+				Score: h.score,
+			})
+		}
+		if len(results[i].Ranges) < maxRangesPerFile && h.score >= results[i].Score*rangeScoreRatio {
+			results[i].Ranges = append(results[i].Ranges, h.lines)
+		}
+	}
+	return results, nil
+}
+
+// normalize scales a vector to unit length
+func normalize(v []float32) []float32 {
+	var sum float64
+	for _, x := range v {
+		sum += float64(x) * float64(x)
+	}
+	if sum == 0 {
+		return v
+	}
+	norm := float32(math.Sqrt(sum))
+	out := make([]float32, len(v))
+	for i, x := range v {
+		out[i] = x / norm
+	}
+	return out
+}
+
+// dot returns the dot product of two vectors, which is the cosine similarity for unit vectors
+func dot(a, b []float32) float64 {
+	n := min(len(a), len(b))
+	var sum float64
+	for i := 0; i < n; i++ {
+		sum += float64(a[i]) * float64(b[i])
+	}
+	return sum
+}
+
+// isText reports whether content looks like text rather than binary data
+func isText(content string) bool {
+	sample := content
+	if len(sample) > 8000 {
+		sample = sample[:8000]
+	}
+	return !strings.ContainsRune(sample, 0)
+}
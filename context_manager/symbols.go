@@ -0,0 +1,674 @@
+package context_manager
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Symbol kinds
+const (
+	SymbolFunction = "function"
+	SymbolMethod   = "method"
+	SymbolType     = "type"
+	SymbolClass    = "class"
+	SymbolConstant = "constant"
+	SymbolVariable = "variable"
+	SymbolMacro    = "macro"
+)
+
+// Symbol is a declaration found in a source file
+type Symbol struct {
+	Name      string
+	Kind      string
+	Parent    string // Receiver type, class, impl or trait the symbol belongs to, if any
+	Path      string // Absolute path
+	Start     int    // First line, including leading comments, attributes and decorators
+	End       int    // Last line (inclusive)
+	Signature string // The declaration without its body
+}
+
+// QualifiedName returns the name prefixed with its parent, e.g. "Server.Start"
+func (s Symbol) QualifiedName() string {
+	if s.Parent != "" {
+		return s.Parent + "." + s.Name
+	}
+	return s.Name
+}
+
+// Matches reports whether the symbol is the one a "Name" or "Parent.Name" reference means
+func (s Symbol) Matches(name string) bool {
+	return s.Name == name || s.QualifiedName() == name
+}
+
+// symbolFile holds the symbols of a file as of its last modification time
+type symbolFile struct {
+	modTime int64
+	size    int64
+	symbols []Symbol
+}
+
+// SymbolIndex is an in-memory index of the declarations in the project's source files,
+// refreshed by file modification time
+type SymbolIndex struct {
+	cm    *ContextManager
+	mutex sync.Mutex
+	files map[string]*symbolFile // Keyed by absolute path
+}
+
+// newSymbolIndex creates an empty index for the manager's project
+func newSymbolIndex(cm *ContextManager) *SymbolIndex {
+	return &SymbolIndex{cm: cm, files: make(map[string]*symbolFile)}
+}
+
+// SupportsSymbols reports whether symbols can be extracted from files with the given path
+func SupportsSymbols(filePath string) bool {
+	switch strings.ToLower(filepath.Ext(filePath)) {
+	case ".go", ".py", ".js", ".jsx", ".mjs", ".ts", ".tsx", ".c", ".h", ".cc", ".cpp", ".hpp", ".rs":
+		return true
+	}
+	return false
+}
+
+// Refresh parses new and modified source files and drops deleted ones
+func (si *SymbolIndex) Refresh() error {
+	seen := make(map[string]bool)
+	err := si.cm.walkFiles(func(path string, info os.FileInfo) error {
+		if !SupportsSymbols(path) || info.Size() > maxIndexedBytes {
+			return nil
+		}
+		seen[path] = true
+		_, _ = si.FileSymbols(path)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	si.mutex.Lock()
+	defer si.mutex.Unlock()
+	for path := range si.files {
+		if !seen[path] {
+			delete(si.files, path)
+		}
+	}
+	return nil
+}
+
+// FileSymbols returns the symbols declared in a file, in file order
+func (si *SymbolIndex) FileSymbols(filePath string) ([]Symbol, error) {
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	si.mutex.Lock()
+	if file, ok := si.files[filePath]; ok && file.modTime == info.ModTime().UnixNano() && file.size == info.Size() {
+		si.mutex.Unlock()
+		return file.symbols, nil
+	}
+	si.mutex.Unlock()
+
+	content, err := si.cm.GetFileContent(filePath)
+	if err != nil {
+		return nil, err
+	}
+	symbols := ExtractSymbols(filePath, content)
+
+	si.mutex.Lock()
+	si.files[filePath] = &symbolFile{modTime: info.ModTime().UnixNano(), size: info.Size(), symbols: symbols}
+	si.mutex.Unlock()
+	return symbols, nil
+}
+
+// Lookup refreshes the index and returns the symbols across the project that match a
+// "Name" or "Parent.Name" reference, ordered by path and line
+func (si *SymbolIndex) Lookup(name string) ([]Symbol, error) {
+	if err := si.Refresh(); err != nil {
+		return nil, err
+	}
+
+	si.mutex.Lock()
+	var all []Symbol
+	for _, file := range si.files {
+		all = append(all, file.symbols...)
+	}
+	si.mutex.Unlock()
+
+	matches := FindSymbols(all, name)
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].Path != matches[j].Path {
+			return matches[i].Path < matches[j].Path
+		}
+		return matches[i].Start < matches[j].Start
+	})
+	return matches, nil
+}
+
+// FindSymbols returns the symbols matching a "Name" or "Parent.Name" reference, falling
+// back to a case-insensitive match when nothing matches exactly
+func FindSymbols(symbols []Symbol, name string) []Symbol {
+	var matches []Symbol
+	for _, s := range symbols {
+		if s.Matches(name) {
+			matches = append(matches, s)
+		}
+	}
+	if len(matches) > 0 {
+		return matches
+	}
+	for _, s := range symbols {
+		if strings.EqualFold(s.Name, name) || strings.EqualFold(s.QualifiedName(), name) {
+			matches = append(matches, s)
+		}
+	}
+	return matches
+}
+
+// ExtractSymbols returns the declarations in a source file, using go/ast for Go and
+// line-based parsers for Python, JavaScript/TypeScript, C/C++ and Rust
+func ExtractSymbols(filePath, content string) []Symbol {
+	var symbols []Symbol
+	switch strings.ToLower(filepath.Ext(filePath)) {
+	case ".go":
+		symbols = goSymbols(filePath, content)
+	case ".py":
+		symbols = pythonSymbols(content)
+	case ".js", ".jsx", ".mjs", ".ts", ".tsx":
+		symbols = braceSymbols(content, jsRules, true, []string{"//", "/*", "*", "@"})
+	case ".c", ".h", ".cc", ".cpp", ".hpp":
+		symbols = braceSymbols(content, cRules, false, []string{"//", "/*", "*"})
+	case ".rs":
+		symbols = braceSymbols(content, rustRules, false, []string{"//", "/*", "*", "#["})
+	}
+	for i := range symbols {
+		symbols[i].Path = filePath
+	}
+	return symbols
+}
+
+// goSymbols extracts the top-level declarations of a Go file
+func goSymbols(filePath, content string) []Symbol {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, filePath, content, parser.ParseComments|parser.SkipObjectResolution)
+	if err != nil && file == nil {
+		return nil
+	}
+	line := func(pos token.Pos) int { return fset.Position(pos).Line }
+	source := func(from, to token.Pos) string {
+		start, end := fset.Position(from).Offset, fset.Position(to).Offset
+		if start < 0 || end > len(content) || start > end {
+			return ""
+		}
+		return strings.Join(strings.Fields(content[start:end]), " ")
+	}
+
+	var symbols []Symbol
+	for _, decl := range file.Decls {
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			s := Symbol{Name: d.Name.Name, Kind: SymbolFunction, Start: line(d.Pos()), End: line(d.End())}
+			if d.Doc != nil {
+				s.Start = line(d.Doc.Pos())
+			}
+			if d.Recv != nil && len(d.Recv.List) > 0 {
+				s.Kind = SymbolMethod
+				s.Parent = receiverType(d.Recv.List[0].Type)
+			}
+			if d.Body != nil {
+				s.Signature = source(d.Pos(), d.Body.Lbrace)
+			} else {
+				s.Signature = source(d.Pos(), d.End())
+			}
+			symbols = append(symbols, s)
+
+		case *ast.GenDecl:
+			if d.Tok == token.IMPORT {
+				continue
+			}
+			for _, spec := range d.Specs {
+				// A single spec covers the whole declaration, a grouped one only its own lines
+				start, end := line(spec.Pos()), line(spec.End())
+				var doc *ast.CommentGroup
+				if !d.Lparen.IsValid() {
+					start, end, doc = line(d.Pos()), line(d.End()), d.Doc
+				}
+
+				switch s := spec.(type) {
+				case *ast.TypeSpec:
+					if s.Doc != nil {
+						doc = s.Doc
+					}
+					if doc != nil {
+						start = line(doc.Pos())
+					}
+					signature := "type " + source(s.Pos(), s.Type.Pos())
+					switch s.Type.(type) {
+					case *ast.StructType:
+						signature += " struct"
+					case *ast.InterfaceType:
+						signature += " interface"
+					default:
+						signature = "type " + source(s.Pos(), s.End())
+					}
+					symbols = append(symbols, Symbol{Name: s.Name.Name, Kind: SymbolType, Start: start, End: end, Signature: signature})
+
+				case *ast.ValueSpec:
+					if s.Doc != nil {
+						doc = s.Doc
+					}
+					if doc != nil {
+						start = line(doc.Pos())
+					}
+					kind := SymbolVariable
+					if d.Tok == token.CONST {
+						kind = SymbolConstant
+					}
+					for _, name := range s.Names {
+						symbols = append(symbols, Symbol{Name: name.Name, Kind: kind, Start: start, End: end, Signature: d.Tok.String() + " " + source(s.Pos(), s.End())})
+					}
+				}
+			}
+		}
+	}
+	return symbols
+}
+
+var (
+	pythonDef      = regexp.MustCompile(`^(\s*)(?:async\s+)?def\s+(\w+)\s*\(`)
+	pythonClass    = regexp.MustCompile(`^(\s*)class\s+(\w+)`)
+	pythonConstant = regexp.MustCompile(`^([A-Z][A-Z0-9_]*)\s*(?::[^=]+)?=`)
+)
+
+// pythonSymbols extracts classes, functions, methods and module-level constants, using
+// indentation to find where each block ends
+func pythonSymbols(content string) []Symbol {
+	lines := strings.Split(content, "\n")
+
+	// Enclosing blocks, innermost last
+	type block struct {
+		indent  int
+		name    string
+		isClass bool
+	}
+	var stack []block
+
+	var symbols []Symbol
+	for i, text := range lines {
+		if strings.TrimSpace(text) == "" || strings.HasPrefix(strings.TrimSpace(text), "#") {
+			continue
+		}
+		indent := len(text) - len(strings.TrimLeft(text, " \t"))
+		for len(stack) > 0 && stack[len(stack)-1].indent >= indent {
+			stack = stack[:len(stack)-1]
+		}
+
+		if m := pythonConstant.FindStringSubmatch(text); m != nil && indent == 0 {
+			symbols = append(symbols, Symbol{Name: m[1], Kind: SymbolConstant, Start: i + 1, End: i + 1, Signature: strings.TrimSpace(text)})
+			continue
+		}
+
+		var name, kind string
+		if m := pythonDef.FindStringSubmatch(text); m != nil {
+			name, kind = m[2], SymbolFunction
+		} else if m := pythonClass.FindStringSubmatch(text); m != nil {
+			name, kind = m[2], SymbolClass
+		} else {
+			continue
+		}
+
+		s := Symbol{Name: name, Kind: kind, Start: leadingComments(lines, i, []string{"#", "@"}) + 1, End: pythonBlockEnd(lines, i, indent) + 1}
+		s.Signature = strings.TrimSuffix(strings.TrimSpace(text), ":")
+		if n := len(stack); n > 0 && stack[n-1].isClass && kind == SymbolFunction {
+			s.Kind = SymbolMethod
+			s.Parent = stack[n-1].name
+		}
+		if len(stack) == 0 || stack[len(stack)-1].isClass {
+			// Functions nested in functions are implementation details
+			symbols = append(symbols, s)
+		}
+		stack = append(stack, block{indent: indent, name: name, isClass: kind == SymbolClass})
+	}
+	return symbols
+}
+
+// pythonBlockEnd returns the last line of the block whose header is on line start
+func pythonBlockEnd(lines []string, start int, indent int) int {
+	// Skip the rest of a multi-line header
+	i := start
+	for depth := 0; i < len(lines); i++ {
+		depth += strings.Count(lines[i], "(") + strings.Count(lines[i], "[") - strings.Count(lines[i], ")") - strings.Count(lines[i], "]")
+		if depth <= 0 && strings.HasSuffix(strings.TrimSpace(stripPythonComment(lines[i])), ":") {
+			break
+		}
+	}
+
+	end := min(i, len(lines)-1)
+	for j := i + 1; j < len(lines); j++ {
+		trimmed := strings.TrimSpace(lines[j])
+		if trimmed == "" {
+			continue
+		}
+		if len(lines[j])-len(strings.TrimLeft(lines[j], " \t")) <= indent && !strings.HasPrefix(trimmed, "#") {
+			break
+		}
+		end = j
+	}
+	return end
+}
+
+// stripPythonComment removes a trailing comment from a line of Python, ignoring quotes
+func stripPythonComment(line string) string {
+	if i := strings.Index(line, "#"); i >= 0 && !strings.ContainsAny(line[:i], `"'`) {
+		return line[:i]
+	}
+	return line
+}
+
+// leadingComments returns the first line of the comments, attributes or decorators
+// directly above line i (0-based)
+func leadingComments(lines []string, i int, prefixes []string) int {
+	start := i
+	for start > 0 {
+		prev := strings.TrimSpace(lines[start-1])
+		matched := false
+		for _, prefix := range prefixes {
+			if strings.HasPrefix(prev, prefix) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			break
+		}
+		start--
+	}
+	return start
+}
+
+// braceRule recognizes a declaration in a brace-delimited language
+type braceRule struct {
+	re         *regexp.Regexp // Matched against the trimmed line; the first group is the name
+	kind       string         // Kind at the top level, "" if the rule doesn't apply there
+	memberKind string         // Kind inside a container, "" if the rule doesn't apply there
+	container  bool           // Declarations directly inside belong to this one
+	needsBody  bool           // Only a declaration if a body follows (not a prototype)
+}
+
+// Keywords that look like calls or declarations to the line-based rules
+var braceKeywords = map[string]bool{
+	"if": true, "for": true, "while": true, "switch": true, "catch": true, "return": true,
+	"function": true, "else": true, "do": true, "sizeof": true, "new": true,
+}
+
+var jsRules = []braceRule{
+	{re: regexp.MustCompile(`^(?:export\s+)?(?:default\s+)?(?:declare\s+)?(?:async\s+)?function\s*\*?\s*(\w+)`), kind: SymbolFunction},
+	{re: regexp.MustCompile(`^(?:export\s+)?(?:default\s+)?(?:declare\s+)?(?:abstract\s+)?class\s+(\w+)`), kind: SymbolClass, container: true},
+	{re: regexp.MustCompile(`^(?:export\s+)?(?:declare\s+)?interface\s+(\w+)`), kind: SymbolType},
+	{re: regexp.MustCompile(`^(?:export\s+)?(?:declare\s+)?type\s+(\w+)\s*(?:<[^=]*>)?\s*=`), kind: SymbolType},
+	{re: regexp.MustCompile(`^(?:export\s+)?(?:declare\s+)?(?:const\s+)?enum\s+(\w+)`), kind: SymbolType},
+	{re: regexp.MustCompile(`^(?:export\s+)?(?:const|let|var)\s+(\w+)\s*(?::[^=]+)?=\s*(?:async\s+)?(?:function\b|(?:\([^)]*\)|\w+)\s*(?::[^=]+)?=>)`), kind: SymbolFunction},
+	{re: regexp.MustCompile(`^(?:export\s+)?const\s+([A-Z][A-Z0-9_]*)\s*(?::[^=]+)?=`), kind: SymbolConstant},
+	{re: regexp.MustCompile(`^(?:(?:public|private|protected|static|readonly|override)\s+)*(\w+)\s*=\s*(?:async\s+)?(?:\([^)]*\)|\w+)\s*(?::[^=]+)?=>`), memberKind: SymbolMethod},
+	{re: regexp.MustCompile(`^(?:(?:public|private|protected|static|async|override|abstract|get|set)\s+)*\*?(\w+)\s*(?:<[^>]*>)?\s*\(`), memberKind: SymbolMethod},
+}
+
+var cRules = []braceRule{
+	{re: regexp.MustCompile(`^#\s*define\s+(\w+)`), kind: SymbolMacro},
+	{re: regexp.MustCompile(`^typedef\s+(?:struct|union|enum)\s*(\w*)\s*\{`), kind: SymbolType},
+	{re: regexp.MustCompile(`^(?:typedef\s+)?(?:struct|union|enum|class)\s+(\w+)\s*(?::[^{;]*)?\{?\s*$`), kind: SymbolType, needsBody: true},
+	{re: regexp.MustCompile(`^(?:[\w:<>]+[\s*&]+)+[*&]?(~?\w+(?:::~?\w+)*)\s*\(`), kind: SymbolFunction, needsBody: true},
+}
+
+var rustRules = []braceRule{
+	{re: regexp.MustCompile(`^(?:pub(?:\([^)]*\))?\s+)?(?:default\s+)?(?:const\s+)?(?:async\s+)?(?:unsafe\s+)?(?:extern\s+"[^"]*"\s+)?fn\s+(\w+)`), kind: SymbolFunction, memberKind: SymbolMethod},
+	{re: regexp.MustCompile(`^(?:pub(?:\([^)]*\))?\s+)?(?:struct|enum|union)\s+(\w+)`), kind: SymbolType},
+	{re: regexp.MustCompile(`^(?:pub(?:\([^)]*\))?\s+)?(?:unsafe\s+)?trait\s+(\w+)`), kind: SymbolType, container: true},
+	{re: regexp.MustCompile(`^(?:unsafe\s+)?impl\b(?:\s*<[^>]*>)?\s+(?:[\w:]+(?:<[^>]*>)?\s+for\s+)?(?:[\w]+::)*(\w+)`), container: true},
+	{re: regexp.MustCompile(`^(?:pub(?:\([^)]*\))?\s+)?type\s+(\w+)`), kind: SymbolType, memberKind: SymbolType},
+	{re: regexp.MustCompile(`^(?:pub(?:\([^)]*\))?\s+)?(?:const|static)\s+(?:mut\s+)?(\w+)`), kind: SymbolConstant, memberKind: SymbolConstant},
+	{re: regexp.MustCompile(`^macro_rules!\s*(\w+)`), kind: SymbolMacro},
+}
+
+// braceSymbols extracts declarations from a language that delimits bodies with braces.
+// Comments and string literals are blanked out first so that braces inside them don't
+// count; rules are tried in order on lines at the top level or directly inside a container.
+func braceSymbols(content string, rules []braceRule, jsQuotes bool, commentPrefixes []string) []Symbol {
+	lines := strings.Split(content, "\n")
+	code := stripCommentsAndStrings(lines, jsQuotes)
+
+	depths := make([]int, len(code))
+	depth := 0
+	for i, text := range code {
+		depths[i] = depth
+		depth += strings.Count(text, "{") - strings.Count(text, "}")
+	}
+
+	type container struct {
+		name       string
+		depth, end int
+	}
+	var containers []container
+
+	var symbols []Symbol
+	for i := range code {
+		trimmed := strings.TrimSpace(code[i])
+		if trimmed == "" {
+			continue
+		}
+		for len(containers) > 0 && containers[len(containers)-1].end < i {
+			containers = containers[:len(containers)-1]
+		}
+
+		parent := ""
+		member := false
+		if n := len(containers); n > 0 && depths[i] == containers[n-1].depth+1 {
+			parent, member = containers[n-1].name, true
+		} else if depths[i] != 0 {
+			continue
+		}
+
+		for _, rule := range rules {
+			kind := rule.kind
+			if member {
+				kind = rule.memberKind
+			}
+			if kind == "" && !rule.container {
+				continue
+			}
+			m := rule.re.FindStringSubmatch(trimmed)
+			if m == nil || braceKeywords[m[1]] {
+				continue
+			}
+
+			end, hasBody := braceEnd(code, i)
+			if rule.needsBody && !hasBody {
+				continue
+			}
+			if strings.HasPrefix(trimmed, "#") {
+				// Preprocessor definitions continue over escaped line ends
+				for end = i; end+1 < len(lines) && strings.HasSuffix(strings.TrimRight(lines[end], " \t"), `\`); end++ {
+				}
+			}
+
+			name := m[1]
+			if name == "" || strings.HasPrefix(trimmed, "typedef") {
+				// typedef struct { ... } Name;
+				if alias := typedefName(code[end]); alias != "" {
+					name = alias
+				}
+			}
+			if name == "" {
+				break
+			}
+
+			symbolParent := parent
+			if strings.Contains(name, "::") {
+				// C++ out-of-class definitions: Class::method
+				parts := strings.Split(name, "::")
+				symbolParent, name = strings.Join(parts[:len(parts)-1], "::"), parts[len(parts)-1]
+				if kind == SymbolFunction {
+					kind = SymbolMethod
+				}
+			}
+
+			if kind != "" {
+				symbols = append(symbols, Symbol{
+					Name:      name,
+					Kind:      kind,
+					Parent:    symbolParent,
+					Start:     leadingComments(lines, i, commentPrefixes) + 1,
+					End:       end + 1,
+					Signature: braceSignature(lines, i),
+				})
+			}
+			if rule.container && hasBody {
+				containers = append(containers, container{name: name, depth: depths[i], end: end})
+			}
+			break
+		}
+	}
+	return symbols
+}
+
+var typedefAlias = regexp.MustCompile(`\}\s*\**\s*(\w+)\s*;`)
+
+// typedefName returns the alias declared at the end of a typedef
+func typedefName(line string) string {
+	if m := typedefAlias.FindStringSubmatch(line); m != nil {
+		return m[1]
+	}
+	return ""
+}
+
+// braceEnd returns the line on which the declaration starting at line start ends, and
+// whether it has a body. Braces inside parentheses (e.g. destructured parameters) don't
+// open the body; a semicolon before any body ends the declaration.
+func braceEnd(code []string, start int) (int, bool) {
+	depth, parens := 0, 0
+	opened := false
+	for i := start; i < len(code); i++ {
+		for _, c := range code[i] {
+			switch c {
+			case '(':
+				parens++
+			case ')':
+				parens--
+			case '{':
+				if opened || parens <= 0 {
+					depth++
+					opened = true
+				}
+			case '}':
+				if opened {
+					depth--
+					if depth == 0 {
+						return i, true
+					}
+				}
+			case ';':
+				if !opened && parens <= 0 {
+					return i, false
+				}
+			}
+		}
+		// A declaration without a body or terminator ends at the first blank line
+		if !opened && i > start && strings.TrimSpace(code[i]) == "" {
+			return i - 1, false
+		}
+	}
+	return start, false
+}
+
+// braceSignature returns the declaration line without the opening brace of its body
+func braceSignature(lines []string, i int) string {
+	signature := strings.TrimSpace(lines[i])
+	if j := strings.LastIndex(signature, "{"); j > 0 {
+		signature = strings.TrimSpace(signature[:j])
+	}
+	return signature
+}
+
+// stripCommentsAndStrings blanks out comments and string literals so that only code is
+// left, keeping line lengths. With jsQuotes, single quotes and backticks delimit strings;
+// otherwise single quotes only delimit short character literals (Rust lifetimes are not).
+func stripCommentsAndStrings(lines []string, jsQuotes bool) []string {
+	code := make([]string, len(lines))
+	inBlock := false
+	var quote rune // Quote of the string literal continuing onto the next line
+	for n, line := range lines {
+		runes := []rune(line)
+		out := make([]rune, len(runes))
+		for i := 0; i < len(runes); i++ {
+			c := runes[i]
+			out[i] = ' '
+			switch {
+			case inBlock:
+				if c == '*' && i+1 < len(runes) && runes[i+1] == '/' {
+					inBlock = false
+					out[i+1] = ' '
+					i++
+				}
+			case quote != 0:
+				if c == '\\' && i+1 < len(runes) {
+					out[i+1] = ' '
+					i++
+				} else if c == quote {
+					quote = 0
+				}
+			case c == '/' && i+1 < len(runes) && runes[i+1] == '/':
+				for j := i; j < len(runes); j++ {
+					out[j] = ' '
+				}
+				i = len(runes)
+			case c == '/' && i+1 < len(runes) && runes[i+1] == '*':
+				inBlock = true
+				out[i+1] = ' '
+				i++
+			case c == '"' || jsQuotes && (c == '\'' || c == '`'):
+				quote = c
+			case c == '\'':
+				// Character literals: 'x' or '\n'
+				if i+2 < len(runes) && runes[i+1] == '\\' {
+					end := i + 2
+					for end < len(runes) && runes[end] != '\'' {
+						end++
+					}
+					for j := i; j <= end && j < len(runes); j++ {
+						out[j] = ' '
+					}
+					i = end
+				} else if i+2 < len(runes) && runes[i+2] == '\'' {
+					out[i+1], out[i+2] = ' ', ' '
+					i += 2
+				} else {
+					out[i] = c
+				}
+			default:
+				out[i] = c
+			}
+		}
+		// Only template literals and block comments span lines
+		if quote != '`' {
+			quote = 0
+		}
+		code[n] = string(out)
+	}
+	return code
+}
+
+// FormatSymbol describes a symbol for the user, e.g. "method Server.Start (server.go:10-25)"
+func FormatSymbol(s Symbol, root string) string {
+	path := s.Path
+	if rel, err := filepath.Rel(root, s.Path); err == nil && !strings.HasPrefix(rel, "..") {
+		path = rel
+	}
+	return fmt.Sprintf("%s %s (%s:%d-%d)", s.Kind, s.QualifiedName(), path, s.Start, s.End)
+}
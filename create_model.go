@@ -0,0 +1,215 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/ai-in-pm/Ollama-Code/api"
+	"github.com/ai-in-pm/Ollama-Code/modelfile"
+	"github.com/ai-in-pm/Ollama-Code/ui"
+	"github.com/spf13/cobra"
+)
+
+// configModelfile builds a Modelfile for the configured model with the system prompt of a
+// task and the configured generation options baked in
+func configModelfile(task string) (*modelfile.Modelfile, error) {
+	if _, ok := config.SystemPrompts[task]; !ok {
+		return nil, fmt.Errorf("no system prompt for %q in the configuration", task)
+	}
+	parameters, err := modelfile.FromOptions(generationOptions())
+	if err != nil {
+		return nil, err
+	}
+
+	m := &modelfile.Modelfile{}
+	m.Add(modelfile.From, "", config.Model)
+	m.Add(modelfile.System, "", systemPrompt(task))
+	m.Commands = append(m.Commands, parameters...)
+	return m, nil
+}
+
+// isWeightsFile reports whether a file in a model directory is needed to create a model
+// from it: safetensors weights, their configuration and the tokenizer
+func isWeightsFile(name string) bool {
+	switch filepath.Ext(name) {
+	case ".safetensors", ".json", ".gguf":
+		return true
+	}
+	return name == "tokenizer.model"
+}
+
+// uploadFiles uploads a GGUF file, or the weights files of a directory, as blobs and
+// returns their file names and digests. Blobs the server already has are not sent again.
+func uploadFiles(ctx context.Context, client *api.OllamaClient, terminal *ui.TerminalUI, path string) (map[string]string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	paths := []string{path}
+	if info.IsDir() {
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		paths = nil
+		for _, entry := range entries {
+			if entry.Type().IsRegular() && isWeightsFile(entry.Name()) {
+				paths = append(paths, filepath.Join(path, entry.Name()))
+			}
+		}
+		if len(paths) == 0 {
+			return nil, fmt.Errorf("no model files (*.safetensors, *.gguf, *.json, tokenizer.model) in %s", path)
+		}
+	}
+
+	files := make(map[string]string, len(paths))
+	for _, p := range paths {
+		digest, err := uploadBlob(ctx, client, terminal, p)
+		if err != nil {
+			return nil, err
+		}
+		files[filepath.Base(p)] = digest
+	}
+	return files, nil
+}
+
+// uploadBlob uploads a file as a blob unless the server already has it, returning its digest
+func uploadBlob(ctx context.Context, client *api.OllamaClient, terminal *ui.TerminalUI, path string) (string, error) {
+	name := filepath.Base(path)
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = f.Close() }()
+	info, err := f.Stat()
+	if err != nil {
+		return "", err
+	}
+
+	terminal.SetLoading(true, "Computing the digest of "+name+"...")
+	hash := sha256.New()
+	_, err = io.Copy(hash, f)
+	terminal.SetLoading(false, "")
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	digest := "sha256:" + hex.EncodeToString(hash.Sum(nil))
+
+	exists, err := client.BlobExists(ctx, digest)
+	if err != nil {
+		return "", err
+	}
+	if exists {
+		return digest, nil
+	}
+
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	body := &progressReader{Reader: f, report: func(n int64) {
+		terminal.SetProgress("uploading "+name, n, info.Size())
+	}}
+	err = client.CreateBlob(ctx, digest, body, info.Size())
+	terminal.SetLoading(false, "")
+	if err != nil {
+		return "", fmt.Errorf("failed to upload %s: %w", name, err)
+	}
+	return digest, nil
+}
+
+// progressReader reports the number of bytes read so far
+type progressReader struct {
+	io.Reader
+	read   int64
+	report func(n int64)
+}
+
+// Read implements io.Reader
+func (r *progressReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	r.read += int64(n)
+	r.report(r.read)
+	return n, err
+}
+
+// newModelsCreateCmd creates the models create command
+func newModelsCreateCmd() *cobra.Command {
+	var file, task, quantize string
+	var fromConfig, printOnly bool
+
+	cmd := &cobra.Command{
+		Use:   "create [name] -f Modelfile",
+		Short: "Create a model from a Modelfile or from the configuration",
+		Long: `Creates a model from a Modelfile. FROM names a base model or a local GGUF file or
+safetensors directory, which is uploaded to the server first. With --from-config the Modelfile
+is generated from the configuration instead: the configured model with the system prompt of a
+task (--prompt, "chat" by default) and the generation options baked in. --print shows the
+Modelfile without creating the model.`,
+		Args: func(cmd *cobra.Command, args []string) error {
+			if printOnly {
+				return cobra.MaximumNArgs(1)(cmd, args)
+			}
+			return cobra.ExactArgs(1)(cmd, args)
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if fromConfig == (file != "") {
+				return fmt.Errorf("use either -f Modelfile or --from-config")
+			}
+
+			var m *modelfile.Modelfile
+			var err error
+			dir := "."
+			if fromConfig {
+				m, err = configModelfile(task)
+			} else {
+				m, err = modelfile.ParseFile(file)
+				dir = filepath.Dir(file)
+			}
+			if err != nil {
+				return fmt.Errorf("failed to read Modelfile: %w", err)
+			}
+			if printOnly {
+				fmt.Print(m.String())
+				return nil
+			}
+
+			client, err := ollamaClient()
+			if err != nil {
+				return err
+			}
+			name := args[0]
+			terminal := ui.NewTerminalUI()
+			ctx, cancel := terminal.Cancellable(context.Background())
+			defer cancel()
+
+			req, err := m.Request(name, dir, func(path string) (map[string]string, error) {
+				return uploadFiles(ctx, client, terminal, path)
+			})
+			if err != nil {
+				return clientError(err)
+			}
+			req.Quantize = quantize
+			err = client.Create(ctx, req, func(progress api.ProgressResponse) {
+				terminal.SetProgress(progress.Status, progress.Completed, progress.Total)
+			})
+			terminal.SetLoading(false, "")
+			if err != nil {
+				return clientError(err)
+			}
+			forgetModelInfo(client, name)
+			fmt.Printf("Created %s. Use it with --model %s.\n", name, name)
+			return nil
+		},
+	}
+	cmd.Flags().StringVarP(&file, "file", "f", "", "Path to the Modelfile")
+	cmd.Flags().BoolVar(&fromConfig, "from-config", false, "Generate the Modelfile from the configured model, system prompt and options")
+	cmd.Flags().StringVar(&task, "prompt", "chat", "System prompt to bake in with --from-config: chat, generate, explain, review, ...")
+	cmd.Flags().StringVarP(&quantize, "quantize", "q", "", "Quantize the model, e.g. q4_K_M")
+	cmd.Flags().BoolVar(&printOnly, "print", false, "Print the Modelfile instead of creating the model")
+	return cmd
+}
@@ -0,0 +1,160 @@
+package edits
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// FormatInstructions tells the model how to propose edits so they can be applied automatically
+const FormatInstructions = `After a short explanation, give every change as a SEARCH/REPLACE block in exactly this format:
+
+path/to/file.ext
+<<<<<<< SEARCH
+exact lines copied from the current file
+=======
+the lines that replace them
+>>>>>>> REPLACE
+
+The SEARCH part must match the file exactly, including indentation, and should include enough lines to be unique.
+Use one block per change. To create a new file, leave the SEARCH part empty.`
+
+// Block is a single search/replace edit proposed by the model
+type Block struct {
+	Path    string
+	Search  string
+	Replace string
+}
+
+var (
+	searchMarker  = regexp.MustCompile(`^<{5,9} ?SEARCH\s*$`)
+	dividerMarker = regexp.MustCompile(`^={5,9}\s*$`)
+	replaceMarker = regexp.MustCompile(`^>{5,9} ?REPLACE\s*$`)
+)
+
+// ParseBlocks extracts search/replace blocks from a model response. Blocks without a
+// file name use defaultPath.
+func ParseBlocks(text string, defaultPath string) []Block {
+	var blocks []Block
+	lines := strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+
+	for i := 0; i < len(lines); i++ {
+		if !searchMarker.MatchString(strings.TrimSpace(lines[i])) {
+			continue
+		}
+
+		block := Block{Path: findPath(lines[:i], defaultPath)}
+
+		// Collect the search part
+		var search []string
+		j := i + 1
+		for ; j < len(lines) && !dividerMarker.MatchString(strings.TrimSpace(lines[j])); j++ {
+			search = append(search, lines[j])
+		}
+		if j >= len(lines) {
+			break
+		}
+
+		// Collect the replacement
+		var replace []string
+		k := j + 1
+		for ; k < len(lines) && !replaceMarker.MatchString(strings.TrimSpace(lines[k])); k++ {
+			replace = append(replace, lines[k])
+		}
+		if k >= len(lines) {
+			break
+		}
+
+		block.Search = joinLines(search)
+		block.Replace = joinLines(replace)
+		blocks = append(blocks, block)
+		i = k
+	}
+
+	return blocks
+}
+
+// findPath returns the file name given on the closest non-empty line before a block
+func findPath(before []string, defaultPath string) string {
+	for i := len(before) - 1; i >= 0; i-- {
+		line := strings.TrimSpace(before[i])
+		if line == "" || strings.HasPrefix(line, "```") {
+			continue
+		}
+
+		line = strings.TrimPrefix(line, "File:")
+		line = strings.Trim(strings.TrimSpace(line), "`*\"'")
+		if line == "" || strings.ContainsAny(line, " \t") || replaceMarker.MatchString(line) {
+			return defaultPath
+		}
+		return line
+	}
+	return defaultPath
+}
+
+// joinLines rejoins block lines, keeping a trailing newline for non-empty blocks
+func joinLines(lines []string) string {
+	if len(lines) == 0 {
+		return ""
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// ApplyBlock applies a block to file content. An exact match is preferred; otherwise the
+// search lines are matched ignoring trailing whitespace. The match must be unique.
+func ApplyBlock(content string, block Block) (string, error) {
+	if block.Search == "" {
+		if content != "" {
+			return "", fmt.Errorf("empty SEARCH part for existing file %s", block.Path)
+		}
+		return block.Replace, nil
+	}
+
+	switch count := strings.Count(content, block.Search); count {
+	case 1:
+		return strings.Replace(content, block.Search, block.Replace, 1), nil
+	case 0:
+	default:
+		return "", fmt.Errorf("SEARCH text occurs %d times in %s", count, block.Path)
+	}
+
+	// Fall back to a line-based match that ignores trailing whitespace
+	contentLines := strings.SplitAfter(content, "\n")
+	searchLines := strings.Split(strings.TrimSuffix(block.Search, "\n"), "\n")
+
+	match := -1
+	for start := 0; start+len(searchLines) <= len(contentLines); start++ {
+		found := true
+		for n, line := range searchLines {
+			if strings.TrimRight(contentLines[start+n], " \t\r\n") != strings.TrimRight(line, " \t\r") {
+				found = false
+				break
+			}
+		}
+		if found {
+			if match >= 0 {
+				return "", fmt.Errorf("SEARCH text matches several places in %s", block.Path)
+			}
+			match = start
+		}
+	}
+	if match < 0 {
+		return "", fmt.Errorf("SEARCH text not found in %s", block.Path)
+	}
+
+	var result strings.Builder
+	for _, line := range contentLines[:match] {
+		result.WriteString(line)
+	}
+	replace := block.Replace
+	end := match + len(searchLines)
+	// Preserve a missing final newline when the match runs to the end of the file
+	if end == len(contentLines) && !strings.HasSuffix(content, "\n") {
+		replace = strings.TrimSuffix(replace, "\n")
+	}
+	result.WriteString(replace)
+	for _, line := range contentLines[end:] {
+		result.WriteString(line)
+	}
+	return result.String(), nil
+}
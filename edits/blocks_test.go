@@ -0,0 +1,118 @@
+package edits
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseBlocks(t *testing.T) {
+	response := "Rename the greeting and add a file.\n" +
+		"\n" +
+		"main.go\n" +
+		"```go\n" +
+		"<<<<<<< SEARCH\n" +
+		"\tfmt.Println(\"hi\")\n" +
+		"=======\n" +
+		"\tfmt.Println(\"hello\")\n" +
+		">>>>>>> REPLACE\n" +
+		"```\n" +
+		"\n" +
+		"File: `docs/new.md`\n" +
+		"<<<<<<< SEARCH\n" +
+		"=======\n" +
+		"# New\n" +
+		">>>>>>> REPLACE\n" +
+		"\n" +
+		"Then remove the helper:\n" +
+		"<<<<<<< SEARCH\r\n" +
+		"func helper() {}\r\n" +
+		"=======\r\n" +
+		">>>>>>> REPLACE\r\n" +
+		"<<<<<<< SEARCH\n" +
+		"never closed\n"
+
+	want := []Block{
+		{Path: "main.go", Search: "\tfmt.Println(\"hi\")\n", Replace: "\tfmt.Println(\"hello\")\n"},
+		{Path: "docs/new.md", Search: "", Replace: "# New\n"},
+		{Path: "current.go", Search: "func helper() {}\n", Replace: ""}, // Prose before the block
+	}
+	if got := ParseBlocks(response, "current.go"); !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseBlocks:\n got %+v\nwant %+v", got, want)
+	}
+}
+
+func TestParseBlocksWithoutBlocks(t *testing.T) {
+	if got := ParseBlocks("Looks good, no changes needed.", "main.go"); len(got) != 0 {
+		t.Errorf("ParseBlocks = %+v", got)
+	}
+}
+
+func TestApplyBlock(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		block   Block
+		want    string
+	}{
+		{
+			"exact match",
+			"a\nb\nc\n",
+			Block{Search: "b\n", Replace: "B\n"},
+			"a\nB\nc\n",
+		},
+		{
+			"trailing whitespace ignored",
+			"a  \nb\t\nc\n",
+			Block{Search: "a\nb\n", Replace: "x\n"},
+			"x\nc\n",
+		},
+		{
+			"missing final newline kept",
+			"a\nb  ",
+			Block{Search: "b\n", Replace: "c\n"},
+			"a\nc",
+		},
+		{
+			"new file",
+			"",
+			Block{Search: "", Replace: "package main\n"},
+			"package main\n",
+		},
+		{
+			"delete lines",
+			"a\nb\nc\n",
+			Block{Search: "b\n", Replace: ""},
+			"a\nc\n",
+		},
+	}
+	for _, tt := range tests {
+		got, err := ApplyBlock(tt.content, tt.block)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestApplyBlockErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		block   Block
+		want    string
+	}{
+		{"not found", "a\nb\n", Block{Path: "f.go", Search: "c\n"}, "SEARCH text not found in f.go"},
+		{"ambiguous", "x\nx\n", Block{Path: "f.go", Search: "x\n"}, "SEARCH text occurs 2 times in f.go"},
+		{"ambiguous after trimming", "x \nx\t\n", Block{Path: "f.go", Search: "x\n"}, "SEARCH text matches several places in f.go"},
+		{"empty search on existing file", "a\n", Block{Path: "f.go", Replace: "b\n"}, "empty SEARCH part for existing file f.go"},
+	}
+	for _, tt := range tests {
+		_, err := ApplyBlock(tt.content, tt.block)
+		if err == nil || err.Error() != tt.want {
+			t.Errorf("%s: error = %v, want %q", tt.name, err, tt.want)
+		}
+	}
+}
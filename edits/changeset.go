@@ -0,0 +1,261 @@
+package edits
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrNothingToUndo is returned by Undo when no change set has been recorded
+var ErrNothingToUndo = errors.New("nothing to undo")
+
+// ModifiedError is returned by Undo when files were changed again after the change set
+// was applied, so restoring them would lose those changes
+type ModifiedError struct {
+	Paths []string
+}
+
+// Error implements the error interface
+func (e *ModifiedError) Error() string {
+	return "changed since the change set was applied: " + strings.Join(e.Paths, ", ")
+}
+
+const manifestName = "manifest.json"
+
+// ChangeSet records the files touched by one apply operation so it can be undone
+type ChangeSet struct {
+	ID    string       `json:"id"`
+	Time  time.Time    `json:"time"`
+	Files []FileBackup `json:"files"`
+}
+
+// FileBackup is the state of a file before a change set was applied
+type FileBackup struct {
+	Path    string      `json:"path"`
+	Backup  string      `json:"backup,omitempty"`
+	Created bool        `json:"created"`
+	Mode    os.FileMode `json:"mode,omitempty"`
+	Hash    string      `json:"hash,omitempty"` // SHA-256 of the content written by the change set
+}
+
+// contentHash returns the hex SHA-256 of file content
+func contentHash(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// Apply backs up the current version of every file, then writes the new contents atomically.
+// The change set is stored under backupRoot so that Undo can restore it. If a write fails,
+// the files already written are restored and the backup is removed; only if that fails too
+// is the change set returned with the error, so that Undo can finish the job.
+func Apply(backupRoot string, files map[string]string) (*ChangeSet, error) {
+	now := time.Now()
+	cs := &ChangeSet{ID: strconv.FormatInt(now.UnixNano(), 10), Time: now}
+	dir := filepath.Join(backupRoot, cs.ID)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create backup directory: %w", err)
+	}
+
+	if err := backUp(dir, cs, files); err != nil {
+		_ = os.RemoveAll(dir)
+		return nil, err
+	}
+
+	for i, entry := range cs.Files {
+		if err := write(entry, files[entry.Path]); err != nil {
+			err = fmt.Errorf("failed to write %s: %w", entry.Path, err)
+			for _, written := range cs.Files[:i] {
+				if restoreErr := restore(dir, written); restoreErr != nil {
+					return cs, fmt.Errorf("%w; restoring the files written before also failed: %v", err, restoreErr)
+				}
+			}
+			_ = os.RemoveAll(dir)
+			return nil, err
+		}
+	}
+
+	return cs, nil
+}
+
+// backUp copies the files that exist to dir and records the change set's manifest there
+func backUp(dir string, cs *ChangeSet, files map[string]string) error {
+	// Write in a stable order so partial failures are predictable
+	paths := make([]string, 0, len(files))
+	for path := range files {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	for i, path := range paths {
+		entry := FileBackup{Path: path, Hash: contentHash([]byte(files[path]))}
+		info, err := os.Stat(path)
+		switch {
+		case err == nil:
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			entry.Backup = strconv.Itoa(i)
+			entry.Mode = info.Mode().Perm()
+			if err := os.WriteFile(filepath.Join(dir, entry.Backup), data, 0600); err != nil {
+				return fmt.Errorf("failed to back up %s: %w", path, err)
+			}
+		case os.IsNotExist(err):
+			entry.Created = true
+		default:
+			return err
+		}
+		cs.Files = append(cs.Files, entry)
+	}
+
+	return writeManifest(dir, cs)
+}
+
+// write stores the new content of a file, creating its directory if the file is new
+func write(entry FileBackup, content string) error {
+	mode := entry.Mode
+	if entry.Created {
+		mode = 0644
+		if err := os.MkdirAll(filepath.Dir(entry.Path), 0755); err != nil {
+			return err
+		}
+	}
+	return WriteFileAtomic(entry.Path, []byte(content), mode)
+}
+
+// restore puts a file back the way it was before the change set: removed if the change set
+// created it, otherwise rewritten from its backup in dir
+func restore(dir string, entry FileBackup) error {
+	if entry.Created {
+		if err := os.Remove(entry.Path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove %s: %w", entry.Path, err)
+		}
+		return nil
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, entry.Backup))
+	if err != nil {
+		return fmt.Errorf("failed to read backup of %s: %w", entry.Path, err)
+	}
+	if err := WriteFileAtomic(entry.Path, data, entry.Mode); err != nil {
+		return fmt.Errorf("failed to restore %s: %w", entry.Path, err)
+	}
+	return nil
+}
+
+// Undo restores the most recent change set and removes it from the backup directory.
+// Unless force is set, nothing is restored if a file was changed after the change set was
+// applied, and a *ModifiedError lists those files.
+func Undo(backupRoot string, force bool) (*ChangeSet, error) {
+	cs, dir, err := latest(backupRoot)
+	if err != nil {
+		return nil, err
+	}
+	if !force {
+		if modified := cs.modified(); len(modified) > 0 {
+			return cs, &ModifiedError{Paths: modified}
+		}
+	}
+
+	for _, entry := range cs.Files {
+		if err := restore(dir, entry); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := os.RemoveAll(dir); err != nil {
+		return cs, fmt.Errorf("restored files but could not remove backup: %w", err)
+	}
+	return cs, nil
+}
+
+// modified returns the files whose content is no longer what the change set wrote;
+// change sets recorded without hashes are not checked
+func (cs *ChangeSet) modified() []string {
+	var paths []string
+	for _, entry := range cs.Files {
+		if entry.Hash == "" {
+			continue
+		}
+		data, err := os.ReadFile(entry.Path)
+		if err != nil || contentHash(data) != entry.Hash {
+			paths = append(paths, entry.Path)
+		}
+	}
+	return paths
+}
+
+// latest returns the newest change set in backupRoot
+func latest(backupRoot string) (*ChangeSet, string, error) {
+	entries, err := os.ReadDir(backupRoot)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, "", ErrNothingToUndo
+		}
+		return nil, "", err
+	}
+
+	var ids []int64
+	for _, entry := range entries {
+		if id, err := strconv.ParseInt(entry.Name(), 10, 64); err == nil && entry.IsDir() {
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == 0 {
+		return nil, "", ErrNothingToUndo
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] > ids[j] })
+
+	dir := filepath.Join(backupRoot, strconv.FormatInt(ids[0], 10))
+	data, err := os.ReadFile(filepath.Join(dir, manifestName))
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read change set: %w", err)
+	}
+	var cs ChangeSet
+	if err := json.Unmarshal(data, &cs); err != nil {
+		return nil, "", fmt.Errorf("failed to parse change set: %w", err)
+	}
+	return &cs, dir, nil
+}
+
+func writeManifest(dir string, cs *ChangeSet) error {
+	data, err := json.MarshalIndent(cs, "", "  ")
+	if err != nil {
+		return err
+	}
+	return WriteFileAtomic(filepath.Join(dir, manifestName), data, 0600)
+}
+
+// WriteFileAtomic writes data to a temporary file in the same directory and renames it into place
+func WriteFileAtomic(path string, data []byte, mode os.FileMode) error {
+	if mode == 0 {
+		mode = 0644
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(mode); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
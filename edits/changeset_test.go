@@ -0,0 +1,140 @@
+package edits
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// readFile returns the content of a file, failing the test if it can't be read
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestApplyAndUndo(t *testing.T) {
+	dir := t.TempDir()
+	backups := filepath.Join(dir, "backups")
+	existing := filepath.Join(dir, "main.go")
+	created := filepath.Join(dir, "sub", "new.go")
+	if err := os.WriteFile(existing, []byte("old\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Apply(backups, map[string]string{existing: "new\n", created: "created\n"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := readFile(t, existing); got != "new\n" {
+		t.Errorf("main.go = %q after Apply", got)
+	}
+	if got := readFile(t, created); got != "created\n" {
+		t.Errorf("new.go = %q after Apply", got)
+	}
+
+	if _, err := Undo(backups, false); err != nil {
+		t.Fatal(err)
+	}
+	if got := readFile(t, existing); got != "old\n" {
+		t.Errorf("main.go = %q after Undo", got)
+	}
+	if info, err := os.Stat(existing); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("main.go mode not restored: %v %v", info, err)
+	}
+	if _, err := os.Stat(created); !os.IsNotExist(err) {
+		t.Errorf("created file not removed: %v", err)
+	}
+
+	if _, err := Undo(backups, false); !errors.Is(err, ErrNothingToUndo) {
+		t.Errorf("second Undo error = %v, want ErrNothingToUndo", err)
+	}
+}
+
+func TestUndoRefusesModifiedFiles(t *testing.T) {
+	dir := t.TempDir()
+	backups := filepath.Join(dir, "backups")
+	path := filepath.Join(dir, "main.go")
+	if err := os.WriteFile(path, []byte("old\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Apply(backups, map[string]string{path: "new\n"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte("edited by hand\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := Undo(backups, false)
+	var modified *ModifiedError
+	if !errors.As(err, &modified) || !reflect.DeepEqual(modified.Paths, []string{path}) {
+		t.Fatalf("Undo error = %v, want a ModifiedError for %s", err, path)
+	}
+	if got := readFile(t, path); got != "edited by hand\n" {
+		t.Errorf("main.go = %q after a refused Undo", got)
+	}
+
+	if _, err := Undo(backups, true); err != nil {
+		t.Fatal(err)
+	}
+	if got := readFile(t, path); got != "old\n" {
+		t.Errorf("main.go = %q after a forced Undo", got)
+	}
+}
+
+func TestUndoRestoresNewestChangeSet(t *testing.T) {
+	dir := t.TempDir()
+	backups := filepath.Join(dir, "backups")
+	path := filepath.Join(dir, "main.go")
+	if err := os.WriteFile(path, []byte("v1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, content := range []string{"v2\n", "v3\n"} {
+		if _, err := Apply(backups, map[string]string{path: content}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, want := range []string{"v2\n", "v1\n"} {
+		if _, err := Undo(backups, false); err != nil {
+			t.Fatal(err)
+		}
+		if got := readFile(t, path); got != want {
+			t.Errorf("main.go = %q, want %q", got, want)
+		}
+	}
+}
+
+func TestApplyRollsBackFailedWrite(t *testing.T) {
+	dir := t.TempDir()
+	backups := filepath.Join(dir, "backups")
+	existing := filepath.Join(dir, "b.go")
+	created := filepath.Join(dir, "c.go")
+	blocked := filepath.Join(dir, "d", "new.go") // "d" is a file, so this can't be written
+	for path, content := range map[string]string{existing: "old\n", filepath.Join(dir, "d"): "file\n"} {
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cs, err := Apply(backups, map[string]string{existing: "new\n", created: "created\n", blocked: "x\n"})
+	if err == nil || cs != nil {
+		t.Fatalf("Apply = %v, %v, want only an error", cs, err)
+	}
+	if got := readFile(t, existing); got != "old\n" {
+		t.Errorf("b.go = %q after a failed Apply", got)
+	}
+	if _, err := os.Stat(created); !os.IsNotExist(err) {
+		t.Errorf("c.go was left behind: %v", err)
+	}
+	if entries, err := os.ReadDir(backups); err != nil || len(entries) != 0 {
+		t.Errorf("backup directory not removed: %v %v", entries, err)
+	}
+	if _, err := Undo(backups, false); !errors.Is(err, ErrNothingToUndo) {
+		t.Errorf("Undo error = %v, want ErrNothingToUndo", err)
+	}
+}
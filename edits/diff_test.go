@@ -0,0 +1,51 @@
+package edits
+
+import "testing"
+
+func TestDiff(t *testing.T) {
+	tests := []struct {
+		name     string
+		old, new string
+		want     string
+	}{
+		{"unchanged", "a\n", "a\n", ""},
+		{"both empty", "", "", ""},
+		{
+			"new file", "", "a\nb\n",
+			"--- /dev/null\n+++ b/f.go\n@@ -0,0 +1,2 @@\n+a\n+b\n",
+		},
+		{
+			"emptied", "a\nb\n", "",
+			"--- a/f.go\n+++ b/f.go\n@@ -1,2 +0,0 @@\n-a\n-b\n",
+		},
+		{
+			"insert only", "a\nb\nc\n", "a\nb\nx\nc\n",
+			"--- a/f.go\n+++ b/f.go\n@@ -1,3 +1,4 @@\n a\n b\n+x\n c\n",
+		},
+		{
+			"delete only", "a\nb\nc\nd\n", "a\nd\n",
+			"--- a/f.go\n+++ b/f.go\n@@ -1,4 +1,2 @@\n a\n-b\n-c\n d\n",
+		},
+		{
+			"newline added", "a\nb", "a\nb\n",
+			"--- a/f.go\n+++ b/f.go\n@@ -1,2 +1,2 @@\n a\n-b\n\\ No newline at end of file\n+b\n",
+		},
+		{
+			"newline removed", "a\nb\n", "a\nb",
+			"--- a/f.go\n+++ b/f.go\n@@ -1,2 +1,2 @@\n a\n-b\n+b\n\\ No newline at end of file\n",
+		},
+		{
+			"no newline on either side", "a\nb", "a\nc",
+			"--- a/f.go\n+++ b/f.go\n@@ -1,2 +1,2 @@\n a\n-b\n\\ No newline at end of file\n+c\n\\ No newline at end of file\n",
+		},
+		{
+			"separate hunks", "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n", "x\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\ny\n",
+			"--- a/f.go\n+++ b/f.go\n@@ -1,4 +1,4 @@\n-1\n+x\n 2\n 3\n 4\n@@ -9,4 +9,4 @@\n 9\n 10\n 11\n-12\n+y\n",
+		},
+	}
+	for _, tt := range tests {
+		if got := Diff("f.go", tt.old, tt.new); got != tt.want {
+			t.Errorf("%s: Diff =\n%s\nwant\n%s", tt.name, got, tt.want)
+		}
+	}
+}
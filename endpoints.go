@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/ai-in-pm/Ollama-Code/api"
+	"github.com/spf13/cobra"
+)
+
+// endpointStatuses checks the configured Ollama endpoints, or the single api_url, and
+// returns their status in the order they are used for the configured model
+func endpointStatuses(ctx context.Context) ([]api.EndpointStatus, error) {
+	if _, err := ollamaClient(); err != nil {
+		return nil, err
+	}
+	p := endpointPool()
+	if p == nil {
+		p = api.NewPool([]api.Endpoint{{Client: newClient()}}, config.Model)
+	}
+	p.Check(ctx)
+	return p.Status(config.Model), nil
+}
+
+// formatEndpoints lists endpoints as a table with their health, version and models, marking
+// those that have the configured model loaded (*) or available (+)
+func formatEndpoints(statuses []api.EndpointStatus) string {
+	var sb strings.Builder
+	w := tabwriter.NewWriter(&sb, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "\tURL\tPRIORITY\tSTATUS\tVERSION\tLOADED\tMODELS")
+	for _, s := range statuses {
+		mark := ""
+		switch {
+		case api.HasModel(s.Loaded, config.Model):
+			mark = "*"
+		case api.HasModel(s.Models, config.Model):
+			mark = "+"
+		}
+		status := "ok"
+		switch {
+		case !s.Checked:
+			status = "not checked"
+		case !s.Healthy:
+			status = "down"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%s\t%s\t%d\n",
+			mark,
+			s.URL,
+			s.Priority,
+			status,
+			s.Version,
+			strings.Join(s.Loaded, ", "),
+			len(s.Models),
+		)
+	}
+	_ = w.Flush()
+
+	// Errors are too long for the table
+	for _, s := range statuses {
+		if s.Error != "" {
+			fmt.Fprintf(&sb, "\n%s: %s", s.URL, s.Error)
+		}
+	}
+	return strings.TrimRight(sb.String(), "\n")
+}
+
+// newEndpointsCmd creates the endpoints command
+func newEndpointsCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "endpoints",
+		Short: "Check the Ollama endpoints and show which models they have",
+		Long: `Checks every configured Ollama endpoint and lists them in the order requests for the
+configured model try them: endpoints that have the model loaded (*) or available (+) first,
+then by priority. Unhealthy endpoints come last.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
+			statuses, err := endpointStatuses(context.Background())
+			if err != nil {
+				return err
+			}
+			fmt.Println(formatEndpoints(statuses))
+			return nil
+		},
+	}
+}
@@ -0,0 +1,263 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ai-in-pm/Ollama-Code/api"
+	"github.com/ai-in-pm/Ollama-Code/history"
+	"github.com/ai-in-pm/Ollama-Code/ui"
+	"github.com/spf13/cobra"
+)
+
+// Conversation history state
+var (
+	historyStore   *history.Store
+	currentSession *history.Session
+)
+
+// maxToolDisplayLen limits how much of a tool result is shown when replaying a session
+const maxToolDisplayLen = 500
+
+// ensureSession returns the current session, starting a new one if needed
+func ensureSession() *history.Session {
+	if currentSession == nil {
+		workDir, _ := os.Getwd()
+		currentSession = history.NewSession(config.Model, workDir, generationOptions())
+	}
+	return currentSession
+}
+
+// recordMessages appends messages to the current session and persists it. Messages are
+// stored as they were sent, with secrets masked.
+func recordMessages(messages ...api.ChatMessage) error {
+	session := ensureSession()
+	for _, msg := range messages {
+		session.AddMessage(redactMessage(msg))
+	}
+	return saveSession(session)
+}
+
+// recordInterrupted records messages that end with a partial reply the user stopped
+func recordInterrupted(messages ...api.ChatMessage) error {
+	session := ensureSession()
+	for _, msg := range messages {
+		session.AddMessage(redactMessage(msg))
+	}
+	if last := len(session.Messages) - 1; last >= 0 && session.Messages[last].Role == api.RoleAssistant {
+		session.Messages[last].Interrupted = true
+	}
+	return saveSession(session)
+}
+
+// saveSession persists a session with the current model settings
+func saveSession(session *history.Session) error {
+	session.Model = config.Model
+	session.Options = generationOptions()
+
+	if historyStore == nil {
+		return nil
+	}
+	return historyStore.Save(session)
+}
+
+// displayMessages converts recorded messages into UI messages
+func displayMessages(session *history.Session) []ui.Message {
+	var messages []ui.Message
+	for _, msg := range session.Messages {
+		switch msg.Role {
+		case api.RoleUser, api.RoleAssistant:
+			if msg.Content == "" {
+				continue
+			}
+			content := msg.Content
+			if msg.Interrupted {
+				content += "\n[interrupted]"
+			}
+			messages = append(messages, ui.Message{Role: msg.Role, Content: content, Time: msg.Time})
+		case api.RoleTool:
+			content := msg.Content
+			if len(content) > maxToolDisplayLen {
+				content = content[:maxToolDisplayLen] + "..."
+			}
+			messages = append(messages, ui.Message{Role: "system", Content: fmt.Sprintf("[%s] %s", msg.ToolName, content), Time: msg.Time})
+		}
+	}
+	return messages
+}
+
+// resumeSession makes a stored session the current one and restores its model
+func resumeSession(id string) (*history.Session, error) {
+	if historyStore == nil {
+		return nil, fmt.Errorf("history is disabled (history_file_path is empty)")
+	}
+	session, err := historyStore.Get(id)
+	if err != nil {
+		return nil, err
+	}
+
+	currentSession = session
+	if session.Model != "" {
+		config.Model = session.Model
+	}
+	return session, nil
+}
+
+// formatSessionList renders a short listing of sessions
+func formatSessionList(sessions []*history.Session, limit int) string {
+	var sb strings.Builder
+	w := tabwriter.NewWriter(&sb, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tUPDATED\tMODEL\tMESSAGES\tTITLE")
+	for i, session := range sessions {
+		if limit > 0 && i >= limit {
+			break
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%s\n",
+			session.ID,
+			session.UpdatedAt.Local().Format(time.DateTime),
+			session.Model,
+			len(session.Messages),
+			session.Title,
+		)
+	}
+	_ = w.Flush()
+	return strings.TrimRight(sb.String(), "\n")
+}
+
+// handleResumeCommand implements the /resume slash command
+func handleResumeCommand(terminal *ui.TerminalUI, args []string) {
+	if historyStore == nil {
+		terminal.AddMessage("system", "History is disabled (history_file_path is empty)")
+		return
+	}
+
+	if len(args) == 0 {
+		sessions, err := historyStore.List()
+		if err != nil {
+			terminal.AddMessage("system", "Error: "+err.Error())
+			return
+		}
+		if len(sessions) == 0 {
+			terminal.AddMessage("system", "No saved sessions")
+			return
+		}
+		terminal.AddMessage("system", "Recent sessions (use /resume <id>):\n"+formatSessionList(sessions, 10))
+		return
+	}
+
+	session, err := resumeSession(args[0])
+	if err != nil {
+		terminal.AddMessage("system", "Error: "+err.Error())
+		return
+	}
+
+	terminal.AddMessage("system", fmt.Sprintf("Resumed session %s (%s, %d messages)", session.ID, session.Model, len(session.Messages)))
+	terminal.ReplayMessages(displayMessages(session))
+}
+
+// newHistoryCmd creates the history command group
+func newHistoryCmd() *cobra.Command {
+	historyCmd := &cobra.Command{
+		Use:   "history",
+		Short: "Manage saved conversations",
+		Long:  `List, show, resume and delete conversations saved in the history file.`,
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			if historyStore == nil {
+				return fmt.Errorf("history is disabled (history_file_path is empty)")
+			}
+			return nil
+		},
+	}
+
+	listCmd := &cobra.Command{
+		Use:   "list",
+		Short: "List saved sessions",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			sessions, err := historyStore.List()
+			if err != nil {
+				return err
+			}
+			if len(sessions) == 0 {
+				fmt.Println("No saved sessions.")
+				return nil
+			}
+			fmt.Println(formatSessionList(sessions, 0))
+			return nil
+		},
+	}
+
+	showCmd := &cobra.Command{
+		Use:   "show [id]",
+		Short: "Show the messages of a saved session",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			session, err := historyStore.Get(args[0])
+			if err != nil {
+				return err
+			}
+
+			fmt.Printf("Session:   %s\n", session.ID)
+			fmt.Printf("Model:     %s\n", session.Model)
+			fmt.Printf("Directory: %s\n", session.WorkingDir)
+			fmt.Printf("Created:   %s\n", session.CreatedAt.Local().Format(time.DateTime))
+			fmt.Printf("Updated:   %s\n", session.UpdatedAt.Local().Format(time.DateTime))
+			if session.Options != nil {
+				options, _ := json.Marshal(session.Options)
+				fmt.Printf("Options:   %s\n", options)
+			}
+			fmt.Println()
+
+			terminal := ui.NewTerminalUI()
+			for _, msg := range displayMessages(session) {
+				terminal.AddMessage(msg.Role, msg.Content)
+			}
+			return nil
+		},
+	}
+
+	resumeCmd := &cobra.Command{
+		Use:   "resume [id]",
+		Short: "Continue a saved session interactively",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			session, err := resumeSession(args[0])
+			if err != nil {
+				return err
+			}
+			interactiveSession(session)
+			return nil
+		},
+	}
+
+	var deleteAll bool
+	deleteCmd := &cobra.Command{
+		Use:   "delete [id...]",
+		Short: "Delete saved sessions",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if deleteAll {
+				if err := historyStore.Clear(); err != nil {
+					return err
+				}
+				fmt.Println("Deleted all sessions.")
+				return nil
+			}
+			if len(args) == 0 {
+				return fmt.Errorf("specify session IDs to delete or use --all")
+			}
+			if err := historyStore.Delete(args...); err != nil {
+				return err
+			}
+			fmt.Printf("Deleted %d session(s).\n", len(args))
+			return nil
+		},
+	}
+	deleteCmd.Flags().BoolVar(&deleteAll, "all", false, "Delete every saved session")
+
+	historyCmd.AddCommand(listCmd, showCmd, resumeCmd, deleteCmd)
+	return historyCmd
+}
@@ -0,0 +1,284 @@
+package history
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ai-in-pm/Ollama-Code/api"
+)
+
+// ErrNotFound is returned when no session matches an ID
+var ErrNotFound = errors.New("session not found")
+
+// maxTitleLen limits the length of a session title derived from the first prompt
+const maxTitleLen = 60
+
+// Message is a chat message with the time it was recorded
+type Message struct {
+	api.ChatMessage
+	Time time.Time `json:"time"`
+
+	// Interrupted marks a reply that the user stopped before it was complete
+	Interrupted bool `json:"interrupted,omitempty"`
+}
+
+// Session is a persisted conversation
+type Session struct {
+	ID         string       `json:"id"`
+	Title      string       `json:"title"`
+	Model      string       `json:"model"`
+	Options    *api.Options `json:"options,omitempty"`
+	WorkingDir string       `json:"working_dir"`
+	CreatedAt  time.Time    `json:"created_at"`
+	UpdatedAt  time.Time    `json:"updated_at"`
+	Messages   []Message    `json:"messages"`
+
+	// Summary condenses the first SummarizedCount messages once they no longer fit the context window
+	Summary         string `json:"summary,omitempty"`
+	SummarizedCount int    `json:"summarized_count,omitempty"`
+}
+
+// Store reads and writes sessions in a single JSON file
+type Store struct {
+	path  string
+	mutex sync.Mutex
+}
+
+type storeFile struct {
+	Sessions []*Session `json:"sessions"`
+}
+
+// NewStore creates a store backed by the given file
+func NewStore(path string) *Store {
+	return &Store{path: path}
+}
+
+// NewSession creates an empty session for the given model and working directory
+func NewSession(model string, workingDir string, options *api.Options) *Session {
+	now := time.Now()
+	return &Session{
+		ID:         newID(),
+		Model:      model,
+		Options:    options,
+		WorkingDir: workingDir,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+}
+
+// newID returns a short random hexadecimal identifier
+func newID() string {
+	buf := make([]byte, 4)
+	if _, err := rand.Read(buf); err != nil {
+		return fmt.Sprintf("%08x", time.Now().UnixNano()&0xffffffff)
+	}
+	return hex.EncodeToString(buf)
+}
+
+// AddMessage records a message and updates the session timestamp and title
+func (s *Session) AddMessage(msg api.ChatMessage) {
+	now := time.Now()
+	s.Messages = append(s.Messages, Message{ChatMessage: msg, Time: now})
+	s.UpdatedAt = now
+
+	if s.Title == "" && msg.Role == api.RoleUser {
+		s.Title = summarize(msg.Content)
+	}
+}
+
+// ChatMessages returns the session messages in the form expected by the chat API
+func (s *Session) ChatMessages() []api.ChatMessage {
+	messages := make([]api.ChatMessage, 0, len(s.Messages))
+	for _, msg := range s.Messages {
+		messages = append(messages, msg.ChatMessage)
+	}
+	return messages
+}
+
+// RecentMessages returns the messages not yet covered by the summary
+func (s *Session) RecentMessages() []api.ChatMessage {
+	if s.SummarizedCount >= len(s.Messages) {
+		return nil
+	}
+	messages := make([]api.ChatMessage, 0, len(s.Messages)-s.SummarizedCount)
+	for _, msg := range s.Messages[s.SummarizedCount:] {
+		messages = append(messages, msg.ChatMessage)
+	}
+	return messages
+}
+
+// summarize turns a prompt into a one-line title
+func summarize(content string) string {
+	title := strings.Join(strings.Fields(content), " ")
+	if len(title) > maxTitleLen {
+		title = title[:maxTitleLen-3] + "..."
+	}
+	return title
+}
+
+// List returns all sessions, most recently updated first
+func (st *Store) List() ([]*Session, error) {
+	st.mutex.Lock()
+	defer st.mutex.Unlock()
+
+	sessions, err := st.load()
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].UpdatedAt.After(sessions[j].UpdatedAt)
+	})
+	return sessions, nil
+}
+
+// Get returns the session with the given ID or unique ID prefix
+func (st *Store) Get(id string) (*Session, error) {
+	st.mutex.Lock()
+	defer st.mutex.Unlock()
+
+	sessions, err := st.load()
+	if err != nil {
+		return nil, err
+	}
+	index, err := find(sessions, id)
+	if err != nil {
+		return nil, err
+	}
+	return sessions[index], nil
+}
+
+// Save inserts or replaces a session
+func (st *Store) Save(session *Session) error {
+	st.mutex.Lock()
+	defer st.mutex.Unlock()
+
+	sessions, err := st.load()
+	if err != nil {
+		return err
+	}
+
+	replaced := false
+	for i, existing := range sessions {
+		if existing.ID == session.ID {
+			sessions[i] = session
+			replaced = true
+			break
+		}
+	}
+	if !replaced {
+		sessions = append(sessions, session)
+	}
+	return st.write(sessions)
+}
+
+// Delete removes the sessions with the given IDs or unique ID prefixes
+func (st *Store) Delete(ids ...string) error {
+	st.mutex.Lock()
+	defer st.mutex.Unlock()
+
+	sessions, err := st.load()
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		index, err := find(sessions, id)
+		if err != nil {
+			return err
+		}
+		sessions = append(sessions[:index], sessions[index+1:]...)
+	}
+	return st.write(sessions)
+}
+
+// Clear removes all sessions
+func (st *Store) Clear() error {
+	st.mutex.Lock()
+	defer st.mutex.Unlock()
+	return st.write(nil)
+}
+
+// find returns the index of the session matching an ID or unique ID prefix
+func find(sessions []*Session, id string) (int, error) {
+	match := -1
+	for i, session := range sessions {
+		if session.ID == id {
+			return i, nil
+		}
+		if id != "" && strings.HasPrefix(session.ID, id) {
+			if match >= 0 {
+				return -1, fmt.Errorf("session ID %q is ambiguous", id)
+			}
+			match = i
+		}
+	}
+	if match < 0 {
+		return -1, fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+	return match, nil
+}
+
+func (st *Store) load() ([]*Session, error) {
+	data, err := os.ReadFile(st.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read history: %w", err)
+	}
+	if len(strings.TrimSpace(string(data))) == 0 {
+		return nil, nil
+	}
+
+	var file storeFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse history file %s: %w", st.path, err)
+	}
+	return file.Sessions, nil
+}
+
+// write replaces the history file atomically
+func (st *Store) write(sessions []*Session) error {
+	if sessions == nil {
+		sessions = []*Session{}
+	}
+	data, err := json.MarshalIndent(storeFile{Sessions: sessions}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode history: %w", err)
+	}
+
+	dir := filepath.Dir(st.path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create history directory: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(dir, ".history-*.json")
+	if err != nil {
+		return fmt.Errorf("failed to write history: %w", err)
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("failed to write history: %w", err)
+	}
+	if err := tmp.Chmod(0600); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("failed to write history: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write history: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), st.path); err != nil {
+		return fmt.Errorf("failed to write history: %w", err)
+	}
+	return nil
+}
@@ -1,16 +1,22 @@
 package main
 
 import (
-	"bufio"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"strings"
+	"time"
 
+	"github.com/ai-in-pm/Ollama-Code/agent"
 	"github.com/ai-in-pm/Ollama-Code/api"
+	"github.com/ai-in-pm/Ollama-Code/context_manager"
+	"github.com/ai-in-pm/Ollama-Code/edits"
+	"github.com/ai-in-pm/Ollama-Code/history"
+	"github.com/ai-in-pm/Ollama-Code/redact"
 	"github.com/ai-in-pm/Ollama-Code/ui"
 	"github.com/spf13/cobra"
 )
@@ -19,13 +25,63 @@ import (
 type OllamaCodeConfig struct {
 	Model           string            `json:"model"`
 	ApiURL          string            `json:"api_url"`
-	ContextSize     int               `json:"context_size"`
+	Provider        string            `json:"provider"`          // "ollama", or "openai" for OpenAI-compatible servers
+	ApiKey          string            `json:"api_key,omitempty"` // Bearer token for the openai provider
+	ContextSize     int               `json:"context_size"`      // num_ctx; 0 uses the model's context length
+	MaxContextSize  int               `json:"max_context_size"`  // Upper limit of the automatic context size; 0 for none
 	Temperature     float64           `json:"temperature"`
 	TopP            float64           `json:"top_p"`
-	MaxTokens       int               `json:"max_tokens"`
+	MaxTokens       int               `json:"max_tokens"` // Sent to Ollama as num_predict
 	SystemPrompts   map[string]string `json:"system_prompts"`
 	HistoryFilePath string            `json:"history_file_path"`
 	KaliTools       []string          `json:"kali_tools,omitempty"`
+	ContextMode     string            `json:"context_mode"`
+	GitContext      bool              `json:"git_context"` // Add uncommitted changes, recent commits and blame to file context
+	BackupDir       string            `json:"backup_dir"`
+	EmbeddingModel  string            `json:"embedding_model"` // Used by search; empty matches file paths only
+
+	// Additional generation options; zero values leave the model defaults in place
+	TopK          int      `json:"top_k,omitempty"`
+	Seed          int      `json:"seed"`
+	RepeatPenalty float64  `json:"repeat_penalty,omitempty"`
+	RepeatLastN   int      `json:"repeat_last_n,omitempty"`
+	Stop          []string `json:"stop,omitempty"`
+	Mirostat      int      `json:"mirostat,omitempty"`
+	MirostatTau   float64  `json:"mirostat_tau,omitempty"`
+	MirostatEta   float64  `json:"mirostat_eta,omitempty"`
+
+	// Agent mode settings
+	AgentMaxIterations   int      `json:"agent_max_iterations"`
+	AgentAutoApprove     []string `json:"agent_auto_approve"`
+	AgentAllowedCommands []string `json:"agent_allowed_commands"`
+
+	// Retries for failed requests, with exponential backoff starting at RetryBackoffMs
+	MaxRetries     int `json:"max_retries"`
+	RetryBackoffMs int `json:"retry_backoff_ms"`
+
+	// Request timeouts in seconds; 0 disables a timeout
+	ConnectTimeout    int `json:"connect_timeout"`
+	FirstTokenTimeout int `json:"first_token_timeout"`
+	IdleTimeout       int `json:"idle_timeout"`
+
+	// Several Ollama servers to route requests to and fail over between; api_url is used
+	// when the list is empty
+	Endpoints           []EndpointConfig `json:"endpoints,omitempty"`
+	HealthCheckInterval int              `json:"health_check_interval"` // Seconds between health checks; 0 checks only at startup
+
+	// Secret redaction for everything sent to the model
+	Redact              bool              `json:"redact"`
+	RedactRules         map[string]string `json:"redact_rules,omitempty"` // Extra rules: name to pattern, masking the first group if any
+	RedactDisabledRules []string          `json:"redact_disabled_rules,omitempty"`
+	RedactAllowlist     []string          `json:"redact_allowlist,omitempty"` // Patterns of values that are never masked
+	RedactEntropy       float64           `json:"redact_entropy"`             // Bits per character; 0 disables entropy detection
+	RestoreSecrets      bool              `json:"restore_secrets"`            // Put the real values back into applied changes
+}
+
+// EndpointConfig is one Ollama server of the endpoints list
+type EndpointConfig struct {
+	URL      string `json:"url"`
+	Priority int    `json:"priority,omitempty"` // Lower values are preferred
 }
 
 // Global configuration
@@ -73,13 +129,31 @@ func initConfig() {
 
 	// Default configuration
 	config = OllamaCodeConfig{
-		Model:           "qwen2.5-coder:1.5b",
-		ApiURL:          "http://localhost:11434",
-		ContextSize:     8192,
-		Temperature:     0.2,
-		TopP:            0.95,
-		MaxTokens:       2048,
-		HistoryFilePath: filepath.Join(homeDir, ".ollama-code", "history.json"),
+		Model:                "qwen2.5-coder:1.5b",
+		ApiURL:               "http://localhost:11434",
+		Provider:             providerOllama,
+		MaxContextSize:       8192,
+		Temperature:          0.2,
+		TopP:                 0.95,
+		MaxTokens:            2048,
+		Seed:                 -1,
+		ContextMode:          contextRelated,
+		GitContext:           true,
+		EmbeddingModel:       "nomic-embed-text",
+		HistoryFilePath:      filepath.Join(homeDir, ".ollama-code", "history.json"),
+		BackupDir:            filepath.Join(homeDir, ".ollama-code", "backups"),
+		AgentMaxIterations:   agent.DefaultMaxIterations,
+		AgentAutoApprove:     []string{"read_file", "list_dir", "grep"},
+		AgentAllowedCommands: []string{},
+		MaxRetries:           api.DefaultRetryPolicy.MaxRetries,
+		RetryBackoffMs:       int(api.DefaultRetryPolicy.InitialBackoff / time.Millisecond),
+		ConnectTimeout:       int(api.DefaultTimeouts.Connect / time.Second),
+		FirstTokenTimeout:    int(api.DefaultTimeouts.FirstToken / time.Second),
+		IdleTimeout:          int(api.DefaultTimeouts.Idle / time.Second),
+		HealthCheckInterval:  30,
+		Redact:               true,
+		RedactEntropy:        redact.DefaultEntropyThreshold,
+		RestoreSecrets:       true,
 		SystemPrompts: map[string]string{
 			"generate": "You are an expert code generator optimized for Kali Linux environments. Create clean, efficient, and well-commented code based on the user's requirements. Focus on security tools integration when relevant.",
 			"explain":  "You are a code explanation expert with knowledge of Kali Linux and security tooling. Analyze the provided code and explain how it works in clear, concise terms. Focus on security implications when relevant.",
@@ -87,6 +161,9 @@ func initConfig() {
 			"debug":    "You are a debugging expert familiar with Kali Linux environments. Analyze the code and error messages to identify issues. Provide clear explanations of the bugs and suggest fixes with improved code.",
 			"test":     "You are a testing specialist for security-focused applications. Create comprehensive test cases for the provided code, covering edge cases, security vulnerabilities, and typical usage patterns.",
 			"doc":      "You are a documentation expert familiar with Kali Linux tools and conventions. Generate clear, concise documentation for the provided code, including function descriptions, parameters, return values, and usage examples.",
+			"review":   "You are a meticulous code reviewer with security expertise. Review changes for bugs, security vulnerabilities, error handling, edge cases and maintainability. Report only real problems in the changed code, each with a concrete fix.",
+			"agent":    agent.DefaultSystemPrompt,
+			"chat":     "You are Ollama Code, an AI coding assistant with knowledge of Kali Linux and security tooling. Answer questions about code clearly and concisely, and keep track of the conversation so far.",
 		},
 	}
 
@@ -109,6 +186,7 @@ func initConfig() {
 		data, err := os.ReadFile(configPath)
 		if err == nil {
 			_ = json.Unmarshal(data, &config)
+			migrateLegacyContextSize(data)
 		}
 	} else {
 		// Save default config
@@ -117,20 +195,30 @@ func initConfig() {
 	}
 }
 
-// BuildPrompt constructs a specialized prompt based on the task
-func buildPrompt(task string, language string, context string, userPrompt string) string {
-	systemMsg, ok := config.SystemPrompts[task]
-	if !ok {
-		systemMsg = "You are a helpful AI coding assistant."
+// legacyContextSize is the context_size that configurations were saved with before the
+// context window followed the model
+const legacyContextSize = 8192
+
+// migrateLegacyContextSize resets context_size to automatic in a configuration saved before
+// max_context_size existed, where 8192 was the default rather than the user's choice
+func migrateLegacyContextSize(data []byte) {
+	var keys map[string]json.RawMessage
+	if err := json.Unmarshal(data, &keys); err != nil {
+		return
 	}
+	if _, ok := keys["max_context_size"]; !ok && config.ContextSize == legacyContextSize {
+		config.ContextSize = 0
+	}
+}
 
-	return fmt.Sprintf(
-		"System: %s\nLanguage: %s\nContext:\n```\n%s\n```\n\nUser request: %s",
-		systemMsg,
-		language,
-		context,
-		userPrompt,
-	)
+// BuildPrompt constructs the user message for a task; the task's system prompt is sent separately.
+// The context is included as-is and is expected to fence its own code blocks.
+func buildPrompt(task string, language string, context string, userPrompt string) string {
+	prompt := fmt.Sprintf("Task: %s\nLanguage: %s\n", task, language)
+	if context != "" {
+		prompt += "Context:\n" + context + "\n"
+	}
+	return prompt + "\nUser request: " + userPrompt
 }
 
 // readFileContent reads and returns the content of a file
@@ -173,36 +261,49 @@ func detectLanguage(filePath string) string {
 	return "Unknown"
 }
 
-// Main function for handling interactive session
-func interactiveSession() {
+// Main function for handling interactive session; resume, if not nil, is a saved session to continue
+func interactiveSession(resume *history.Session) {
 	fmt.Println("Starting Ollama Code interactive session...")
 	fmt.Println("Model:", config.Model)
 	fmt.Println("Type 'exit' or 'quit' to end the session")
 	fmt.Println("Type '/help' for available commands")
 
-	client := api.NewClient(config.ApiURL, config.Model)
+	client := newProvider()
+
+	// Create terminal UI
+	terminal := ui.NewTerminalUI()
 
-	// Check if model exists
+	// Check if model exists, offering to download it from Ollama
 	models, err := client.ListModels(context.Background())
+	ollama, ollamaErr := ollamaClient()
 	if err != nil {
-		fmt.Printf("Warning: Could not verify model availability: %v\n", err)
-	} else {
-		modelExists := false
-		for _, model := range models {
-			if model == config.Model {
-				modelExists = true
-				break
+		fmt.Printf("Warning: Could not verify model availability: %s\n", describeError(err))
+	} else if !api.HasModel(models, config.Model) && ollamaErr != nil {
+		fmt.Printf("Warning: Model '%s' is not offered by the server. Available models: %v\n", config.Model, models)
+	} else if !api.HasModel(models, config.Model) {
+		fmt.Printf("Model '%s' is not downloaded. Available models: %v\n", config.Model, models)
+		if terminal.Confirm(fmt.Sprintf("Pull %s now?", config.Model)) {
+			ctx, cancel := terminal.Cancellable(context.Background())
+			err := pullModel(ctx, ollama, terminal, config.Model)
+			cancel()
+			if err != nil {
+				fmt.Printf("Warning: Could not pull %s: %s\n", config.Model, describeError(err))
 			}
+		} else {
+			fmt.Println("Pull it later with /pull, or choose another model with /model.")
 		}
+	}
 
-		if !modelExists {
-			fmt.Printf("Warning: Model '%s' not found. Available models: %v\n", config.Model, models)
-			fmt.Println("Using model anyway. If it doesn't exist, Ollama will attempt to download it.")
-		}
+	// The details are looked up once the model is there
+	fmt.Println("Model details:", describeModel())
+	if warning := contextSizeWarning(); warning != "" {
+		fmt.Println("Warning:", warning)
 	}
 
-	// Create terminal UI
-	terminal := ui.NewTerminalUI()
+	if resume != nil {
+		fmt.Printf("Resuming session %s (%d messages)\n", resume.ID, len(resume.Messages))
+		terminal.ReplayMessages(displayMessages(resume))
+	}
 
 	// Start UI in background
 	go func() {
@@ -213,15 +314,13 @@ func interactiveSession() {
 		}
 	}()
 
-	scanner := bufio.NewScanner(os.Stdin)
-
 	for {
-		fmt.Print("\nollama-code> ")
-		if !scanner.Scan() {
+		input, ok := terminal.ReadInput()
+		if !ok {
 			break
 		}
 
-		input := scanner.Text()
+		input = strings.TrimSpace(input)
 		if input == "exit" || input == "quit" {
 			break
 		}
@@ -232,12 +331,14 @@ func interactiveSession() {
 		}
 
 		// Regular prompt
-		handlePrompt(client, terminal, input, "")
+		handlePrompt(client, terminal, "chat", input, "")
 	}
+
+	terminal.Stop()
 }
 
 // Handle special commands
-func handleCommand(client *api.OllamaClient, terminal *ui.TerminalUI, input string) {
+func handleCommand(client api.Provider, terminal *ui.TerminalUI, input string) {
 	cmd := strings.TrimSpace(strings.TrimPrefix(input, "/"))
 	parts := strings.Fields(cmd)
 
@@ -249,14 +350,27 @@ func handleCommand(client *api.OllamaClient, terminal *ui.TerminalUI, input stri
 	case "help":
 		terminal.AddMessage("system", "Available commands:\n"+
 			"  /generate <description> - Generate code from description\n"+
-			"  /explain <file> - Explain code in file\n"+
-			"  /refactor <file> - Suggest refactoring for code\n"+
-			"  /debug <file> - Help debug code\n"+
+			"  /explain <file> [--context=none|file|related|project] [--no-git] - Explain code in file\n"+
+			"  /refactor <file> [--no-apply] - Refactor code and offer to apply the changes\n"+
+			"  /debug <file> [--no-apply] - Help debug code and offer to apply fixes\n"+
 			"  /test <file> - Generate tests for code\n"+
-			"  /doc <file> - Generate documentation\n"+
+			"  /doc <file> [--no-apply] - Generate documentation and offer to apply it\n"+
+			"  (<file> can also be file:START-END or file#Symbol to work on part of a file)\n"+
+			"  /undo - Revert the last applied change set\n"+
+			"  /search <query> - Find the files and lines most relevant to a question\n"+
+			"  /changes [--staged] [question] - Ask about the uncommitted changes and recent commits\n"+
+			"  /review [--staged|--range A..B] - Review the current git changes\n"+
+			"  /agent <task> - Let the model read, edit and run commands to complete a task\n"+
+			"  /resume [id] - List saved sessions or continue one\n"+
 			"  /model <modelname> - Change the model\n"+
-			"  /temp <value> - Change temperature (0.0-1.0)\n"+
-			"  /help - Show this help")
+			"  /models - List the downloaded models\n"+
+			"  /pull [model] - Download a model (the current one by default)\n"+
+			"  /endpoints - Check the Ollama endpoints and show which models they have\n"+
+			"  /temp <value> - Change temperature (0.0-2.0)\n"+
+			"  /set <option> <value> - Change a generation option (num_ctx, num_predict, top_k, seed, ...)\n"+
+			"  /options - Show the current generation options\n"+
+			"  /help - Show this help\n\n"+
+			"Press Esc to stop a response in progress (the partial answer is kept); Ctrl-C stops it too, or quits when idle")
 
 	case "generate", "explain", "refactor", "debug", "test", "doc":
 		if len(parts) < 2 {
@@ -265,22 +379,80 @@ func handleCommand(client *api.OllamaClient, terminal *ui.TerminalUI, input stri
 		}
 
 		task := parts[0]
-		arg := strings.Join(parts[1:], " ")
+		args, flags := parseTaskFlags(parts[1:])
+		arg := strings.Join(args, " ")
 
-		// Check if argument is a file path
-		fileInfo, err := os.Stat(arg)
-		if err == nil && !fileInfo.IsDir() {
-			content, err := readFileContent(arg)
+		// Check if argument is a file path, a line range or a symbol
+		target, isTarget, err := parseFileTarget(arg)
+		if err != nil {
+			terminal.AddMessage("system", "Error: "+err.Error())
+			return
+		}
+		if isTarget {
+			fileContext, err := buildFileContext(target, flags.mode, flags.git)
 			if err != nil {
 				terminal.AddMessage("system", "Error reading file: "+err.Error())
 				return
 			}
-			language := detectLanguage(arg)
-			handlePrompt(client, terminal, "", buildPrompt(task, language, content, ""))
+			language := detectLanguage(target.Path)
+			apply := flags.apply && editTasks[task]
+			response := handlePrompt(client, terminal, task, input, buildPrompt(task, language, fileContext, taskInstructions(target, apply)))
+			if apply {
+				applyProposedEdits(terminal, target.Path, response)
+			}
 		} else {
 			// Treat as direct prompt
-			handlePrompt(client, terminal, "", buildPrompt(task, "Unknown", "", arg))
+			handlePrompt(client, terminal, task, input, buildPrompt(task, "Unknown", "", arg))
+		}
+
+	case "changes":
+		args := parts[1:]
+		staged := len(args) > 0 && args[0] == "--staged"
+		if staged {
+			args = args[1:]
+		}
+		runChanges(client, terminal, input, strings.Join(args, " "), staged, defaultChangesCommits)
+
+	case "review":
+		source, err := parseReviewFlags(parts[1:])
+		if err != nil {
+			terminal.AddMessage("system", "Error: "+err.Error())
+			return
 		}
+		runReview(client, terminal, source)
+
+	case "agent":
+		if len(parts) < 2 {
+			terminal.AddMessage("system", "/agent requires a task description")
+			return
+		}
+		runAgent(client, terminal, strings.Join(parts[1:], " "))
+
+	case "resume":
+		handleResumeCommand(terminal, parts[1:])
+
+	case "undo":
+		message, err := undoLastChange(false)
+		var modified *edits.ModifiedError
+		if errors.As(err, &modified) {
+			if !terminal.Confirm("Files were " + modified.Error() + ". Restore them anyway and lose those changes?") {
+				terminal.AddMessage("system", "Undo canceled")
+				return
+			}
+			message, err = undoLastChange(true)
+		}
+		if err != nil {
+			terminal.AddMessage("system", "Error: "+err.Error())
+			return
+		}
+		terminal.AddMessage("system", message)
+
+	case "search":
+		if len(parts) < 2 {
+			terminal.AddMessage("system", "/search requires a query")
+			return
+		}
+		runSearch(client, terminal, strings.Join(parts[1:], " "), defaultSearchResults)
 
 	case "model":
 		if len(parts) < 2 {
@@ -288,33 +460,89 @@ func handleCommand(client *api.OllamaClient, terminal *ui.TerminalUI, input stri
 			return
 		}
 		config.Model = parts[1]
-		client.DefaultModel = parts[1]
-		terminal.AddMessage("system", "Model changed to: "+config.Model)
+		terminal.AddMessage("system", "Model changed to: "+describeModel())
+		if warning := contextSizeWarning(); warning != "" {
+			terminal.AddMessage("system", "Warning: "+warning)
+		}
 
 		// Save config
 		saveConfig()
 
+	case "models":
+		ollama, err := ollamaClient()
+		if err != nil {
+			terminal.AddMessage("system", "Error: "+err.Error())
+			return
+		}
+		models, err := ollama.ListLocalModels(context.Background())
+		if err != nil {
+			terminal.AddMessage("system", "Error: "+describeError(err))
+			return
+		}
+		terminal.AddMessage("system", formatModelList(models))
+
+	case "endpoints":
+		statuses, err := endpointStatuses(context.Background())
+		if err != nil {
+			terminal.AddMessage("system", "Error: "+err.Error())
+			return
+		}
+		terminal.AddMessage("system", formatEndpoints(statuses))
+
+	case "pull":
+		model := config.Model
+		if len(parts) > 1 {
+			model = parts[1]
+		}
+		ollama, err := ollamaClient()
+		if err != nil {
+			terminal.AddMessage("system", "Error: "+err.Error())
+			return
+		}
+		ctx, cancel := terminal.Cancellable(context.Background())
+		defer cancel()
+		if err := pullModel(ctx, ollama, terminal, model); err != nil {
+			terminal.AddMessage("system", "Error: "+describeError(err))
+			return
+		}
+		terminal.AddMessage("system", "Pulled "+model)
+
 	case "temp":
 		if len(parts) < 2 {
 			terminal.AddMessage("system", fmt.Sprintf("Current temperature: %.2f", config.Temperature))
 			return
 		}
 
-		// Parse temperature value
-		var temp float64
-		_, _ = fmt.Sscanf(parts[1], "%f", &temp)
-		if temp < 0 {
-			temp = 0
-		} else if temp > 1 {
-			temp = 1
+		// Same validation as /set temperature
+		if err := setGenerationOption("temperature", parts[1]); err != nil {
+			terminal.AddMessage("system", "Error: "+err.Error())
+			return
 		}
-
-		config.Temperature = temp
 		terminal.AddMessage("system", fmt.Sprintf("Temperature changed to: %.2f", config.Temperature))
 
 		// Save config
 		saveConfig()
 
+	case "set":
+		if len(parts) < 3 {
+			terminal.AddMessage("system", "Usage: /set <option> <value>\n"+formatGenerationOptions())
+			return
+		}
+		if err := setGenerationOption(parts[1], strings.Join(parts[2:], " ")); err != nil {
+			terminal.AddMessage("system", "Error: "+err.Error())
+			return
+		}
+		terminal.AddMessage("system", fmt.Sprintf("%s changed to: %s", parts[1], strings.Join(parts[2:], " ")))
+		if warning := contextSizeWarning(); parts[1] == "num_ctx" && warning != "" {
+			terminal.AddMessage("system", "Warning: "+warning)
+		}
+
+		// Save config
+		saveConfig()
+
+	case "options":
+		terminal.AddMessage("system", formatGenerationOptions())
+
 	default:
 		terminal.AddMessage("system", "Unknown command. Type /help for available commands")
 	}
@@ -335,9 +563,11 @@ func saveConfig() {
 	}
 }
 
-// Handle a user prompt
-func handlePrompt(client *api.OllamaClient, terminal *ui.TerminalUI, userInput string, formattedPrompt string) {
-	ctx := context.Background()
+// Handle a user prompt as the next turn of the current conversation, returning the model's answer
+func handlePrompt(client api.Provider, terminal *ui.TerminalUI, task string, userInput string, formattedPrompt string) string {
+	// Esc or Ctrl-C stops the request
+	ctx, cancel := terminal.Cancellable(context.Background())
+	defer cancel()
 
 	prompt := formattedPrompt
 	if prompt == "" {
@@ -345,39 +575,175 @@ func handlePrompt(client *api.OllamaClient, terminal *ui.TerminalUI, userInput s
 	}
 
 	terminal.AddMessage("user", userInput)
+	if err := requireCapability(config.Model, capabilityCompletion, "chat"); err != nil {
+		terminal.AddMessage("system", "Error: "+err.Error())
+		return ""
+	}
+
+	session := ensureSession()
+	systemMsg := systemPrompt(task)
+	userMsg := api.ChatMessage{Role: api.RoleUser, Content: prompt}
+
+	// Make room for the new turn before sending it
+	compactConversation(ctx, client, terminal, session, systemMsg, userMsg)
+	messages := append(conversationMessages(session, systemMsg), userMsg)
 
 	// Start spinning indicator
 	terminal.SetLoading(true, "Thinking...")
 
-	options := map[string]interface{}{
-		"temperature": config.Temperature,
-		"top_p":       config.TopP,
-		"max_tokens":  config.MaxTokens,
-	}
-
 	// Stream response from model
-	err := client.GenerateStream(ctx, &api.GenerateRequest{
-		Model:   config.Model,
-		Prompt:  prompt,
-		Options: options,
+	var reply api.ChatMessage
+	err := client.ChatStream(ctx, &api.ChatRequest{
+		Model:    config.Model,
+		Messages: messages,
+		Options:  generationOptions(),
 	}, func(resp interface{}) {
-		if genResp, ok := resp.(*api.GenerateResponse); ok {
-			terminal.StreamOutput(genResp.Response)
+		if chatResp, ok := resp.(*api.ChatResponse); ok {
+			reply.Append(chatResp.Message)
+			terminal.StreamOutput(chatResp.Message.Content)
 		}
 	})
+	reportRedactions(terminal)
 
 	if err != nil {
 		terminal.SetLoading(false, "")
+		if errors.Is(err, api.ErrCanceled) && reply.Content != "" {
+			// Keep what the model said so far; it is not a complete answer, so nothing is returned
+			reply.Role = api.RoleAssistant
+			if err := recordInterrupted(userMsg, reply); err != nil {
+				terminal.AddMessage("system", "Warning: could not save history: "+err.Error())
+			}
+			terminal.AddMessage("system", "Interrupted; the partial answer was kept")
+			return ""
+		}
+		terminal.AddMessage("system", "Error: "+describeError(err))
+		return ""
+	}
+	terminal.SetLoading(false, "")
+
+	reply.Role = api.RoleAssistant
+	if err := recordMessages(userMsg, reply); err != nil {
+		terminal.AddMessage("system", "Warning: could not save history: "+err.Error())
+	}
+	return reply.Content
+}
+
+// runAgent lets the model work on a task with the built-in tools, asking before any
+// action that is not covered by the configured allowlists
+func runAgent(client api.Provider, terminal *ui.TerminalUI, task string) {
+	workDir, err := os.Getwd()
+	if err != nil {
 		terminal.AddMessage("system", "Error: "+err.Error())
-	} else {
-		terminal.SetLoading(false, "")
+		return
+	}
+
+	for _, capability := range []string{capabilityCompletion, capabilityTools} {
+		if err := requireCapability(config.Model, capability, "agent mode"); err != nil {
+			terminal.AddMessage("system", "Error: "+err.Error())
+			return
+		}
+	}
+
+	systemMsg, ok := config.SystemPrompts["agent"]
+	if !ok {
+		systemMsg = agent.DefaultSystemPrompt
+	}
+
+	// The agent continues the current conversation
+	session := ensureSession()
+	taskMsg := api.ChatMessage{Role: api.RoleUser, Content: task}
+	ctx, cancel := terminal.Cancellable(context.Background())
+	defer cancel()
+	compactConversation(ctx, client, terminal, session, systemMsg, taskMsg)
+	conversation := append(conversationMessages(session, systemMsg), taskMsg)
+
+	cm := context_manager.NewContextManager(workDir)
+	a := agent.NewAgent(client, config.Model, &agent.Workspace{Root: workDir, Ignore: cm.ShouldIgnore})
+	a.Options = generationOptions()
+	a.MaxIterations = config.AgentMaxIterations
+	a.AutoApprove = config.AgentAutoApprove
+	a.AllowedCommands = config.AgentAllowedCommands
+	a.Approve = func(action agent.Action) bool {
+		return terminal.Confirm("Allow " + action.Summary + "?")
+	}
+	a.OnContent = terminal.StreamOutput
+	a.OnToolResult = func(action agent.Action, result string) {
+		terminal.AddMessage("system", fmt.Sprintf("[%s] %s", action.Tool, action.Summary))
+	}
+	a.Unmask = restoreSecrets
+	a.UnmaskSearch = unmaskSearch
+
+	terminal.AddMessage("user", task)
+	terminal.SetLoading(true, "Working...")
+
+	messages, err := a.Run(ctx, conversation)
+
+	terminal.SetLoading(false, "")
+	reportRedactions(terminal)
+	record := recordMessages
+	if errors.Is(err, api.ErrCanceled) {
+		terminal.AddMessage("system", "Interrupted; the agent was stopped")
+		record = recordInterrupted
+	} else if err != nil {
+		terminal.AddMessage("system", "Error: "+describeError(err))
+	}
+
+	// Save the task and everything the agent added, including partial runs
+	if err := record(messages[len(conversation)-1:]...); err != nil {
+		terminal.AddMessage("system", "Warning: could not save history: "+err.Error())
+	}
+}
+
+// newFileCommand creates a command that runs a task on a single file with project context
+func newFileCommand(task string, short string) *cobra.Command {
+	var contextMode string
+	var apply bool
+	var git bool
+
+	cmd := &cobra.Command{
+		Use:   task + " [file | file:START-END | file#Symbol]",
+		Short: short,
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			target, isTarget, err := parseFileTarget(args[0])
+			if err == nil && !isTarget {
+				err = fmt.Errorf("%s is not a file, line range or symbol", args[0])
+			}
+			if err != nil {
+				fmt.Println("Error:", err)
+				os.Exit(1)
+			}
+			fileContext, err := buildFileContext(target, contextMode, git)
+			if err != nil {
+				fmt.Println("Error reading file:", err)
+				os.Exit(1)
+			}
+			language := detectLanguage(target.Path)
+			client := newProvider()
+			terminal := ui.NewTerminalUI()
+
+			response := handlePrompt(client, terminal, task, "", buildPrompt(task, language, fileContext, taskInstructions(target, apply)))
+			if apply {
+				applyProposedEdits(terminal, target.Path, response)
+			}
+		},
 	}
+	cmd.Flags().StringVar(&contextMode, "context", config.ContextMode, "Project context to include: none, file, related or project")
+	cmd.Flags().BoolVar(&git, "git", config.GitContext, "Include uncommitted changes, recent commits and blame of the file")
+	if editTasks[task] {
+		cmd.Flags().BoolVar(&apply, "apply", true, "Offer to apply the proposed changes to disk")
+	}
+	return cmd
 }
 
 func main() {
 	// Initialize configuration
 	initConfig()
 
+	if config.HistoryFilePath != "" {
+		historyStore = history.NewStore(config.HistoryFilePath)
+	}
+
 	// Create root command
 	rootCmd := &cobra.Command{
 		Use:   "ollama-code",
@@ -386,7 +752,7 @@ func main() {
 		Run: func(cmd *cobra.Command, args []string) {
 			// If no arguments provided, start interactive session
 			if len(args) == 0 {
-				interactiveSession()
+				interactiveSession(nil)
 				return
 			}
 
@@ -394,19 +760,33 @@ func main() {
 			prompt := strings.Join(args, " ")
 
 			// Create client
-			client := api.NewClient(config.ApiURL, config.Model)
+			client := newProvider()
 
 			// Create terminal UI
 			terminal := ui.NewTerminalUI()
 
-			handlePrompt(client, terminal, prompt, "")
+			handlePrompt(client, terminal, "chat", prompt, "")
 		},
 	}
 
+	// Reject an unknown provider once the flags are parsed; --api replaces the endpoints list
+	cobra.OnInitialize(func() {
+		if rootCmd.PersistentFlags().Lookup("api").Changed {
+			config.Endpoints = nil
+		}
+		if config.Provider != providerOllama && config.Provider != providerOpenAI {
+			fmt.Fprintf(os.Stderr, "Error: unknown provider %q (use %s or %s)\n", config.Provider, providerOllama, providerOpenAI)
+			os.Exit(1)
+		}
+	})
+
 	// Define command line flags
 	rootCmd.PersistentFlags().StringVarP(&config.Model, "model", "m", config.Model, "Specify the Ollama model to use")
 	rootCmd.PersistentFlags().Float64VarP(&config.Temperature, "temperature", "t", config.Temperature, "Set the temperature for model responses")
-	rootCmd.PersistentFlags().StringVarP(&config.ApiURL, "api", "a", config.ApiURL, "Ollama API URL")
+	rootCmd.PersistentFlags().StringVarP(&config.ApiURL, "api", "a", config.ApiURL, "API URL of the server (Ollama, or e.g. http://localhost:8080/v1 for the openai provider)")
+	rootCmd.PersistentFlags().StringVar(&config.Provider, "provider", config.Provider, "Server protocol: ollama or openai")
+	rootCmd.PersistentFlags().IntVar(&config.MaxRetries, "retries", config.MaxRetries, "Number of retries for failed requests (0 to disable)")
+	addGenerationFlags(rootCmd)
 
 	// Add subcommands
 	generateCmd := &cobra.Command{
@@ -415,103 +795,19 @@ func main() {
 		Args:  cobra.MinimumNArgs(1),
 		Run: func(cmd *cobra.Command, args []string) {
 			prompt := strings.Join(args, " ")
-			client := api.NewClient(config.ApiURL, config.Model)
-			terminal := ui.NewTerminalUI()
-			handlePrompt(client, terminal, "", buildPrompt("generate", "Unknown", "", prompt))
-		},
-	}
-
-	explainCmd := &cobra.Command{
-		Use:   "explain [file]",
-		Short: "Explain code in file",
-		Args:  cobra.ExactArgs(1),
-		Run: func(cmd *cobra.Command, args []string) {
-			filePath := args[0]
-			content, err := readFileContent(filePath)
-			if err != nil {
-				fmt.Println("Error reading file:", err)
-				os.Exit(1)
-			}
-			language := detectLanguage(filePath)
-			client := api.NewClient(config.ApiURL, config.Model)
-			terminal := ui.NewTerminalUI()
-			handlePrompt(client, terminal, "", buildPrompt("explain", language, content, ""))
-		},
-	}
-
-	refactorCmd := &cobra.Command{
-		Use:   "refactor [file]",
-		Short: "Suggest refactoring for code",
-		Args:  cobra.ExactArgs(1),
-		Run: func(cmd *cobra.Command, args []string) {
-			filePath := args[0]
-			content, err := readFileContent(filePath)
-			if err != nil {
-				fmt.Println("Error reading file:", err)
-				os.Exit(1)
-			}
-			language := detectLanguage(filePath)
-			client := api.NewClient(config.ApiURL, config.Model)
-			terminal := ui.NewTerminalUI()
-			handlePrompt(client, terminal, "", buildPrompt("refactor", language, content, ""))
-		},
-	}
-
-	debugCmd := &cobra.Command{
-		Use:   "debug [file]",
-		Short: "Help debug code",
-		Args:  cobra.ExactArgs(1),
-		Run: func(cmd *cobra.Command, args []string) {
-			filePath := args[0]
-			content, err := readFileContent(filePath)
-			if err != nil {
-				fmt.Println("Error reading file:", err)
-				os.Exit(1)
-			}
-			language := detectLanguage(filePath)
-			client := api.NewClient(config.ApiURL, config.Model)
+			client := newProvider()
 			terminal := ui.NewTerminalUI()
-			handlePrompt(client, terminal, "", buildPrompt("debug", language, content, ""))
+			handlePrompt(client, terminal, "generate", "", buildPrompt("generate", "Unknown", "", prompt))
 		},
 	}
 
-	testCmd := &cobra.Command{
-		Use:   "test [file]",
-		Short: "Generate tests for code",
-		Args:  cobra.ExactArgs(1),
-		Run: func(cmd *cobra.Command, args []string) {
-			filePath := args[0]
-			content, err := readFileContent(filePath)
-			if err != nil {
-				fmt.Println("Error reading file:", err)
-				os.Exit(1)
-			}
-			language := detectLanguage(filePath)
-			client := api.NewClient(config.ApiURL, config.Model)
-			terminal := ui.NewTerminalUI()
-			handlePrompt(client, terminal, "", buildPrompt("test", language, content, ""))
-		},
-	}
-
-	docCmd := &cobra.Command{
-		Use:   "doc [file]",
-		Short: "Generate documentation",
-		Args:  cobra.ExactArgs(1),
-		Run: func(cmd *cobra.Command, args []string) {
-			filePath := args[0]
-			content, err := readFileContent(filePath)
-			if err != nil {
-				fmt.Println("Error reading file:", err)
-				os.Exit(1)
-			}
-			language := detectLanguage(filePath)
-			client := api.NewClient(config.ApiURL, config.Model)
-			terminal := ui.NewTerminalUI()
-			handlePrompt(client, terminal, "", buildPrompt("doc", language, content, ""))
-		},
-	}
+	explainCmd := newFileCommand("explain", "Explain code in file")
+	refactorCmd := newFileCommand("refactor", "Suggest refactoring for code")
+	debugCmd := newFileCommand("debug", "Help debug code")
+	testCmd := newFileCommand("test", "Generate tests for code")
+	docCmd := newFileCommand("doc", "Generate documentation")
 
-	rootCmd.AddCommand(generateCmd, explainCmd, refactorCmd, debugCmd, testCmd, docCmd)
+	rootCmd.AddCommand(generateCmd, explainCmd, refactorCmd, debugCmd, testCmd, docCmd, newHistoryCmd(), newUndoCmd(), newSearchCmd(), newChangesCmd(), newReviewCmd(), newModelsCmd(), newEndpointsCmd())
 
 	// Add Kali Linux specific commands
 	if isKaliLinux() {
@@ -571,9 +867,9 @@ func main() {
 				)
 
 				// Call the API
-				client := api.NewClient(config.ApiURL, config.Model)
+				client := newProvider()
 				terminal := ui.NewTerminalUI()
-				handlePrompt(client, terminal, "", buildPrompt("generate", "Security", "", prompt))
+				handlePrompt(client, terminal, "generate", "", buildPrompt("generate", "Security", "", prompt))
 			},
 		}
 
@@ -0,0 +1,259 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ai-in-pm/Ollama-Code/api"
+)
+
+// Model capabilities reported by /api/show
+const (
+	capabilityCompletion = "completion"
+	capabilityTools      = "tools"
+	capabilityEmbedding  = "embedding"
+)
+
+// modelInfoTTL is how long cached model details are used before asking the server again
+const modelInfoTTL = 24 * time.Hour
+
+// modelInfoTimeout bounds the lookup of model details, which must not hold up a request
+const modelInfoTimeout = 5 * time.Second
+
+// fallbackContextSize is used when context_size is 0 and the model's context length is unknown
+const fallbackContextSize = 8192
+
+// modelInfo is what Ollama Code needs to know about a model
+type modelInfo struct {
+	ContextLength int       `json:"context_length,omitempty"` // Trained context length in tokens; 0 if unknown
+	Family        string    `json:"family,omitempty"`
+	ParameterSize string    `json:"parameter_size,omitempty"`
+	Quantization  string    `json:"quantization,omitempty"`
+	Capabilities  []string  `json:"capabilities,omitempty"` // Empty for servers that don't report them
+	FetchedAt     time.Time `json:"fetched_at"`
+}
+
+var (
+	modelInfoMutex sync.Mutex
+	modelInfos     = make(map[string]*modelInfo) // Looked up in this process by modelInfoKey; nil if the lookup failed
+)
+
+// newModelInfo extracts the model details from an /api/show response
+func newModelInfo(show *api.ShowResponse) *modelInfo {
+	return &modelInfo{
+		ContextLength: show.ContextLength(),
+		Family:        show.Details.Family,
+		ParameterSize: show.Details.ParameterSize,
+		Quantization:  show.Details.QuantizationLevel,
+		Capabilities:  show.Capabilities,
+		FetchedAt:     time.Now(),
+	}
+}
+
+// supports reports whether the model has a capability; models whose capabilities are not
+// known are assumed to have it
+func (m *modelInfo) supports(capability string) bool {
+	if m == nil || len(m.Capabilities) == 0 {
+		return true
+	}
+	for _, c := range m.Capabilities {
+		if c == capability {
+			return true
+		}
+	}
+	return false
+}
+
+// summary describes the model in a few words, e.g. "32768-token context, 7.6B Q4_K_M,
+// completion, tools"
+func (m *modelInfo) summary() string {
+	var parts []string
+	if m.ContextLength > 0 {
+		parts = append(parts, fmt.Sprintf("%d-token context", m.ContextLength))
+	}
+	if size := strings.TrimSpace(m.ParameterSize + " " + m.Quantization); size != "" {
+		parts = append(parts, size)
+	}
+	if len(m.Capabilities) > 0 {
+		parts = append(parts, strings.Join(m.Capabilities, ", "))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// modelInfoCachePath returns the file caching model details between runs
+func modelInfoCachePath() (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(homeDir, ".ollama-code", "models.json"), nil
+}
+
+// modelInfoKey identifies the details of a model on a server: the same name can be a
+// different model on another server
+func modelInfoKey(baseURL string, model string) string {
+	return strings.TrimRight(baseURL, "/") + " " + model
+}
+
+// loadModelInfoCache reads the cached model details by modelInfoKey; a missing or damaged
+// cache is empty
+func loadModelInfoCache() map[string]*modelInfo {
+	cache := make(map[string]*modelInfo)
+	path, err := modelInfoCachePath()
+	if err != nil {
+		return cache
+	}
+	if data, err := os.ReadFile(path); err == nil {
+		_ = json.Unmarshal(data, &cache)
+	}
+	return cache
+}
+
+// updateModelInfoCache stores or, with a nil info, removes the cached details of a model
+// by modelInfoKey
+func updateModelInfoCache(key string, info *modelInfo) {
+	path, err := modelInfoCachePath()
+	if err != nil {
+		return
+	}
+	cache := loadModelInfoCache()
+	if info == nil {
+		delete(cache, key)
+	} else {
+		cache[key] = info
+	}
+	data, err := json.MarshalIndent(cache, "", "  ")
+	if err != nil {
+		return
+	}
+	_ = os.MkdirAll(filepath.Dir(path), 0755)
+	_ = os.WriteFile(path, data, 0644)
+}
+
+// lookupModelInfo returns the details of a model from the cache or the server, or nil if
+// they cannot be found. A failed lookup is not repeated within the same run.
+func lookupModelInfo(model string) *modelInfo {
+	// Only Ollama describes its models
+	ollama, err := ollamaClientFor(model)
+	if err != nil {
+		return nil
+	}
+	key := modelInfoKey(ollama.BaseURL, model)
+	modelInfoMutex.Lock()
+	info, ok := modelInfos[key]
+	modelInfoMutex.Unlock()
+	if ok {
+		return info
+	}
+
+	cached := loadModelInfoCache()[key]
+	if cached != nil && time.Since(cached.FetchedAt) < modelInfoTTL {
+		storeModelInfo(key, cached)
+		return cached
+	}
+
+	// A quick, single attempt: the request the details are needed for reports errors itself.
+	// The lock is not held meanwhile, so other lookups don't wait for a slow server.
+	client := *ollama
+	client.Retry.MaxRetries = 0
+	ctx, cancel := context.WithTimeout(context.Background(), modelInfoTimeout)
+	defer cancel()
+	show, err := client.Show(ctx, model)
+	if err != nil {
+		// Stale details are better than none
+		storeModelInfo(key, cached)
+		return cached
+	}
+
+	info = newModelInfo(show)
+	storeModelInfo(key, info)
+	updateModelInfoCache(key, info)
+	return info
+}
+
+// storeModelInfo remembers the details of a model for the rest of the run
+func storeModelInfo(key string, info *modelInfo) {
+	modelInfoMutex.Lock()
+	modelInfos[key] = info
+	modelInfoMutex.Unlock()
+}
+
+// rememberModelInfo caches the details of a model on a server that were just fetched
+func rememberModelInfo(client *api.OllamaClient, model string, show *api.ShowResponse) {
+	key := modelInfoKey(client.BaseURL, model)
+	info := newModelInfo(show)
+	storeModelInfo(key, info)
+	updateModelInfoCache(key, info)
+}
+
+// forgetModelInfo drops the cached details of a model on a server that was pulled or deleted
+func forgetModelInfo(client *api.OllamaClient, model string) {
+	key := modelInfoKey(client.BaseURL, model)
+	modelInfoMutex.Lock()
+	delete(modelInfos, key)
+	modelInfoMutex.Unlock()
+	updateModelInfoCache(key, nil)
+}
+
+// contextSize returns the context window to use in tokens (num_ctx): context_size if it is
+// set, but no more than the model supports, or else the model's context length up to
+// max_context_size
+func contextSize() int {
+	info := lookupModelInfo(config.Model)
+	if config.ContextSize > 0 {
+		if info != nil && info.ContextLength > 0 {
+			return min(config.ContextSize, info.ContextLength)
+		}
+		return config.ContextSize
+	}
+
+	if info == nil || info.ContextLength <= 0 {
+		return fallbackContextSize
+	}
+	if config.MaxContextSize > 0 {
+		return min(info.ContextLength, config.MaxContextSize)
+	}
+	return info.ContextLength
+}
+
+// contextSizeWarning explains when context_size is larger than the model supports, or
+// returns ""
+func contextSizeWarning() string {
+	info := lookupModelInfo(config.Model)
+	if config.ContextSize <= 0 || info == nil || info.ContextLength <= 0 || config.ContextSize <= info.ContextLength {
+		return ""
+	}
+	return fmt.Sprintf("context_size %d is larger than the %d tokens %s supports; using %d", config.ContextSize, info.ContextLength, config.Model, info.ContextLength)
+}
+
+// requireCapability returns an error if a model is known to lack a capability a task needs
+func requireCapability(model string, capability string, task string) error {
+	info := lookupModelInfo(model)
+	if info.supports(capability) {
+		return nil
+	}
+	err := fmt.Sprintf("model %s does not support %s, which %s needs (it supports %s)", model, capability, task, strings.Join(info.Capabilities, ", "))
+	if model == config.Model {
+		err += "; choose another model with --model or /model"
+	}
+	return errors.New(err)
+}
+
+// describeModel returns a line describing the configured model for the user
+func describeModel() string {
+	info := lookupModelInfo(config.Model)
+	if info == nil {
+		return config.Model
+	}
+	if summary := info.summary(); summary != "" {
+		return fmt.Sprintf("%s (%s)", config.Model, summary)
+	}
+	return config.Model
+}
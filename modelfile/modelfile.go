@@ -0,0 +1,293 @@
+// Package modelfile reads and writes Ollama Modelfiles and turns them into create requests
+package modelfile
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ai-in-pm/Ollama-Code/api"
+)
+
+// Instructions of a Modelfile
+const (
+	From      = "from"
+	Adapter   = "adapter"
+	Parameter = "parameter"
+	Template  = "template"
+	System    = "system"
+	License   = "license"
+	Message   = "message"
+)
+
+// ErrNoFrom is returned for a Modelfile without a FROM instruction
+var ErrNoFrom = errors.New("the Modelfile has no FROM instruction")
+
+// Command is one instruction of a Modelfile
+type Command struct {
+	Name  string // One of the instruction constants
+	Key   string // The option name of PARAMETER and the role of MESSAGE; empty otherwise
+	Value string
+}
+
+// Modelfile is a parsed Modelfile
+type Modelfile struct {
+	Commands []Command
+}
+
+// Add appends an instruction
+func (m *Modelfile) Add(name string, key string, value string) {
+	m.Commands = append(m.Commands, Command{Name: name, Key: key, Value: value})
+}
+
+// Parse reads a Modelfile. Instructions are case-insensitive, lines starting with # are
+// comments, and a value can span several lines between triple quotes.
+func Parse(r io.Reader) (*Modelfile, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	m := &Modelfile{}
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		start := lineNum
+		instruction, rest, _ := strings.Cut(line, " ")
+		name := strings.ToLower(instruction)
+		rest = strings.TrimSpace(rest)
+
+		var key string
+		switch name {
+		case From, Adapter, Template, System, License:
+		case Parameter, Message:
+			key, rest, _ = strings.Cut(rest, " ")
+			rest = strings.TrimSpace(rest)
+			if key == "" {
+				return nil, fmt.Errorf("line %d: %s needs a name and a value", start, instruction)
+			}
+			if name == Parameter {
+				key = strings.ToLower(key)
+			}
+		default:
+			return nil, fmt.Errorf("line %d: unknown instruction %q", start, instruction)
+		}
+
+		value := rest
+		if strings.HasPrefix(rest, `"""`) {
+			// Read up to the closing triple quotes, which may be on a later line
+			text := strings.TrimPrefix(rest, `"""`)
+			for {
+				if before, _, ok := strings.Cut(text, `"""`); ok {
+					value = before
+					break
+				}
+				if !scanner.Scan() {
+					return nil, fmt.Errorf("line %d: unterminated \"\"\" in %s", start, instruction)
+				}
+				lineNum++
+				text += "\n" + scanner.Text()
+			}
+		} else if len(rest) >= 2 && strings.HasPrefix(rest, `"`) && strings.HasSuffix(rest, `"`) {
+			if unquoted, err := strconv.Unquote(rest); err == nil {
+				value = unquoted
+			} else {
+				value = rest[1 : len(rest)-1]
+			}
+		}
+		if value == "" && name != System && name != Template {
+			return nil, fmt.Errorf("line %d: %s needs a value", start, instruction)
+		}
+		m.Add(name, key, value)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read Modelfile: %w", err)
+	}
+
+	for _, c := range m.Commands {
+		if c.Name == From {
+			return m, nil
+		}
+	}
+	return nil, ErrNoFrom
+}
+
+// ParseFile reads a Modelfile from a file
+func ParseFile(path string) (*Modelfile, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+	return Parse(f)
+}
+
+// String formats the Modelfile, one instruction per line with multi-line or quoted values
+// between triple quotes. Values that triple quotes can't hold, because they contain """ or
+// end with a quote, are written as a Go-style quoted string, which Parse reads back.
+func (m *Modelfile) String() string {
+	var sb strings.Builder
+	for _, c := range m.Commands {
+		sb.WriteString(strings.ToUpper(c.Name))
+		if c.Key != "" {
+			sb.WriteString(" " + c.Key)
+		}
+		value := c.Value
+		switch {
+		case strings.Contains(value, `"""`) || strings.HasSuffix(value, `"`):
+			value = strconv.Quote(value)
+		case value == "" || strings.ContainsAny(value, "\n\"") || value != strings.TrimSpace(value):
+			value = `"""` + value + `"""`
+		}
+		sb.WriteString(" " + value + "\n")
+	}
+	return sb.String()
+}
+
+// Request turns the Modelfile into a request creating the named model. ADAPTER values and
+// FROM values that look like paths (starting with ./, ../, / or ~, or ending in .gguf) name
+// local files or directories, relative to dir; they are passed to upload, which returns
+// their file names and blob digests. Other FROM values name a base model.
+func (m *Modelfile) Request(model string, dir string, upload func(path string) (map[string]string, error)) (*api.CreateRequest, error) {
+	req := &api.CreateRequest{Model: model}
+	for _, c := range m.Commands {
+		switch c.Name {
+		case From:
+			if !isPath(c.Value) {
+				req.From = c.Value
+				continue
+			}
+			path, ok := localPath(dir, c.Value)
+			if !ok {
+				return nil, fmt.Errorf("model file %s not found", c.Value)
+			}
+			files, err := upload(path)
+			if err != nil {
+				return nil, err
+			}
+			req.Files = files
+
+		case Adapter:
+			path, ok := localPath(dir, c.Value)
+			if !ok {
+				return nil, fmt.Errorf("adapter %s not found", c.Value)
+			}
+			files, err := upload(path)
+			if err != nil {
+				return nil, err
+			}
+			req.Adapters = files
+
+		case Parameter:
+			if req.Parameters == nil {
+				req.Parameters = make(map[string]interface{})
+			}
+			if c.Key == "stop" {
+				stop, _ := req.Parameters["stop"].([]string)
+				req.Parameters["stop"] = append(stop, c.Value)
+				continue
+			}
+			req.Parameters[c.Key] = parameterValue(c.Value)
+
+		case Template:
+			req.Template = c.Value
+		case System:
+			req.System = c.Value
+		case License:
+			req.License = append(req.License, c.Value)
+
+		case Message:
+			role := strings.ToLower(c.Key)
+			switch role {
+			case api.RoleSystem, api.RoleUser, api.RoleAssistant:
+			default:
+				return nil, fmt.Errorf("unknown message role %q (use system, user or assistant)", c.Key)
+			}
+			req.Messages = append(req.Messages, api.ChatMessage{Role: role, Content: c.Value})
+		}
+	}
+	return req, nil
+}
+
+// FromOptions returns PARAMETER instructions for the options that are set, sorted by name
+func FromOptions(options *api.Options) ([]Command, error) {
+	encoded, err := json.Marshal(options)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode options: %w", err)
+	}
+	var data map[string]interface{}
+	if err := json.Unmarshal(encoded, &data); err != nil {
+		return nil, fmt.Errorf("failed to decode options: %w", err)
+	}
+	names := make([]string, 0, len(data))
+	for name := range data {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var commands []Command
+	for _, name := range names {
+		switch value := data[name].(type) {
+		case []interface{}:
+			for _, v := range value {
+				commands = append(commands, Command{Name: Parameter, Key: name, Value: fmt.Sprint(v)})
+			}
+		case float64:
+			commands = append(commands, Command{Name: Parameter, Key: name, Value: strconv.FormatFloat(value, 'f', -1, 64)})
+		default:
+			commands = append(commands, Command{Name: Parameter, Key: name, Value: fmt.Sprint(value)})
+		}
+	}
+	return commands, nil
+}
+
+// isPath reports whether a FROM value names local weights rather than a model
+func isPath(value string) bool {
+	for _, prefix := range []string{"./", "../", "/", "~", ".\\", "..\\"} {
+		if strings.HasPrefix(value, prefix) {
+			return true
+		}
+	}
+	return filepath.IsAbs(value) || strings.HasSuffix(strings.ToLower(value), ".gguf")
+}
+
+// localPath resolves a FROM or ADAPTER value against dir, reporting whether it exists
+func localPath(dir string, value string) (string, bool) {
+	path := value
+	if home, err := os.UserHomeDir(); err == nil && strings.HasPrefix(path, "~/") {
+		path = filepath.Join(home, path[2:])
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(dir, path)
+	}
+	if _, err := os.Stat(path); err != nil {
+		return "", false
+	}
+	return path, true
+}
+
+// parameterValue converts a PARAMETER value to a number or boolean where it is one
+func parameterValue(value string) interface{} {
+	if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return n
+	}
+	if f, err := strconv.ParseFloat(value, 64); err == nil {
+		return f
+	}
+	switch value {
+	case "true":
+		return true
+	case "false":
+		return false
+	}
+	return value
+}
@@ -0,0 +1,263 @@
+package modelfile
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ai-in-pm/Ollama-Code/api"
+)
+
+func TestParse(t *testing.T) {
+	input := `# A comment
+from llama3.2
+PARAMETER Temperature 0.7
+PARAMETER stop "<|eot|>"
+SYSTEM """You are a
+helpful assistant."""
+TEMPLATE """{{ .Prompt }}"""
+MESSAGE user Hello there
+LICENSE MIT
+`
+	m, err := Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Command{
+		{Name: From, Value: "llama3.2"},
+		{Name: Parameter, Key: "temperature", Value: "0.7"},
+		{Name: Parameter, Key: "stop", Value: "<|eot|>"},
+		{Name: System, Value: "You are a\nhelpful assistant."},
+		{Name: Template, Value: "{{ .Prompt }}"},
+		{Name: Message, Key: "user", Value: "Hello there"},
+		{Name: License, Value: "MIT"},
+	}
+	if !reflect.DeepEqual(m.Commands, want) {
+		t.Errorf("Parse:\n got %+v\nwant %+v", m.Commands, want)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"unknown instruction", "FROM llama3.2\nPROMPT hi\n", `line 2: unknown instruction "PROMPT"`},
+		{"unterminated", "FROM llama3.2\nSYSTEM \"\"\"never\nclosed\n", `line 2: unterminated """ in SYSTEM`},
+		{"parameter without a name", "FROM llama3.2\nPARAMETER\n", "line 2: PARAMETER needs a name and a value"},
+		{"missing value", "FROM\n", "line 1: FROM needs a value"},
+	}
+	for _, tt := range tests {
+		_, err := Parse(strings.NewReader(tt.input))
+		if err == nil || err.Error() != tt.want {
+			t.Errorf("%s: error = %v, want %q", tt.name, err, tt.want)
+		}
+	}
+
+	if _, err := Parse(strings.NewReader("SYSTEM hi\n")); !errors.Is(err, ErrNoFrom) {
+		t.Errorf("error = %v, want ErrNoFrom", err)
+	}
+}
+
+func TestParseAllowsEmptySystemAndTemplate(t *testing.T) {
+	m, err := Parse(strings.NewReader("FROM llama3.2\nSYSTEM \"\"\"\"\"\"\nTEMPLATE\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m.Commands) != 3 || m.Commands[1].Value != "" || m.Commands[2].Value != "" {
+		t.Errorf("Commands = %+v", m.Commands)
+	}
+}
+
+func TestStringRoundTrip(t *testing.T) {
+	m := &Modelfile{}
+	m.Add(From, "", "llama3.2")
+	m.Add(Parameter, "num_ctx", "8192")
+	m.Add(Parameter, "stop", "<|eot|>")
+	m.Add(System, "", "Line one\nline two")
+	m.Add(System, "", `Say "hi" politely`)
+	m.Add(System, "", "  padded  ")
+	m.Add(System, "", `Quote it: """like this"""`)
+	m.Add(System, "", "Ends with a quote: \"")
+	m.Add(Template, "", "{{ .System }}\n\"\"\"\n{{ .Prompt }}")
+	m.Add(Template, "", "")
+	m.Add(Message, "assistant", "Hi!")
+
+	text := m.String()
+	if !strings.HasPrefix(text, "FROM llama3.2\nPARAMETER num_ctx 8192\n") {
+		t.Errorf("String = %q", text)
+	}
+	parsed, err := Parse(strings.NewReader(text))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(parsed.Commands, m.Commands) {
+		t.Errorf("Parse(String()):\n got %+v\nwant %+v", parsed.Commands, m.Commands)
+	}
+}
+
+func TestRequest(t *testing.T) {
+	m, err := Parse(strings.NewReader(`FROM llama3.2
+PARAMETER num_ctx 4096
+PARAMETER temperature 0.5
+PARAMETER penalize_newline false
+PARAMETER stop <a>
+PARAMETER stop <b>
+SYSTEM Be brief.
+LICENSE MIT
+LICENSE Apache-2.0
+MESSAGE User Hi
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	upload := func(path string) (map[string]string, error) {
+		t.Errorf("upload called for %s", path)
+		return nil, nil
+	}
+	req, err := m.Request("brief", t.TempDir(), upload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &api.CreateRequest{
+		Model:  "brief",
+		From:   "llama3.2",
+		System: "Be brief.",
+		Parameters: map[string]interface{}{
+			"num_ctx":          int64(4096),
+			"temperature":      0.5,
+			"penalize_newline": false,
+			"stop":             []string{"<a>", "<b>"},
+		},
+		License:  []string{"MIT", "Apache-2.0"},
+		Messages: []api.ChatMessage{{Role: api.RoleUser, Content: "Hi"}},
+	}
+	if !reflect.DeepEqual(req, want) {
+		t.Errorf("Request:\n got %+v\nwant %+v", req, want)
+	}
+}
+
+func TestRequestUploadsLocalFiles(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"model.gguf", "adapter.gguf"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("GGUF"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	m, err := Parse(strings.NewReader("FROM ./model.gguf\nADAPTER adapter.gguf\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var uploaded []string
+	upload := func(path string) (map[string]string, error) {
+		uploaded = append(uploaded, path)
+		return map[string]string{filepath.Base(path): "sha256:" + filepath.Base(path)}, nil
+	}
+	req, err := m.Request("local", dir, upload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.From != "" {
+		t.Errorf("From = %q, want it empty for a local file", req.From)
+	}
+	if !reflect.DeepEqual(req.Files, map[string]string{"model.gguf": "sha256:model.gguf"}) {
+		t.Errorf("Files = %v", req.Files)
+	}
+	if !reflect.DeepEqual(req.Adapters, map[string]string{"adapter.gguf": "sha256:adapter.gguf"}) {
+		t.Errorf("Adapters = %v", req.Adapters)
+	}
+	wantUploaded := []string{filepath.Join(dir, "model.gguf"), filepath.Join(dir, "adapter.gguf")}
+	if !reflect.DeepEqual(uploaded, wantUploaded) {
+		t.Errorf("uploaded %v, want %v", uploaded, wantUploaded)
+	}
+}
+
+func TestRequestErrors(t *testing.T) {
+	noUpload := func(path string) (map[string]string, error) { return nil, nil }
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"missing adapter", "FROM llama3.2\nADAPTER missing.gguf\n", "adapter missing.gguf not found"},
+		{"missing model file", "FROM ./missing.gguf\n", "model file ./missing.gguf not found"},
+		{"missing gguf", "FROM missing.gguf\n", "model file missing.gguf not found"},
+		{"message role", "FROM llama3.2\nMESSAGE tool hi\n", `unknown message role "tool" (use system, user or assistant)`},
+	}
+	for _, tt := range tests {
+		m, err := Parse(strings.NewReader(tt.input))
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if _, err := m.Request("x", t.TempDir(), noUpload); err == nil || err.Error() != tt.want {
+			t.Errorf("%s: error = %v, want %q", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestRequestFromNameOfLocalDirectory(t *testing.T) {
+	// A directory that happens to have the model's name doesn't make it a path
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, "llama3.2"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	m, err := Parse(strings.NewReader("FROM llama3.2\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, err := m.Request("x", dir, func(path string) (map[string]string, error) {
+		t.Errorf("upload called for %s", path)
+		return nil, nil
+	})
+	if err != nil || req.From != "llama3.2" {
+		t.Errorf("Request = %+v, %v, want FROM llama3.2", req, err)
+	}
+}
+
+func TestIsPath(t *testing.T) {
+	tests := []struct {
+		value string
+		want  bool
+	}{
+		{"./model.gguf", true},
+		{"../weights", true},
+		{"/models/llama", true},
+		{"~/models/llama", true},
+		{"model.GGUF", true},
+		{"llama3.2", false},
+		{"qwen2.5-coder:7b", false},
+		{"hf.co/user/repo:Q4_K_M", false},
+		{"user/model", false},
+	}
+	for _, tt := range tests {
+		if got := isPath(tt.value); got != tt.want {
+			t.Errorf("isPath(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestFromOptions(t *testing.T) {
+	temperature := 0.25
+	commands, err := FromOptions(&api.Options{
+		NumCtx:      8192,
+		Temperature: &temperature,
+		Stop:        []string{"<a>", "<b>"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Command{
+		{Name: Parameter, Key: "num_ctx", Value: "8192"},
+		{Name: Parameter, Key: "stop", Value: "<a>"},
+		{Name: Parameter, Key: "stop", Value: "<b>"},
+		{Name: Parameter, Key: "temperature", Value: "0.25"},
+	}
+	if !reflect.DeepEqual(commands, want) {
+		t.Errorf("FromOptions:\n got %+v\nwant %+v", commands, want)
+	}
+}
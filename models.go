@@ -0,0 +1,272 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ai-in-pm/Ollama-Code/api"
+	"github.com/ai-in-pm/Ollama-Code/ui"
+	"github.com/spf13/cobra"
+)
+
+// pullModel downloads a model, showing the download progress. The cached details of the
+// model are dropped, since the pull may have updated it.
+func pullModel(ctx context.Context, client *api.OllamaClient, terminal *ui.TerminalUI, model string) error {
+	err := client.Pull(ctx, model, func(progress api.ProgressResponse) {
+		terminal.SetProgress(progress.Status, progress.Completed, progress.Total)
+	})
+	terminal.SetLoading(false, "")
+	if err == nil {
+		forgetModelInfo(client, model)
+	}
+	return err
+}
+
+// formatModelList lists local models as a table, marking the configured model
+func formatModelList(models []api.Model) string {
+	var sb strings.Builder
+	w := tabwriter.NewWriter(&sb, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "\tNAME\tSIZE\tPARAMETERS\tQUANTIZATION\tMODIFIED")
+	for _, m := range models {
+		current := ""
+		if api.HasModel([]string{m.Name}, config.Model) {
+			current = "*"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n",
+			current,
+			m.Name,
+			ui.FormatBytes(m.Size),
+			m.Details.ParameterSize,
+			m.Details.QuantizationLevel,
+			m.ModifiedAt.Local().Format(time.DateTime),
+		)
+	}
+	_ = w.Flush()
+	return strings.TrimRight(sb.String(), "\n")
+}
+
+// formatRunningModels lists the loaded models as a table with where they run and how long
+// they stay loaded
+func formatRunningModels(models []api.RunningModel) string {
+	var sb strings.Builder
+	w := tabwriter.NewWriter(&sb, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tSIZE\tPROCESSOR\tCONTEXT\tUNTIL")
+	for _, m := range models {
+		contextLength := ""
+		if m.ContextLength > 0 {
+			contextLength = fmt.Sprint(m.ContextLength)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n",
+			m.Name,
+			ui.FormatBytes(m.Size),
+			processor(m),
+			contextLength,
+			m.ExpiresAt.Local().Format(time.DateTime),
+		)
+	}
+	_ = w.Flush()
+	return strings.TrimRight(sb.String(), "\n")
+}
+
+// processor describes how much of a loaded model is in GPU memory, as "ollama ps" does
+func processor(m api.RunningModel) string {
+	switch {
+	case m.Size == 0:
+		return ""
+	case m.SizeVRAM == 0:
+		return "100% CPU"
+	case m.SizeVRAM >= m.Size:
+		return "100% GPU"
+	}
+	gpu := m.SizeVRAM * 100 / m.Size
+	return fmt.Sprintf("%d%%/%d%% CPU/GPU", 100-gpu, gpu)
+}
+
+// formatModelDetails describes a model as returned by /api/show
+func formatModelDetails(name string, info *api.ShowResponse) string {
+	var sb strings.Builder
+	w := tabwriter.NewWriter(&sb, 0, 0, 2, ' ', 0)
+	row := func(label, value string) {
+		if value != "" {
+			fmt.Fprintf(w, "%s:\t%s\n", label, value)
+		}
+	}
+	row("Model", name)
+	row("Family", info.Details.Family)
+	row("Parameters", info.Details.ParameterSize)
+	row("Quantization", info.Details.QuantizationLevel)
+	row("Format", info.Details.Format)
+	if n := info.ContextLength(); n > 0 {
+		row("Context length", fmt.Sprint(n))
+	}
+	row("Capabilities", strings.Join(info.Capabilities, ", "))
+	if !info.ModifiedAt.IsZero() {
+		row("Modified", info.ModifiedAt.Local().Format(time.DateTime))
+	}
+	_ = w.Flush()
+
+	if info.Parameters != "" {
+		sb.WriteString("\nParameters:\n")
+		for _, line := range strings.Split(strings.TrimSpace(info.Parameters), "\n") {
+			sb.WriteString("  " + strings.Join(strings.Fields(line), " ") + "\n")
+		}
+	}
+	if info.System != "" {
+		sb.WriteString("\nSystem prompt:\n  " + strings.ReplaceAll(strings.TrimSpace(info.System), "\n", "\n  ") + "\n")
+	}
+	if info.License != "" {
+		license, _, _ := strings.Cut(strings.TrimSpace(info.License), "\n")
+		sb.WriteString("\nLicense:\n  " + license + "\n")
+	}
+	return strings.TrimRight(sb.String(), "\n")
+}
+
+// clientError turns a client error into a command error with a hint. Errors about a
+// missing model are kept as they are, since they name the model asked for rather than the
+// configured one.
+func clientError(err error) error {
+	if errors.Is(err, api.ErrModelNotFound) {
+		return err
+	}
+	return errors.New(describeError(err))
+}
+
+// newModelsCmd creates the models command group
+func newModelsCmd() *cobra.Command {
+	// The Ollama server the commands act on; with several endpoints, the one preferred for
+	// the configured model
+	var client *api.OllamaClient
+
+	modelsCmd := &cobra.Command{
+		Use:   "models",
+		Short: "Manage Ollama models",
+		Long:  `List, download, inspect, create, copy and delete the models of the Ollama server, and show which are loaded.`,
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			// Usage is shown for wrong arguments, not for server errors
+			cmd.SilenceUsage = true
+			var err error
+			client, err = ollamaClient()
+			return err
+		},
+	}
+
+	listCmd := &cobra.Command{
+		Use:     "list",
+		Aliases: []string{"ls"},
+		Short:   "List the downloaded models",
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			models, err := client.ListLocalModels(context.Background())
+			if err != nil {
+				return clientError(err)
+			}
+			if len(models) == 0 {
+				fmt.Println("No models downloaded. Download one with 'ollama-code models pull <name>'.")
+				return nil
+			}
+			fmt.Println(formatModelList(models))
+			return nil
+		},
+	}
+
+	pullCmd := &cobra.Command{
+		Use:   "pull [model]",
+		Short: "Download a model, or update it if it has changed",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			model := config.Model
+			if len(args) > 0 {
+				model = args[0]
+			}
+			terminal := ui.NewTerminalUI()
+			ctx, cancel := terminal.Cancellable(context.Background())
+			defer cancel()
+			if err := pullModel(ctx, client, terminal, model); err != nil {
+				return clientError(err)
+			}
+			fmt.Printf("Pulled %s.\n", model)
+			return nil
+		},
+	}
+
+	var modelfile bool
+	showCmd := &cobra.Command{
+		Use:   "show [model]",
+		Short: "Show the details, parameters and capabilities of a model",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			model := config.Model
+			if len(args) > 0 {
+				model = args[0]
+			}
+			info, err := client.Show(context.Background(), model)
+			if err != nil {
+				return clientError(err)
+			}
+			rememberModelInfo(client, model, info)
+			if modelfile {
+				fmt.Println(strings.TrimRight(info.Modelfile, "\n"))
+				return nil
+			}
+			fmt.Println(formatModelDetails(model, info))
+			return nil
+		},
+	}
+	showCmd.Flags().BoolVar(&modelfile, "modelfile", false, "Print the Modelfile of the model")
+
+	deleteCmd := &cobra.Command{
+		Use:     "delete [model...]",
+		Aliases: []string{"rm"},
+		Short:   "Delete models",
+		Args:    cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			for _, model := range args {
+				if err := client.Delete(context.Background(), model); err != nil {
+					return fmt.Errorf("failed to delete %s: %w", model, clientError(err))
+				}
+				forgetModelInfo(client, model)
+				fmt.Printf("Deleted %s.\n", model)
+			}
+			return nil
+		},
+	}
+
+	copyCmd := &cobra.Command{
+		Use:     "copy [source] [destination]",
+		Aliases: []string{"cp"},
+		Short:   "Copy a model to a new name",
+		Args:    cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := client.Copy(context.Background(), args[0], args[1]); err != nil {
+				return clientError(err)
+			}
+			fmt.Printf("Copied %s to %s.\n", args[0], args[1])
+			return nil
+		},
+	}
+
+	psCmd := &cobra.Command{
+		Use:   "ps",
+		Short: "List the models loaded into memory",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			models, err := client.ListRunning(context.Background())
+			if err != nil {
+				return clientError(err)
+			}
+			if len(models) == 0 {
+				fmt.Println("No models are loaded.")
+				return nil
+			}
+			fmt.Println(formatRunningModels(models))
+			return nil
+		},
+	}
+
+	modelsCmd.AddCommand(listCmd, pullCmd, showCmd, newModelsCreateCmd(), deleteCmd, copyCmd, psCmd)
+	return modelsCmd
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/ai-in-pm/Ollama-Code/api"
+	"github.com/spf13/cobra"
+)
+
+// generationOptions returns the model options derived from the configuration
+func generationOptions() *api.Options {
+	options := &api.Options{
+		NumCtx:        contextSize(),
+		NumPredict:    config.MaxTokens,
+		Temperature:   api.Float64(config.Temperature),
+		TopP:          config.TopP,
+		TopK:          config.TopK,
+		RepeatPenalty: config.RepeatPenalty,
+		RepeatLastN:   config.RepeatLastN,
+		Stop:          config.Stop,
+		Mirostat:      config.Mirostat,
+		MirostatTau:   config.MirostatTau,
+		MirostatEta:   config.MirostatEta,
+	}
+	// A negative seed means a random seed for every request
+	if config.Seed >= 0 {
+		options.Seed = api.Int(config.Seed)
+	}
+	return options
+}
+
+// addGenerationFlags registers the most common generation options as persistent flags
+func addGenerationFlags(cmd *cobra.Command) {
+	flags := cmd.PersistentFlags()
+	flags.IntVar(&config.ContextSize, "num-ctx", config.ContextSize, "Context window size in tokens (num_ctx, 0 for the model's context length)")
+	flags.IntVarP(&config.MaxTokens, "num-predict", "n", config.MaxTokens, "Maximum number of tokens to generate (num_predict, -1 for no limit)")
+	flags.Float64Var(&config.TopP, "top-p", config.TopP, "Nucleus sampling probability (top_p)")
+	flags.IntVar(&config.TopK, "top-k", config.TopK, "Sample from the k most likely tokens (top_k, 0 for the model default)")
+	flags.IntVar(&config.Seed, "seed", config.Seed, "Random seed for reproducible output (-1 for random)")
+	flags.Float64Var(&config.RepeatPenalty, "repeat-penalty", config.RepeatPenalty, "Penalty for repeated tokens (repeat_penalty, 0 for the model default)")
+	flags.StringSliceVar(&config.Stop, "stop", config.Stop, "Stop sequences (repeatable)")
+	flags.IntVar(&config.Mirostat, "mirostat", config.Mirostat, "Mirostat sampling: 0 disabled, 1 Mirostat, 2 Mirostat 2.0")
+}
+
+// setGenerationOption changes a generation option by its Ollama name
+func setGenerationOption(name string, value string) error {
+	parseInt := func() (int, error) {
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return 0, fmt.Errorf("%s must be an integer", name)
+		}
+		return n, nil
+	}
+	parseFloat := func(min, max float64) (float64, error) {
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return 0, fmt.Errorf("%s must be a number", name)
+		}
+		if f < min || f > max {
+			return 0, fmt.Errorf("%s must be between %g and %g", name, min, max)
+		}
+		return f, nil
+	}
+
+	var err error
+	switch name {
+	case "temperature":
+		config.Temperature, err = parseFloat(0, 2)
+	case "top_p":
+		config.TopP, err = parseFloat(0, 1)
+	case "top_k":
+		config.TopK, err = parseInt()
+	case "num_ctx":
+		config.ContextSize, err = parseInt()
+	case "num_predict":
+		config.MaxTokens, err = parseInt()
+	case "seed":
+		config.Seed, err = parseInt()
+	case "repeat_penalty":
+		config.RepeatPenalty, err = parseFloat(0, 10)
+	case "repeat_last_n":
+		config.RepeatLastN, err = parseInt()
+	case "mirostat":
+		var mode int
+		if mode, err = parseInt(); err == nil && (mode < 0 || mode > 2) {
+			err = fmt.Errorf("mirostat must be 0, 1 or 2")
+		}
+		if err == nil {
+			config.Mirostat = mode
+		}
+	case "mirostat_tau":
+		config.MirostatTau, err = parseFloat(0, 100)
+	case "mirostat_eta":
+		config.MirostatEta, err = parseFloat(0, 10)
+	case "stop":
+		if value == "" || value == "none" {
+			config.Stop = nil
+		} else {
+			config.Stop = strings.Split(value, ",")
+		}
+	default:
+		return fmt.Errorf("unknown option %q (use /options to list them)", name)
+	}
+	return err
+}
+
+// formatGenerationOptions lists the current generation options
+func formatGenerationOptions() string {
+	seed := "random"
+	if config.Seed >= 0 {
+		seed = strconv.Itoa(config.Seed)
+	}
+	numCtx := strconv.Itoa(contextSize())
+	if config.ContextSize <= 0 {
+		numCtx = "auto (" + numCtx + ")"
+	}
+	stop := "none"
+	if len(config.Stop) > 0 {
+		stop = strconv.Quote(strings.Join(config.Stop, ","))
+	}
+
+	return fmt.Sprintf("Generation options:\n"+
+		"  temperature    %.2f\n"+
+		"  top_p          %.2f\n"+
+		"  top_k          %d\n"+
+		"  num_ctx        %s\n"+
+		"  num_predict    %d\n"+
+		"  seed           %s\n"+
+		"  repeat_penalty %.2f\n"+
+		"  repeat_last_n  %d\n"+
+		"  mirostat       %d (tau %.2f, eta %.2f)\n"+
+		"  stop           %s",
+		config.Temperature, config.TopP, config.TopK, numCtx, config.MaxTokens, seed,
+		config.RepeatPenalty, config.RepeatLastN, config.Mirostat, config.MirostatTau, config.MirostatEta, stop)
+}
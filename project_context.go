@@ -0,0 +1,161 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ai-in-pm/Ollama-Code/context_manager"
+)
+
+// Context modes for the file commands
+const (
+	contextNone    = "none"    // Only the target file
+	contextFile    = "file"    // The target file with its project-relative path
+	contextRelated = "related" // The target file and the files it depends on
+	contextProject = "project" // Related files plus the project tree
+)
+
+// promptOverheadTokens is kept free of file context for the system prompt, the task
+// instructions and the edit format
+const promptOverheadTokens = 1024
+
+// Files and directories that mark the root of a project
+var projectMarkers = []string{".git", "go.mod", "package.json", "pyproject.toml", "setup.py", "Cargo.toml", "Makefile"}
+
+// validContextMode reports whether mode is one of the supported context modes
+func validContextMode(mode string) bool {
+	switch mode {
+	case contextNone, contextFile, contextRelated, contextProject:
+		return true
+	}
+	return false
+}
+
+// findProjectRoot walks up from the file's directory to the nearest project marker,
+// falling back to the working directory if it contains the file, or the file's directory
+func findProjectRoot(filePath string) string {
+	dir := filepath.Dir(filePath)
+	if root := findMarkedRoot(dir); root != "" {
+		return root
+	}
+
+	if workDir, err := os.Getwd(); err == nil {
+		if rel, err := filepath.Rel(workDir, filePath); err == nil && !strings.HasPrefix(rel, "..") {
+			return workDir
+		}
+	}
+	return dir
+}
+
+// findMarkedRoot walks up from dir to the nearest directory containing a project marker,
+// returning "" if there is none
+func findMarkedRoot(dir string) string {
+	for current := dir; ; {
+		for _, marker := range projectMarkers {
+			if _, err := os.Stat(filepath.Join(current, marker)); err == nil {
+				return current
+			}
+		}
+		parent := filepath.Dir(current)
+		if parent == current {
+			return ""
+		}
+		current = parent
+	}
+}
+
+// newContextManager creates a context manager for the project containing filePath,
+// sized to the configured context window
+func newContextManager(filePath string) *context_manager.ContextManager {
+	cm := context_manager.NewContextManager(findProjectRoot(filePath))
+	cm.SetTokenBudget(fileContextBudget())
+	return cm
+}
+
+// fileContextBudget returns the tokens available for file context in a prompt
+func fileContextBudget() int {
+	return max(contextBudget()-promptOverheadTokens, contextBudget()/2)
+}
+
+// buildFileContext returns the context for a file command according to the context mode.
+// A target with a focus sends those lines first, numbered as in the file, and the rest
+// of the file as context (except in none mode). With git, the file's uncommitted changes,
+// recent commits and the blame of the focus are added.
+func buildFileContext(target fileTarget, mode string, git bool) (string, error) {
+	if mode == "" {
+		mode = contextRelated
+	}
+	if !validContextMode(mode) {
+		return "", fmt.Errorf("unknown context mode %q (use none, file, related or project)", mode)
+	}
+
+	absPath, err := filepath.Abs(target.Path)
+	if err != nil {
+		return "", err
+	}
+
+	if mode == contextNone {
+		content, err := readFileContent(absPath)
+		if err != nil {
+			return "", err
+		}
+		if target.Focus.Start == 0 {
+			return fmt.Sprintf("File: %s\n\n```\n%s\n```\n", target.Path, content), nil
+		}
+
+		lines := strings.Split(content, "\n")
+		start, end := target.Focus.Start, min(target.Focus.End, len(lines))
+		if start > end {
+			return "", fmt.Errorf("line %d is past the end of %s (%d lines)", start, target.Path, len(lines))
+		}
+		focus := context_manager.NumberLines(strings.Join(lines[start-1:end], "\n"), start)
+		label := fmt.Sprintf("%s:%d-%d", target.Path, start, end)
+		if target.Label != "" {
+			label += " (" + target.Label + ")"
+		}
+		return fmt.Sprintf("Focus: %s\n\n```\n%s\n```\n", label, focus), nil
+	}
+
+	cm := newContextManager(absPath)
+	return cm.PackFileContext(absPath, context_manager.PackOptions{
+		Related:    mode == contextRelated || mode == contextProject,
+		Structure:  mode == contextProject,
+		Git:        git,
+		Focus:      target.Focus,
+		FocusLabel: target.Label,
+	})
+}
+
+// taskFlags are the options of the file slash commands
+type taskFlags struct {
+	mode  string // Context mode
+	apply bool   // Offer to apply the proposed changes
+	git   bool   // Include git history in the context
+}
+
+// parseTaskFlags removes the --context=MODE (or --context MODE), --no-apply and --no-git
+// options from slash command arguments
+func parseTaskFlags(args []string) ([]string, taskFlags) {
+	flags := taskFlags{mode: config.ContextMode, apply: true, git: config.GitContext}
+	var rest []string
+	for i := 0; i < len(args); i++ {
+		switch {
+		case strings.HasPrefix(args[i], "--context="):
+			flags.mode = strings.TrimPrefix(args[i], "--context=")
+		case args[i] == "--context" && i+1 < len(args):
+			flags.mode = args[i+1]
+			i++
+		case args[i] == "--no-apply" || args[i] == "--apply=false":
+			flags.apply = false
+		case args[i] == "--no-git" || args[i] == "--git=false":
+			flags.git = false
+		case args[i] == "--git":
+			flags.git = true
+		default:
+			rest = append(rest, args[i])
+		}
+	}
+	return rest, flags
+}
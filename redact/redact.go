@@ -0,0 +1,289 @@
+package redact
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// DefaultEntropyThreshold is the Shannon entropy, in bits per character, above which a
+// long random-looking token is treated as a secret
+const DefaultEntropyThreshold = 4.2
+
+// minEntropyTokenLen is the shortest token checked for entropy
+const minEntropyTokenLen = 24
+
+// minKnownSecretLen is the shortest secret masked wherever it appears once found
+const minKnownSecretLen = 6
+
+// EntropyRule names secrets found by entropy rather than by a pattern
+const EntropyRule = "high-entropy"
+
+// Rule masks what a regular expression matches; if it has a capture group, only the
+// first group is masked
+type Rule struct {
+	Name    string
+	Pattern *regexp.Regexp
+}
+
+// NewRule compiles a rule
+func NewRule(name string, pattern string) (Rule, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return Rule{}, fmt.Errorf("invalid pattern for rule %s: %w", name, err)
+	}
+	return Rule{Name: name, Pattern: re}, nil
+}
+
+// Keys that usually hold credentials, e.g. "password", "db_password" or "apiKey.prod";
+// "tokenCount" and "tokens" are not, nor are the shell's PWD and OLDPWD, which hold the
+// working directory: "pwd" only counts after a separator or in camel case ("db_pwd", "dbPwd")
+const secretKey = `[\w.-]*(?:password|passwd|[_.-]pwd|(?-i:[a-z0-9]Pwd)|secret|token|api[_-]?key|apikey|access[_-]?key|auth[_-]?key|credentials?|private[_-]?key|client[_-]?secret)(?:[_.-][\w.-]*)?`
+
+// DefaultRules detect private keys, well-known token formats, credentials in URLs and
+// values assigned to secret-looking keys
+func DefaultRules() []Rule {
+	rules := []struct{ name, pattern string }{
+		{"private-key", `-----BEGIN [A-Z0-9 ]*PRIVATE KEY-----[\s\S]*?-----END [A-Z0-9 ]*PRIVATE KEY-----`},
+		{"aws-access-key", `\b((?:AKIA|ASIA)[0-9A-Z]{16})\b`},
+		{"github-token", `\b((?:gh[pousr]_[A-Za-z0-9]{36,})|github_pat_[A-Za-z0-9_]{40,})\b`},
+		{"gitlab-token", `\b(glpat-[A-Za-z0-9_-]{20,})\b`},
+		{"slack-token", `\b(xox[abposr]-[A-Za-z0-9-]{10,})\b`},
+		{"stripe-key", `\b((?:sk|rk)_live_[A-Za-z0-9]{16,})\b`},
+		{"google-api-key", `\b(AIza[0-9A-Za-z_-]{35})\b`},
+		{"openai-key", `\b(sk-(?:proj-)?[A-Za-z0-9_-]{20,})\b`},
+		{"jwt", `\b(eyJ[A-Za-z0-9_-]{10,}\.eyJ[A-Za-z0-9_-]{10,}\.[A-Za-z0-9_-]{10,})\b`},
+		{"url-password", `[a-zA-Z][a-zA-Z0-9+.-]*://[^/\s:@]+:([^/\s@]+)@`},
+		// password = "value", "apiKey": 'value', TOKEN := `value`
+		{"secret", `(?i)` + secretKey + `["']?\s*(?::=|=>|[:=])\s*["'` + "`" + `]([^"'` + "`" + `\s]{4,})["'` + "`" + `]`},
+		// Unquoted values in .env files and shell scripts: DB_PASSWORD=value, but not PWD=/src
+		{"env-secret", `(?m)^\s*(?:export\s+)?[A-Z0-9_]*(?:PASSWORD|PASSWD|_PWD|SECRET|TOKEN|API_?KEY|ACCESS_?KEY|AUTH_?KEY|CREDENTIALS?|PRIVATE_?KEY)[A-Z0-9_]*\s*=\s*([^\s"'#$]{4,})`},
+		// Unquoted values in YAML and INI files: password: value
+		{"config-secret", `(?im)^\s*` + secretKey + `\s*[:=]\s*([^\s"'#$,;(){}\[\]<>]{4,})\s*$`},
+	}
+
+	compiled := make([]Rule, len(rules))
+	for i, r := range rules {
+		compiled[i] = Rule{Name: r.name, Pattern: regexp.MustCompile(r.pattern)}
+	}
+	return compiled
+}
+
+// Finding is a secret masked for the first time
+type Finding struct {
+	Rule        string
+	Placeholder string
+}
+
+// Redactor replaces secrets with placeholders such as REDACTED_PASSWORD_1. The same
+// secret always gets the same placeholder, so placeholders in the model's answers can be
+// restored to the original values.
+type Redactor struct {
+	rules     []Rule
+	allowlist []*regexp.Regexp
+	entropy   float64
+
+	mutex     sync.Mutex
+	byValue   map[string]string // Secret to placeholder
+	originals map[string]string // Placeholder to secret
+	counts    map[string]int    // Placeholders issued per rule
+	pending   []Finding
+}
+
+// New creates a redactor. Values fully matching an allowlist pattern are never masked;
+// an entropy threshold of 0 disables entropy detection.
+func New(rules []Rule, allowlist []*regexp.Regexp, entropy float64) *Redactor {
+	return &Redactor{
+		rules:     rules,
+		allowlist: allowlist,
+		entropy:   entropy,
+		byValue:   make(map[string]string),
+		originals: make(map[string]string),
+		counts:    make(map[string]int),
+	}
+}
+
+var (
+	placeholderPattern = regexp.MustCompile(`\bREDACTED_[A-Z0-9_]+_[0-9]+\b`)
+	entropyToken       = regexp.MustCompile(`[A-Za-z0-9+/_=-]{24,}`)
+)
+
+// span is a part of the text to mask
+type span struct {
+	start, end int
+	rule       string
+}
+
+// Redact returns text with every secret replaced by its placeholder
+func (r *Redactor) Redact(text string) string {
+	var spans []span
+	for _, rule := range r.rules {
+		for _, m := range rule.Pattern.FindAllStringSubmatchIndex(text, -1) {
+			start, end := m[0], m[1]
+			if len(m) >= 4 && m[2] >= 0 {
+				start, end = m[2], m[3]
+			}
+			spans = append(spans, span{start: start, end: end, rule: rule.Name})
+		}
+	}
+	if r.entropy > 0 {
+		for _, m := range entropyToken.FindAllStringIndex(text, -1) {
+			if looksRandom(text[m[0]:m[1]], r.entropy) {
+				spans = append(spans, span{start: m[0], end: m[1], rule: EntropyRule})
+			}
+		}
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.maskKnown(r.mask(text, spans))
+}
+
+// mask replaces the spans of text with placeholders, skipping overlapping spans,
+// allowlisted values and placeholders
+func (r *Redactor) mask(text string, spans []span) string {
+	if len(spans) == 0 {
+		return text
+	}
+
+	// Earlier spans win; on the same start, longer ones do
+	sort.Slice(spans, func(i, j int) bool {
+		if spans[i].start != spans[j].start {
+			return spans[i].start < spans[j].start
+		}
+		return spans[i].end > spans[j].end
+	})
+
+	var sb strings.Builder
+	last := 0
+	for _, s := range spans {
+		value := text[s.start:s.end]
+		if s.start < last || r.allowed(value) || placeholderPattern.MatchString(value) {
+			continue
+		}
+		sb.WriteString(text[last:s.start])
+		sb.WriteString(r.placeholder(value, s.rule))
+		last = s.end
+	}
+	sb.WriteString(text[last:])
+	return sb.String()
+}
+
+// maskKnown masks secrets found before wherever they appear, e.g. a password from .env
+// repeated in a question
+func (r *Redactor) maskKnown(text string) string {
+	var spans []span
+	for value := range r.byValue {
+		if len(value) < minKnownSecretLen {
+			continue
+		}
+		for offset := 0; ; {
+			i := strings.Index(text[offset:], value)
+			if i < 0 {
+				break
+			}
+			spans = append(spans, span{start: offset + i, end: offset + i + len(value)})
+			offset += i + len(value)
+		}
+	}
+	return r.mask(text, spans)
+}
+
+// allowed reports whether a value matches the allowlist
+func (r *Redactor) allowed(value string) bool {
+	for _, re := range r.allowlist {
+		if loc := re.FindStringIndex(value); loc != nil && loc[0] == 0 && loc[1] == len(value) {
+			return true
+		}
+	}
+	return false
+}
+
+// placeholder returns the placeholder of a secret, issuing a new one if needed
+func (r *Redactor) placeholder(value string, rule string) string {
+	if p, ok := r.byValue[value]; ok {
+		return p
+	}
+	r.counts[rule]++
+	p := fmt.Sprintf("REDACTED_%s_%d", strings.ToUpper(strings.NewReplacer("-", "_", " ", "_").Replace(rule)), r.counts[rule])
+	r.byValue[value] = p
+	r.originals[p] = value
+	r.pending = append(r.pending, Finding{Rule: rule, Placeholder: p})
+	return p
+}
+
+// Restore replaces the placeholders in text with the secrets they stand for
+func (r *Redactor) Restore(text string) string {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return placeholderPattern.ReplaceAllStringFunc(text, func(p string) string {
+		if value, ok := r.originals[p]; ok {
+			return value
+		}
+		return p
+	})
+}
+
+// TakeFindings returns the secrets masked since the last call
+func (r *Redactor) TakeFindings() []Finding {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	findings := r.pending
+	r.pending = nil
+	return findings
+}
+
+// Summarize describes findings by rule, e.g. "2 secret, 1 aws-access-key"
+func Summarize(findings []Finding) string {
+	counts := make(map[string]int)
+	var rules []string
+	for _, f := range findings {
+		if counts[f.Rule] == 0 {
+			rules = append(rules, f.Rule)
+		}
+		counts[f.Rule]++
+	}
+	parts := make([]string, len(rules))
+	for i, rule := range rules {
+		parts[i] = fmt.Sprintf("%d %s", counts[rule], rule)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// looksRandom reports whether a token mixes upper case letters, lower case letters and
+// digits and has at least the given entropy
+func looksRandom(token string, threshold float64) bool {
+	if len(token) < minEntropyTokenLen {
+		return false
+	}
+	var upper, lower, digit bool
+	for _, c := range token {
+		switch {
+		case c >= 'A' && c <= 'Z':
+			upper = true
+		case c >= 'a' && c <= 'z':
+			lower = true
+		case c >= '0' && c <= '9':
+			digit = true
+		}
+	}
+	return upper && lower && digit && shannonEntropy(token) >= threshold
+}
+
+// shannonEntropy returns the entropy of a string in bits per character
+func shannonEntropy(s string) float64 {
+	counts := make(map[rune]int)
+	for _, c := range s {
+		counts[c]++
+	}
+	n := float64(len(s))
+	entropy := 0.0
+	for _, count := range counts {
+		p := float64(count) / n
+		entropy -= p * math.Log2(p)
+	}
+	return entropy
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"sync"
+
+	"github.com/ai-in-pm/Ollama-Code/api"
+	"github.com/ai-in-pm/Ollama-Code/redact"
+	"github.com/ai-in-pm/Ollama-Code/ui"
+)
+
+var (
+	redactorOnce sync.Once
+	redactor     *redact.Redactor
+)
+
+// secretRedactor returns the redactor built from the configuration, or nil if redaction
+// is disabled. Invalid rules and allowlist patterns are reported and skipped.
+func secretRedactor() *redact.Redactor {
+	redactorOnce.Do(func() {
+		if !config.Redact {
+			return
+		}
+
+		disabled := make(map[string]bool)
+		for _, name := range config.RedactDisabledRules {
+			disabled[name] = true
+		}
+		var rules []redact.Rule
+		for _, rule := range redact.DefaultRules() {
+			if !disabled[rule.Name] {
+				rules = append(rules, rule)
+			}
+		}
+
+		// Custom rules in a stable order so placeholders don't depend on map iteration
+		names := make([]string, 0, len(config.RedactRules))
+		for name := range config.RedactRules {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			rule, err := redact.NewRule(name, config.RedactRules[name])
+			if err != nil {
+				fmt.Fprintln(os.Stderr, "Warning: skipping redaction rule:", err)
+				continue
+			}
+			rules = append(rules, rule)
+		}
+
+		var allowlist []*regexp.Regexp
+		for _, pattern := range config.RedactAllowlist {
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: skipping redaction allowlist pattern %q: %v\n", pattern, err)
+				continue
+			}
+			allowlist = append(allowlist, re)
+		}
+
+		entropy := config.RedactEntropy
+		if disabled[redact.EntropyRule] {
+			entropy = 0
+		}
+		redactor = redact.New(rules, allowlist, entropy)
+	})
+	return redactor
+}
+
+// reportRedactions tells the user which secrets were masked in the requests sent since
+// the last report
+func reportRedactions(terminal *ui.TerminalUI) {
+	r := secretRedactor()
+	if r == nil {
+		return
+	}
+	findings := r.TakeFindings()
+	if len(findings) == 0 {
+		return
+	}
+	terminal.AddMessage("system", fmt.Sprintf("Masked %d secret(s) before sending: %s (the model sees placeholders such as %s)",
+		len(findings), redact.Summarize(findings), findings[0].Placeholder))
+}
+
+// redactMessage masks the secrets in a message the way they are masked when it is sent,
+// for storing it
+func redactMessage(msg api.ChatMessage) api.ChatMessage {
+	r := secretRedactor()
+	if r == nil {
+		return msg
+	}
+	msg.Content = r.Redact(msg.Content)
+	if len(msg.ToolCalls) > 0 {
+		calls := make([]api.ToolCall, len(msg.ToolCalls))
+		for i, call := range msg.ToolCalls {
+			args := make(api.ToolCallArguments, len(call.Function.Arguments))
+			for key, value := range call.Function.Arguments {
+				if text, ok := value.(string); ok {
+					value = r.Redact(text)
+				}
+				args[key] = value
+			}
+			call.Function.Arguments = args
+			calls[i] = call
+		}
+		msg.ToolCalls = calls
+	}
+	return msg
+}
+
+// restoreSecrets replaces placeholders in generated code with the original secrets when
+// restore_secrets is enabled
+func restoreSecrets(text string) string {
+	if r := secretRedactor(); r != nil && config.RestoreSecrets {
+		return r.Restore(text)
+	}
+	return text
+}
+
+// unmaskSearch restores placeholders in the part of an edit that must match the file on
+// disk; it is always restored, since the model only saw the masked file
+func unmaskSearch(text string) string {
+	if r := secretRedactor(); r != nil {
+		return r.Restore(text)
+	}
+	return text
+}
@@ -0,0 +1,289 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ai-in-pm/Ollama-Code/api"
+	"github.com/ai-in-pm/Ollama-Code/context_manager"
+	"github.com/ai-in-pm/Ollama-Code/review"
+	"github.com/ai-in-pm/Ollama-Code/ui"
+	"github.com/spf13/cobra"
+)
+
+// reviewInstructions tell the model what to review and how to answer
+const reviewInstructions = `Review the change shown in the diff. The changed lines are shown under Focus, numbered as in the new version of the file, with the rest of the file as context. Report problems in the changed lines only: bugs, security issues, missing error handling, unhandled edge cases and clear maintainability problems. Do not report style preferences or restate what the change does.
+
+Answer with JSON only, in this form:
+{"findings": [{"line": <line number>, "end_line": <last line, optional>, "severity": "error" | "warning" | "info", "message": "<the problem and why it matters>", "suggestion": "<the fix, as code where possible>"}]}
+
+Use "error" for bugs and security issues, "warning" for likely problems and "info" for minor suggestions. Answer {"findings": []} if the change looks correct.`
+
+// reviewSource selects the changes to review
+type reviewSource struct {
+	staged    bool   // The staged changes
+	revisions string // "A..B", "A...B", or a revision compared with the work tree; empty for unstaged changes
+}
+
+// diff returns the changes to review
+func (s reviewSource) diff(repo *context_manager.GitRepo) (string, error) {
+	switch {
+	case s.staged:
+		return repo.Diff(true)
+	case s.revisions != "":
+		return repo.RangeDiff(s.revisions)
+	}
+	return repo.Diff(false)
+}
+
+// fileContent returns a changed file as it is after the changes
+func (s reviewSource) fileContent(repo *context_manager.GitRepo, path string) (string, error) {
+	if s.staged {
+		return repo.Show("", path)
+	}
+	for _, sep := range []string{"...", ".."} {
+		if _, to, ok := strings.Cut(s.revisions, sep); ok {
+			if to == "" {
+				to = "HEAD"
+			}
+			return repo.Show(to, path)
+		}
+	}
+	// Unstaged changes, or a single revision compared with the work tree
+	return readFileContent(filepath.Join(repo.Root, path))
+}
+
+// reviewChanges reviews every hunk of the changes with the surrounding code and returns
+// the findings sorted by file and line. Hunks that cannot be reviewed are reported and
+// listed in the report as skipped; on cancellation the report so far is returned with the
+// error.
+func reviewChanges(ctx context.Context, client api.Provider, terminal *ui.TerminalUI, source reviewSource) (review.Report, error) {
+	var report review.Report
+	if err := requireCapability(config.Model, capabilityCompletion, "review"); err != nil {
+		return report, err
+	}
+	workDir, err := os.Getwd()
+	if err != nil {
+		return report, err
+	}
+	repo, err := context_manager.OpenGitRepo(workDir)
+	if errors.Is(err, context_manager.ErrNotGitRepository) {
+		return report, errors.New("the current directory is not in a git repository")
+	} else if err != nil {
+		return report, err
+	}
+
+	diff, err := source.diff(repo)
+	if err != nil {
+		return report, err
+	}
+	cm := context_manager.NewContextManager(repo.Root)
+	cm.SetTokenBudget(fileContextBudget())
+
+	// Deleted and binary files have no lines to comment on, and ignored files are never sent
+	var files []review.FileDiff
+	total := 0
+	for _, file := range review.ParseDiff(diff) {
+		if file.Path != "" && !file.Binary && !cm.ShouldIgnoreGitPath(repo, file.Path) {
+			files = append(files, file)
+			total += len(file.Hunks)
+		}
+	}
+	if total == 0 {
+		return report, nil
+	}
+	defer reportRedactions(terminal)
+
+	skip := func(path string, hunk review.Hunk, reason string) {
+		start, end := hunk.NewRange()
+		report.Skipped = append(report.Skipped, review.Skipped{File: filepath.ToSlash(path), Line: start, EndLine: end, Reason: reason})
+	}
+	n := 0
+	for _, file := range files {
+		content, err := source.fileContent(repo, file.Path)
+		if err != nil {
+			terminal.AddMessage("system", fmt.Sprintf("Skipping %s: %v", file.Path, err))
+			for _, hunk := range file.Hunks {
+				skip(file.Path, hunk, err.Error())
+			}
+			n += len(file.Hunks)
+			continue
+		}
+
+		for _, hunk := range file.Hunks {
+			n++
+			start, end := hunk.NewRange()
+			terminal.SetLoading(true, fmt.Sprintf("Reviewing %s:%d-%d (%d/%d)...", file.Path, start, end, n, total))
+			found, err := reviewHunk(ctx, client, cm, repo.Root, file.Path, content, hunk)
+			terminal.SetLoading(false, "")
+			if errors.Is(err, api.ErrCanceled) {
+				review.Sort(report.Findings)
+				return report, err
+			}
+			if err != nil {
+				terminal.AddMessage("system", fmt.Sprintf("Skipping %s:%d-%d: %s", file.Path, start, end, describeError(err)))
+				skip(file.Path, hunk, describeError(err))
+				continue
+			}
+
+			for _, f := range found {
+				f.File = filepath.ToSlash(file.Path)
+				if f.Line <= 0 {
+					f.Line = start
+				}
+				report.Findings = append(report.Findings, f)
+			}
+		}
+	}
+
+	review.Sort(report.Findings)
+	return report, nil
+}
+
+// reviewHunk asks the model to review one hunk, with the file it changes as context
+func reviewHunk(ctx context.Context, client api.Provider, cm *context_manager.ContextManager, root string, relPath string, content string, hunk review.Hunk) ([]review.Finding, error) {
+	start, end := hunk.NewRange()
+	fileContext, err := cm.PackFileContext(filepath.Join(root, relPath), context_manager.PackOptions{
+		Focus:      context_manager.LineRange{Start: start, End: end},
+		FocusLabel: "changed lines",
+		Content:    content,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	request := fmt.Sprintf("Diff of %s:\n\n```diff\n%s\n```\n\n%s", relPath, hunk.String(), reviewInstructions)
+	resp, err := client.Chat(ctx, &api.ChatRequest{
+		Model: config.Model,
+		Messages: []api.ChatMessage{
+			{Role: api.RoleSystem, Content: systemPrompt("review")},
+			{Role: api.RoleUser, Content: buildPrompt("review", detectLanguage(relPath), fileContext, request)},
+		},
+		Format:  "json",
+		Options: generationOptions(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return review.ParseFindings(resp.Message.Content)
+}
+
+// parseReviewFlags reads --staged and --range A..B (or --range=A..B) from slash command
+// arguments
+func parseReviewFlags(args []string) (reviewSource, error) {
+	var source reviewSource
+	for i := 0; i < len(args); i++ {
+		switch {
+		case args[i] == "--staged":
+			source.staged = true
+		case strings.HasPrefix(args[i], "--range="):
+			source.revisions = strings.TrimPrefix(args[i], "--range=")
+		case args[i] == "--range" && i+1 < len(args):
+			source.revisions = args[i+1]
+			i++
+		default:
+			return source, fmt.Errorf("unknown option %q", args[i])
+		}
+	}
+	if source.staged && source.revisions != "" {
+		return source, errors.New("use either --staged or --range")
+	}
+	return source, nil
+}
+
+// runReview reviews the changes and shows the findings in the terminal
+func runReview(client api.Provider, terminal *ui.TerminalUI, source reviewSource) {
+	ctx, cancel := terminal.Cancellable(context.Background())
+	defer cancel()
+
+	report, err := reviewChanges(ctx, client, terminal, source)
+	if errors.Is(err, api.ErrCanceled) {
+		terminal.AddMessage("system", "Review interrupted; findings so far:")
+	} else if err != nil {
+		terminal.AddMessage("system", "Error: "+describeError(err))
+		return
+	}
+	terminal.AddMessage("system", review.Text(report))
+}
+
+// newReviewCmd creates the review command
+func newReviewCmd() *cobra.Command {
+	var source reviewSource
+	var format, output, failOn string
+
+	cmd := &cobra.Command{
+		Use:   "review [--staged | --range A..B]",
+		Short: "Review the current git changes and report findings",
+		Long: `Reviews the unstaged changes of the git repository (or the staged changes, or the changes
+between two revisions) hunk by hunk, each with the code around it, and reports findings with
+the file, line, severity, message and a suggested fix. Findings are shown as text or written
+as JSON or SARIF for CI; with --fail-on the command exits with status 1 when a finding is at
+least that severe. If a hunk could not be reviewed, the report says the review is incomplete
+and the command exits with status 1.`,
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			if source.staged && source.revisions != "" {
+				fmt.Fprintln(os.Stderr, "Error: use either --staged or --range")
+				os.Exit(1)
+			}
+			if _, err := review.Render(review.Report{}, format); err != nil {
+				fmt.Fprintln(os.Stderr, "Error:", err)
+				os.Exit(1)
+			}
+			var threshold review.Severity
+			if failOn != "" {
+				var err error
+				if threshold, err = review.ParseSeverity(failOn); err != nil {
+					fmt.Fprintln(os.Stderr, "Error:", err)
+					os.Exit(1)
+				}
+			}
+
+			terminal := ui.NewTerminalUI()
+			ctx, cancel := terminal.Cancellable(context.Background())
+			defer cancel()
+			result, err := reviewChanges(ctx, newProvider(), terminal, source)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, "Error:", describeError(err))
+				os.Exit(1)
+			}
+
+			report, err := review.Render(result, format)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, "Error:", err)
+				os.Exit(1)
+			}
+			if output != "" {
+				if err := os.WriteFile(output, []byte(report+"\n"), 0644); err != nil {
+					fmt.Fprintln(os.Stderr, "Error writing report:", err)
+					os.Exit(1)
+				}
+				fmt.Fprintf(os.Stderr, "Wrote %d finding(s) to %s\n", len(result.Findings), output)
+			} else {
+				fmt.Println(report)
+			}
+
+			if !result.Complete() {
+				fmt.Fprintf(os.Stderr, "Error: review incomplete, %d hunk(s) could not be reviewed\n", len(result.Skipped))
+				os.Exit(1)
+			}
+			if threshold != "" {
+				for _, f := range result.Findings {
+					if f.Severity.AtLeast(threshold) {
+						os.Exit(1)
+					}
+				}
+			}
+		},
+	}
+	cmd.Flags().BoolVar(&source.staged, "staged", false, "Review the staged changes")
+	cmd.Flags().StringVar(&source.revisions, "range", "", "Review the changes between two revisions, e.g. main..HEAD")
+	cmd.Flags().StringVar(&format, "format", review.FormatText, "Output format: text, json or sarif")
+	cmd.Flags().StringVarP(&output, "output", "o", "", "Write the report to a file instead of standard output")
+	cmd.Flags().StringVar(&failOn, "fail-on", "", "Exit with status 1 if a finding is at least this severe: error, warning or info")
+	return cmd
+}
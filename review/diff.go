@@ -0,0 +1,128 @@
+package review
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// FileDiff is the part of a unified diff that changes one file
+type FileDiff struct {
+	OldPath string // Empty for a new file
+	Path    string // Empty for a deleted file
+	Binary  bool
+	Hunks   []Hunk
+}
+
+// Hunk is a group of changed lines with the unchanged lines around them
+type Hunk struct {
+	OldStart, OldLines int
+	NewStart, NewLines int
+	Section            string   // The text after the range, usually the enclosing function
+	Lines              []string // Lines prefixed with ' ', '-' or '+'
+}
+
+// hunkHeader matches "@@ -1,5 +1,6 @@ func main() {"
+var hunkHeader = regexp.MustCompile(`^@@ -(\d+)(?:,(\d+))? \+(\d+)(?:,(\d+))? @@ ?(.*)$`)
+
+// ParseDiff splits git's unified diff output into files and hunks
+func ParseDiff(diff string) []FileDiff {
+	var files []FileDiff
+	var file *FileDiff
+	var hunk *Hunk
+
+	flushHunk := func() {
+		if file != nil && hunk != nil {
+			file.Hunks = append(file.Hunks, *hunk)
+		}
+		hunk = nil
+	}
+	flushFile := func() {
+		flushHunk()
+		if file != nil {
+			files = append(files, *file)
+		}
+		file = nil
+	}
+
+	for _, line := range strings.Split(diff, "\n") {
+		switch {
+		case strings.HasPrefix(line, "diff --git "):
+			flushFile()
+			file = &FileDiff{}
+			// Used when there are no ---/+++ lines, e.g. for binary files or renames
+			if a, b, ok := strings.Cut(strings.TrimPrefix(line, "diff --git "), " b/"); ok {
+				file.OldPath, file.Path = strings.TrimPrefix(a, "a/"), b
+			}
+		case file == nil:
+			continue
+		case hunk == nil && strings.HasPrefix(line, "--- "):
+			file.OldPath = diffPath(strings.TrimPrefix(line, "--- "))
+		case hunk == nil && strings.HasPrefix(line, "+++ "):
+			file.Path = diffPath(strings.TrimPrefix(line, "+++ "))
+		case hunk == nil && strings.HasPrefix(line, "Binary files "):
+			file.Binary = true
+		case strings.HasPrefix(line, "@@ "):
+			flushHunk()
+			m := hunkHeader.FindStringSubmatch(line)
+			if m == nil {
+				continue
+			}
+			hunk = &Hunk{
+				OldStart: atoi(m[1]), OldLines: count(m[2]),
+				NewStart: atoi(m[3]), NewLines: count(m[4]),
+				Section: m[5],
+			}
+		case hunk != nil && (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "-") || strings.HasPrefix(line, "+") || strings.HasPrefix(line, `\`)):
+			hunk.Lines = append(hunk.Lines, line)
+		}
+	}
+	flushFile()
+	return files
+}
+
+// diffPath strips the a/ or b/ prefix from a path in a ---/+++ line; /dev/null is empty
+func diffPath(path string) string {
+	path, _, _ = strings.Cut(path, "\t")
+	if path == "/dev/null" {
+		return ""
+	}
+	if strings.HasPrefix(path, "a/") || strings.HasPrefix(path, "b/") {
+		return path[2:]
+	}
+	return path
+}
+
+// atoi converts a number matched by hunkHeader
+func atoi(s string) int {
+	n, _ := strconv.Atoi(s)
+	return n
+}
+
+// count converts the optional line count of a hunk range, which is 1 when left out
+func count(s string) int {
+	if s == "" {
+		return 1
+	}
+	return atoi(s)
+}
+
+// String formats the hunk as in a unified diff
+func (h Hunk) String() string {
+	header := fmt.Sprintf("@@ -%d,%d +%d,%d @@", h.OldStart, h.OldLines, h.NewStart, h.NewLines)
+	if h.Section != "" {
+		header += " " + h.Section
+	}
+	return header + "\n" + strings.Join(h.Lines, "\n")
+}
+
+// NewRange returns the first and last line of the hunk in the new file. A hunk that only
+// removes lines covers the line before the removal.
+func (h Hunk) NewRange() (int, int) {
+	start := max(h.NewStart, 1)
+	if h.NewLines == 0 {
+		return start, start
+	}
+	return start, start + h.NewLines - 1
+}
@@ -0,0 +1,158 @@
+package review
+
+import (
+	"reflect"
+	"testing"
+)
+
+const sampleDiff = `diff --git a/main.go b/main.go
+index 1111111..2222222 100644
+--- a/main.go
++++ b/main.go
+@@ -3,4 +3,5 @@ import "fmt"
+ func main() {
+-	fmt.Println("hi")
++	fmt.Println("hello")
++	fmt.Println("world")
+ }
+@@ -20 +21,0 @@ func helper() {
+-	unused()
+diff --git a/new.txt b/new.txt
+new file mode 100644
+index 0000000..3333333
+--- /dev/null
++++ b/new.txt
+@@ -0,0 +1,2 @@
++one
++two
+\ No newline at end of file
+diff --git a/old.txt b/old.txt
+deleted file mode 100644
+index 4444444..0000000
+--- a/old.txt
++++ /dev/null
+@@ -1 +0,0 @@
+-gone
+diff --git a/logo.png b/logo.png
+index 5555555..6666666 100644
+Binary files a/logo.png and b/logo.png differ
+diff --git a/before.go b/after.go
+similarity index 100%
+rename from before.go
+rename to after.go
+`
+
+func TestParseDiff(t *testing.T) {
+	files := ParseDiff(sampleDiff)
+	want := []FileDiff{
+		{
+			OldPath: "main.go",
+			Path:    "main.go",
+			Hunks: []Hunk{
+				{
+					OldStart: 3, OldLines: 4, NewStart: 3, NewLines: 5,
+					Section: `import "fmt"`,
+					Lines:   []string{" func main() {", `-	fmt.Println("hi")`, `+	fmt.Println("hello")`, `+	fmt.Println("world")`, " }"},
+				},
+				{
+					OldStart: 20, OldLines: 1, NewStart: 21, NewLines: 0,
+					Section: "func helper() {",
+					Lines:   []string{"-	unused()"},
+				},
+			},
+		},
+		{
+			OldPath: "",
+			Path:    "new.txt",
+			Hunks: []Hunk{
+				{OldStart: 0, OldLines: 0, NewStart: 1, NewLines: 2, Lines: []string{"+one", "+two", `\ No newline at end of file`}},
+			},
+		},
+		{
+			OldPath: "old.txt",
+			Path:    "",
+			Hunks: []Hunk{
+				{OldStart: 1, OldLines: 1, NewStart: 0, NewLines: 0, Lines: []string{"-gone"}},
+			},
+		},
+		{OldPath: "logo.png", Path: "logo.png", Binary: true},
+		{OldPath: "before.go", Path: "after.go"},
+	}
+	if !reflect.DeepEqual(files, want) {
+		t.Errorf("ParseDiff:\n got %+v\nwant %+v", files, want)
+	}
+}
+
+func TestParseDiffEmpty(t *testing.T) {
+	if files := ParseDiff(""); len(files) != 0 {
+		t.Errorf("ParseDiff(\"\") = %+v", files)
+	}
+}
+
+func TestHunkNewRange(t *testing.T) {
+	tests := []struct {
+		hunk       Hunk
+		start, end int
+	}{
+		{Hunk{NewStart: 3, NewLines: 5}, 3, 7},
+		{Hunk{NewStart: 10, NewLines: 1}, 10, 10},
+		{Hunk{NewStart: 21, NewLines: 0}, 21, 21}, // Only removes lines
+		{Hunk{NewStart: 0, NewLines: 0}, 1, 1},    // Deletes the whole file
+	}
+	for _, tt := range tests {
+		start, end := tt.hunk.NewRange()
+		if start != tt.start || end != tt.end {
+			t.Errorf("NewRange of %+v = %d-%d, want %d-%d", tt.hunk, start, end, tt.start, tt.end)
+		}
+	}
+}
+
+func TestHunkStringRoundTrip(t *testing.T) {
+	files := ParseDiff(sampleDiff)
+	hunk := files[0].Hunks[0]
+	reparsed := ParseDiff("diff --git a/main.go b/main.go\n--- a/main.go\n+++ b/main.go\n" + hunk.String())
+	if len(reparsed) != 1 || len(reparsed[0].Hunks) != 1 || !reflect.DeepEqual(reparsed[0].Hunks[0], hunk) {
+		t.Errorf("hunk did not survive String and ParseDiff: %+v", reparsed)
+	}
+}
+
+func TestParseFindings(t *testing.T) {
+	tests := []struct {
+		name   string
+		answer string
+		want   []Finding
+	}{
+		{
+			"object",
+			`{"findings": [{"line": 4, "severity": "critical", "message": " nil dereference ", "suggestion": "check err"}]}`,
+			[]Finding{{Line: 4, Severity: SeverityError, Message: "nil dereference", Suggestion: "check err"}},
+		},
+		{
+			"bare list in a code block",
+			"```json\n[{\"line\": 2, \"end_line\": 3, \"severity\": \"nit\", \"message\": \"rename\"}]\n```",
+			[]Finding{{Line: 2, EndLine: 3, Severity: SeverityInfo, Message: "rename"}},
+		},
+		{
+			"unknown severity and empty message",
+			`{"findings": [{"line": 1, "severity": "spicy", "message": "odd"}, {"line": 2, "message": " "}]}`,
+			[]Finding{{Line: 1, Severity: SeverityWarning, Message: "odd"}},
+		},
+		{"no findings", `{"findings": []}`, nil},
+	}
+	for _, tt := range tests {
+		got, err := ParseFindings(tt.answer)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+
+	for _, answer := range []string{"Looks good to me!", `{"result": "ok"}`, `{}`, `{"findings": null}`} {
+		if _, err := ParseFindings(answer); err == nil {
+			t.Errorf("ParseFindings accepted %q", answer)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package review
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Severity is how serious a finding is
+type Severity string
+
+// Severities, from most to least serious
+const (
+	SeverityError   Severity = "error"   // A bug, security issue or broken behaviour
+	SeverityWarning Severity = "warning" // Likely a problem, or risky code
+	SeverityInfo    Severity = "info"    // Style, readability and minor suggestions
+)
+
+// ParseSeverity converts a severity name, accepting common synonyms such as "critical"
+// or "minor"; unknown names are reported as an error
+func ParseSeverity(name string) (Severity, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "error", "critical", "high", "blocker", "bug":
+		return SeverityError, nil
+	case "warning", "warn", "medium", "major":
+		return SeverityWarning, nil
+	case "info", "note", "low", "minor", "suggestion", "nit", "style":
+		return SeverityInfo, nil
+	}
+	return "", fmt.Errorf("unknown severity %q (use error, warning or info)", name)
+}
+
+// rank orders severities; higher is more serious
+func (s Severity) rank() int {
+	switch s {
+	case SeverityError:
+		return 3
+	case SeverityWarning:
+		return 2
+	case SeverityInfo:
+		return 1
+	}
+	return 0
+}
+
+// AtLeast reports whether s is as serious as other or more
+func (s Severity) AtLeast(other Severity) bool {
+	return s.rank() >= other.rank()
+}
+
+// Finding is a problem found in a change
+type Finding struct {
+	File       string   `json:"file"`
+	Line       int      `json:"line"`
+	EndLine    int      `json:"end_line,omitempty"`
+	Severity   Severity `json:"severity"`
+	Message    string   `json:"message"`
+	Suggestion string   `json:"suggestion,omitempty"` // The fix, as code or in words
+}
+
+// ParseFindings reads the findings from a model's answer, which should be a JSON object
+// with a "findings" list or a bare list; a code block around it is ignored. Findings
+// without a message are dropped and unknown severities become warnings.
+func ParseFindings(answer string) ([]Finding, error) {
+	text := strings.TrimSpace(answer)
+	if strings.HasPrefix(text, "```") {
+		if i := strings.Index(text, "\n"); i >= 0 {
+			text = text[i+1:]
+		}
+		text = strings.TrimSuffix(strings.TrimSpace(text), "```")
+	}
+
+	var raw []struct {
+		File       string `json:"file"`
+		Line       int    `json:"line"`
+		EndLine    int    `json:"end_line"`
+		Severity   string `json:"severity"`
+		Message    string `json:"message"`
+		Suggestion string `json:"suggestion"`
+	}
+	var err error
+	if strings.HasPrefix(text, "[") {
+		err = json.Unmarshal([]byte(text), &raw)
+	} else {
+		var wrapped struct {
+			Findings *json.RawMessage `json:"findings"`
+		}
+		if err = json.Unmarshal([]byte(text), &wrapped); err == nil {
+			if wrapped.Findings == nil {
+				err = errors.New(`no "findings" list in the answer`)
+			} else {
+				err = json.Unmarshal(*wrapped.Findings, &raw)
+			}
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse review findings: %w", err)
+	}
+
+	var findings []Finding
+	for _, r := range raw {
+		if strings.TrimSpace(r.Message) == "" {
+			continue
+		}
+		severity, err := ParseSeverity(r.Severity)
+		if err != nil {
+			severity = SeverityWarning
+		}
+		findings = append(findings, Finding{
+			File:       r.File,
+			Line:       r.Line,
+			EndLine:    r.EndLine,
+			Severity:   severity,
+			Message:    strings.TrimSpace(r.Message),
+			Suggestion: strings.TrimSpace(r.Suggestion),
+		})
+	}
+	return findings, nil
+}
+
+// Sort orders findings by file and line
+func Sort(findings []Finding) {
+	sort.SliceStable(findings, func(i, j int) bool {
+		if findings[i].File != findings[j].File {
+			return findings[i].File < findings[j].File
+		}
+		return findings[i].Line < findings[j].Line
+	})
+}
+
+// Count returns the number of findings per severity
+func Count(findings []Finding) map[Severity]int {
+	counts := make(map[Severity]int)
+	for _, f := range findings {
+		counts[f.Severity]++
+	}
+	return counts
+}
@@ -0,0 +1,266 @@
+package review
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Output formats
+const (
+	FormatText  = "text"
+	FormatJSON  = "json"
+	FormatSARIF = "sarif"
+)
+
+// ToolName and ToolURI identify the reviewer in SARIF reports
+const (
+	ToolName = "ollama-code"
+	ToolURI  = "https://github.com/ai-in-pm/Ollama-Code"
+)
+
+// ruleID is the SARIF rule all findings are reported under
+const ruleID = "code-review"
+
+// Skipped is a hunk that could not be reviewed
+type Skipped struct {
+	File    string `json:"file"`
+	Line    int    `json:"line"`
+	EndLine int    `json:"end_line,omitempty"`
+	Reason  string `json:"reason"`
+}
+
+// Report holds the findings of a review and the hunks it skipped
+type Report struct {
+	Findings []Finding
+	Skipped  []Skipped
+}
+
+// Complete reports whether every hunk was reviewed
+func (r Report) Complete() bool {
+	return len(r.Skipped) == 0
+}
+
+// location formats a file and line range as "file:line-end"
+func location(file string, line, endLine int) string {
+	if line > 0 {
+		file += fmt.Sprintf(":%d", line)
+	}
+	if endLine > line {
+		file += fmt.Sprintf("-%d", endLine)
+	}
+	return file
+}
+
+// Render formats a report as text, JSON or SARIF
+func Render(report Report, format string) (string, error) {
+	switch format {
+	case FormatText, "":
+		return Text(report), nil
+	case FormatJSON:
+		return JSON(report)
+	case FormatSARIF:
+		return SARIF(report)
+	}
+	return "", fmt.Errorf("unknown format %q (use text, json or sarif)", format)
+}
+
+// Text formats a report for the terminal, one finding per line with the suggested fix
+// indented below, followed by a count per severity and the hunks that were skipped
+func Text(report Report) string {
+	findings := report.Findings
+	var sb strings.Builder
+	if len(findings) == 0 {
+		sb.WriteString("No findings")
+	}
+	for _, f := range findings {
+		sb.WriteString(fmt.Sprintf("%s %s: %s\n", location(f.File, f.Line, f.EndLine), f.Severity, f.Message))
+		if f.Suggestion != "" {
+			sb.WriteString("  Suggested fix:\n")
+			for _, line := range strings.Split(f.Suggestion, "\n") {
+				sb.WriteString("    " + line + "\n")
+			}
+		}
+	}
+
+	if len(findings) > 0 {
+		counts := Count(findings)
+		var parts []string
+		for _, s := range []Severity{SeverityError, SeverityWarning, SeverityInfo} {
+			if counts[s] > 0 {
+				parts = append(parts, fmt.Sprintf("%d %s", counts[s], s))
+			}
+		}
+		sb.WriteString(fmt.Sprintf("\n%d finding(s): %s", len(findings), strings.Join(parts, ", ")))
+	}
+
+	if !report.Complete() {
+		sb.WriteString(fmt.Sprintf("\n\nReview incomplete: %d hunk(s) could not be reviewed:", len(report.Skipped)))
+		for _, s := range report.Skipped {
+			sb.WriteString(fmt.Sprintf("\n  %s: %s", location(s.File, s.Line, s.EndLine), s.Reason))
+		}
+	}
+	return sb.String()
+}
+
+// jsonReport is the JSON form of a report
+type jsonReport struct {
+	Complete bool      `json:"complete"`
+	Findings []Finding `json:"findings"`
+	Skipped  []Skipped `json:"skipped,omitempty"`
+}
+
+// JSON formats a report as an indented JSON object with the findings, whether the review
+// is complete and the hunks that were skipped
+func JSON(report Report) (string, error) {
+	out := jsonReport{Complete: report.Complete(), Findings: report.Findings, Skipped: report.Skipped}
+	if out.Findings == nil {
+		out.Findings = []Finding{}
+	}
+	data, err := json.MarshalIndent(out, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to encode findings: %w", err)
+	}
+	return string(data), nil
+}
+
+// SARIF 2.1.0 log, reduced to what code scanning tools read
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool        sarifTool         `json:"tool"`
+	Invocations []sarifInvocation `json:"invocations"`
+	Results     []sarifResult     `json:"results"`
+}
+
+type sarifInvocation struct {
+	ExecutionSuccessful        bool                `json:"executionSuccessful"`
+	ToolExecutionNotifications []sarifNotification `json:"toolExecutionNotifications,omitempty"`
+}
+
+type sarifNotification struct {
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID               string       `json:"id"`
+	ShortDescription sarifMessage `json:"shortDescription"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID     string            `json:"ruleId"`
+	Level      string            `json:"level"`
+	Message    sarifMessage      `json:"message"`
+	Locations  []sarifLocation   `json:"locations"`
+	Properties map[string]string `json:"properties,omitempty"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifact `json:"artifactLocation"`
+	Region           *sarifRegion  `json:"region,omitempty"`
+}
+
+type sarifArtifact struct {
+	URI string `json:"uri"`
+}
+
+type sarifRegion struct {
+	StartLine int `json:"startLine"`
+	EndLine   int `json:"endLine,omitempty"`
+}
+
+// sarifLevel maps a severity to a SARIF result level
+func sarifLevel(s Severity) string {
+	switch s {
+	case SeverityError:
+		return "error"
+	case SeverityWarning:
+		return "warning"
+	}
+	return "note"
+}
+
+// sarifLocations returns the location of a file and line range
+func sarifLocations(file string, line, endLine int) []sarifLocation {
+	location := sarifLocation{PhysicalLocation: sarifPhysicalLocation{ArtifactLocation: sarifArtifact{URI: file}}}
+	if line > 0 {
+		region := &sarifRegion{StartLine: line}
+		if endLine > line {
+			region.EndLine = endLine
+		}
+		location.PhysicalLocation.Region = region
+	}
+	return []sarifLocation{location}
+}
+
+// SARIF formats a report as a SARIF 2.1.0 log for code scanning in CI. The suggested fix
+// is appended to the message and kept in the "suggestion" property. Skipped hunks make the
+// invocation unsuccessful and are listed as error notifications.
+func SARIF(report Report) (string, error) {
+	results := make([]sarifResult, 0, len(report.Findings))
+	for _, f := range report.Findings {
+		result := sarifResult{
+			RuleID:    ruleID,
+			Level:     sarifLevel(f.Severity),
+			Message:   sarifMessage{Text: f.Message},
+			Locations: sarifLocations(f.File, f.Line, f.EndLine),
+		}
+		if f.Suggestion != "" {
+			result.Message.Text += "\n\nSuggested fix:\n" + f.Suggestion
+			result.Properties = map[string]string{"suggestion": f.Suggestion}
+		}
+		results = append(results, result)
+	}
+
+	invocation := sarifInvocation{ExecutionSuccessful: report.Complete()}
+	for _, skipped := range report.Skipped {
+		invocation.ToolExecutionNotifications = append(invocation.ToolExecutionNotifications, sarifNotification{
+			Level:     "error",
+			Message:   sarifMessage{Text: "Hunk not reviewed: " + skipped.Reason},
+			Locations: sarifLocations(skipped.File, skipped.Line, skipped.EndLine),
+		})
+	}
+
+	log := sarifLog{
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Version: "2.1.0",
+		Runs: []sarifRun{{
+			Tool: sarifTool{Driver: sarifDriver{
+				Name:           ToolName,
+				InformationURI: ToolURI,
+				Rules:          []sarifRule{{ID: ruleID, ShortDescription: sarifMessage{Text: "Problem found by a model review of the change"}}},
+			}},
+			Invocations: []sarifInvocation{invocation},
+			Results:     results,
+		}},
+	}
+	data, err := json.MarshalIndent(log, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to encode SARIF report: %w", err)
+	}
+	return string(data), nil
+}
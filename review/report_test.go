@@ -0,0 +1,66 @@
+package review
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestRenderIncompleteReview(t *testing.T) {
+	report := Report{
+		Findings: []Finding{{File: "a.go", Line: 3, Severity: SeverityError, Message: "nil dereference"}},
+		Skipped:  []Skipped{{File: "b.go", Line: 10, EndLine: 14, Reason: "failed to parse review findings"}},
+	}
+
+	text := Text(report)
+	if !strings.Contains(text, "a.go:3 error: nil dereference") || !strings.Contains(text, "Review incomplete: 1 hunk(s)") || !strings.Contains(text, "b.go:10-14: failed to parse") {
+		t.Errorf("text report:\n%s", text)
+	}
+	if text := Text(Report{Skipped: report.Skipped}); !strings.HasPrefix(text, "No findings\n\nReview incomplete") {
+		t.Errorf("text report without findings:\n%s", text)
+	}
+
+	out, err := JSON(report)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded struct {
+		Complete bool      `json:"complete"`
+		Findings []Finding `json:"findings"`
+		Skipped  []Skipped `json:"skipped"`
+	}
+	if err := json.Unmarshal([]byte(out), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Complete || len(decoded.Findings) != 1 || len(decoded.Skipped) != 1 || decoded.Skipped[0].EndLine != 14 {
+		t.Errorf("JSON report: %s", out)
+	}
+
+	out, err = SARIF(report)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var log sarifLog
+	if err := json.Unmarshal([]byte(out), &log); err != nil {
+		t.Fatal(err)
+	}
+	invocation := log.Runs[0].Invocations[0]
+	if invocation.ExecutionSuccessful || len(invocation.ToolExecutionNotifications) != 1 || len(log.Runs[0].Results) != 1 {
+		t.Errorf("SARIF report: %s", out)
+	}
+}
+
+func TestRenderCompleteReview(t *testing.T) {
+	if text := Text(Report{}); text != "No findings" {
+		t.Errorf("Text = %q", text)
+	}
+	for format, want := range map[string]string{FormatJSON: `"complete": true`, FormatSARIF: `"executionSuccessful": true`} {
+		out, err := Render(Report{}, format)
+		if err != nil || !strings.Contains(out, want) {
+			t.Errorf("Render(%s) = %s, %v, want it to contain %s", format, out, err, want)
+		}
+	}
+	if _, err := Render(Report{}, "xml"); err == nil {
+		t.Error("Render accepted an unknown format")
+	}
+}
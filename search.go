@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ai-in-pm/Ollama-Code/api"
+	"github.com/ai-in-pm/Ollama-Code/context_manager"
+	"github.com/ai-in-pm/Ollama-Code/ui"
+	"github.com/spf13/cobra"
+)
+
+// defaultSearchResults is the number of files search returns unless told otherwise
+const defaultSearchResults = 10
+
+// embedFunc adapts the client's embedding endpoint for the context manager
+func embedFunc(client api.Provider, model string) context_manager.EmbedFunc {
+	return func(ctx context.Context, texts []string) ([][]float32, error) {
+		resp, err := client.Embed(ctx, &api.EmbedRequest{Model: model, Input: texts})
+		if err != nil {
+			return nil, err
+		}
+		return resp.Embeddings, nil
+	}
+}
+
+// newSearchManager creates a context manager for the project in the working directory,
+// with semantic search enabled when an embedding model is configured
+func newSearchManager(client api.Provider) (*context_manager.ContextManager, string, error) {
+	workDir, err := os.Getwd()
+	if err != nil {
+		return nil, "", err
+	}
+	root := findMarkedRoot(workDir)
+	if root == "" {
+		root = workDir
+	}
+
+	cm := context_manager.NewContextManager(root)
+	if config.EmbeddingModel != "" {
+		cm.EnableSemanticSearch(config.EmbeddingModel, embedFunc(client, config.EmbeddingModel))
+	}
+	return cm, root, nil
+}
+
+// searchProject ranks the project's files by relevance to a query, falling back to keyword
+// search when the embedding model cannot be used
+func searchProject(ctx context.Context, client api.Provider, terminal *ui.TerminalUI, query string, maxFiles int) ([]context_manager.RelevantFile, string, error) {
+	cm, root, err := newSearchManager(client)
+	if err != nil {
+		return nil, "", err
+	}
+
+	// A model that cannot embed is not tried at all
+	if cm.SemanticIndex() != nil {
+		if err := requireCapability(config.EmbeddingModel, capabilityEmbedding, "semantic search"); err != nil {
+			terminal.AddMessage("system", "Semantic search unavailable ("+err.Error()+"), using keyword search")
+			results, err := cm.SearchKeywords(query, maxFiles)
+			return results, root, err
+		}
+	}
+
+	results, err := cm.GetRelevantFiles(ctx, query, maxFiles)
+	if err != nil && cm.SemanticIndex() != nil && ctx.Err() == nil {
+		reason := describeError(err)
+		if errors.Is(err, api.ErrModelNotFound) {
+			reason = fmt.Sprintf("embedding model '%s' is not available; pull it with 'ollama-code models pull %s'", config.EmbeddingModel, config.EmbeddingModel)
+		}
+		terminal.AddMessage("system", "Semantic search unavailable ("+reason+"), using keyword search")
+		results, err = cm.SearchKeywords(query, maxFiles)
+	}
+	return results, root, err
+}
+
+// formatRelevantFiles lists search results with their scores, line ranges and matching lines
+func formatRelevantFiles(root string, results []context_manager.RelevantFile) string {
+	if len(results) == 0 {
+		return "No relevant files found"
+	}
+
+	var sb strings.Builder
+	for i, result := range results {
+		relPath, err := filepath.Rel(root, result.Path)
+		if err != nil {
+			relPath = result.Path
+		}
+		var ranges []string
+		for _, r := range result.Ranges {
+			if r.Start == r.End {
+				ranges = append(ranges, fmt.Sprintf("%d", r.Start))
+			} else {
+				ranges = append(ranges, fmt.Sprintf("%d-%d", r.Start, r.End))
+			}
+		}
+		if i > 0 {
+			sb.WriteString("\n")
+		}
+		fmt.Fprintf(&sb, "%2d. %.3f  %s", i+1, result.Score, relPath)
+		if len(ranges) > 0 {
+			fmt.Fprintf(&sb, ":%s", strings.Join(ranges, ","))
+		}
+		for _, match := range result.Matches {
+			fmt.Fprintf(&sb, "\n      %5d: %s", match.Line, match.Text)
+		}
+	}
+	return sb.String()
+}
+
+// runSearch searches the project and shows the results
+func runSearch(client api.Provider, terminal *ui.TerminalUI, query string, maxFiles int) {
+	ctx, cancel := terminal.Cancellable(context.Background())
+	defer cancel()
+
+	terminal.SetLoading(true, "Updating the search index...")
+	results, root, err := searchProject(ctx, client, terminal, query, maxFiles)
+	terminal.SetLoading(false, "")
+	reportRedactions(terminal)
+	if err != nil {
+		terminal.AddMessage("system", "Error: "+describeError(err))
+		return
+	}
+	terminal.AddMessage("system", formatRelevantFiles(root, results))
+}
+
+// newSearchCmd creates the search command
+func newSearchCmd() *cobra.Command {
+	var maxFiles int
+
+	cmd := &cobra.Command{
+		Use:   "search [query]",
+		Short: "Find the files and lines most relevant to a question",
+		Long: `Ranks the files of the current project by semantic similarity to the query using the
+configured embedding model. The index is stored in .ollama-code/ under the project root and
+only files changed since the last search are embedded again. Without an embedding model,
+files are ranked by keyword relevance (BM25) and the matching lines are shown.`,
+		Args: cobra.MinimumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			runSearch(newProvider(), ui.NewTerminalUI(), strings.Join(args, " "), maxFiles)
+		},
+	}
+	cmd.Flags().IntVar(&maxFiles, "max", defaultSearchResults, "Maximum number of files to return")
+	return cmd
+}
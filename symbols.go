@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/ai-in-pm/Ollama-Code/context_manager"
+)
+
+// symbolReference matches "Name" and "Parent.Name"
+var symbolReference = regexp.MustCompile(`^[A-Za-z_]\w*(?:\.[A-Za-z_]\w*)?$`)
+
+// splitSymbolTarget splits "path#Symbol" into the path and the symbol reference. The path
+// may be empty ("#Symbol") to look the symbol up across the project.
+func splitSymbolTarget(arg string) (string, string) {
+	i := strings.LastIndex(arg, "#")
+	if i < 0 || !symbolReference.MatchString(arg[i+1:]) {
+		return arg, ""
+	}
+	return arg[:i], arg[i+1:]
+}
+
+// findSymbol resolves a symbol reference in a file, or across the project in the working
+// directory when filePath is empty, returning the symbol and its project root
+func findSymbol(filePath, name string) (context_manager.Symbol, string, error) {
+	var root string
+	var matches []context_manager.Symbol
+	if filePath == "" {
+		workDir, err := os.Getwd()
+		if err != nil {
+			return context_manager.Symbol{}, "", err
+		}
+		if root = findMarkedRoot(workDir); root == "" {
+			root = workDir
+		}
+		matches, err = context_manager.NewContextManager(root).Symbols().Lookup(name)
+		if err != nil {
+			return context_manager.Symbol{}, "", fmt.Errorf("failed to index symbols: %w", err)
+		}
+	} else {
+		absPath, err := filepath.Abs(filePath)
+		if err != nil {
+			return context_manager.Symbol{}, "", err
+		}
+		if !context_manager.SupportsSymbols(absPath) {
+			return context_manager.Symbol{}, "", fmt.Errorf("symbols are not supported for %s files", filepath.Ext(absPath))
+		}
+		root = findProjectRoot(absPath)
+		symbols, err := context_manager.NewContextManager(root).Symbols().FileSymbols(absPath)
+		if err != nil {
+			return context_manager.Symbol{}, "", err
+		}
+		matches = context_manager.FindSymbols(symbols, name)
+	}
+
+	switch len(matches) {
+	case 0:
+		if filePath == "" {
+			return context_manager.Symbol{}, "", fmt.Errorf("symbol %s not found in the project", name)
+		}
+		return context_manager.Symbol{}, "", fmt.Errorf("symbol %s not found in %s", name, filePath)
+	case 1:
+		return matches[0], root, nil
+	}
+
+	candidates := make([]string, len(matches))
+	for i, s := range matches {
+		candidates[i] = "  " + context_manager.FormatSymbol(s, root)
+	}
+	return context_manager.Symbol{}, "", fmt.Errorf("symbol %s is ambiguous; qualify it with its type or file:\n%s", name, strings.Join(candidates, "\n"))
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/ai-in-pm/Ollama-Code/context_manager"
+	"github.com/ai-in-pm/Ollama-Code/edits"
+)
+
+// fileTarget is the file a file command works on and, optionally, the lines to focus on
+type fileTarget struct {
+	Path  string                    // The file as given, or the file declaring the symbol
+	Focus context_manager.LineRange // Zero for the whole file
+	Label string                    // Describes the focus, e.g. "function main"
+}
+
+// lineRangeTarget matches "path:START-END" and "path:LINE"
+var lineRangeTarget = regexp.MustCompile(`^(.+):(\d+)(?:-(\d+))?$`)
+
+// parseFileTarget interprets a file command argument: "path", "path:START-END",
+// "path:LINE", "path#Symbol" or "#Symbol" (looked up across the project). ok is false if
+// the argument doesn't name a file, so that it can be treated as a prompt instead.
+func parseFileTarget(arg string) (target fileTarget, ok bool, err error) {
+	if isFile(arg) {
+		return fileTarget{Path: arg}, true, nil
+	}
+
+	if path, name := splitSymbolTarget(arg); name != "" && (path == "" || isFile(path)) {
+		symbol, _, err := findSymbol(path, name)
+		if err != nil {
+			return fileTarget{}, true, err
+		}
+		return fileTarget{
+			Path:  relativeToWorkDir(symbol.Path),
+			Focus: context_manager.LineRange{Start: symbol.Start, End: symbol.End},
+			Label: symbol.Kind + " " + symbol.QualifiedName(),
+		}, true, nil
+	}
+
+	if m := lineRangeTarget.FindStringSubmatch(arg); m != nil && isFile(m[1]) {
+		start, _ := strconv.Atoi(m[2])
+		end := start
+		if m[3] != "" {
+			end, _ = strconv.Atoi(m[3])
+		}
+		if start < 1 || end < start {
+			return fileTarget{}, true, fmt.Errorf("invalid line range %s:%s (use START-END with 1 <= START <= END)", m[1], strings.TrimPrefix(arg, m[1]+":"))
+		}
+		return fileTarget{Path: m[1], Focus: context_manager.LineRange{Start: start, End: end}}, true, nil
+	}
+	return fileTarget{}, false, nil
+}
+
+// relativeToWorkDir shortens a path below the working directory to a relative one
+func relativeToWorkDir(path string) string {
+	if workDir, err := os.Getwd(); err == nil {
+		if rel, err := filepath.Rel(workDir, path); err == nil && !strings.HasPrefix(rel, "..") {
+			return rel
+		}
+	}
+	return path
+}
+
+// isFile reports whether path names an existing regular file
+func isFile(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && !info.IsDir()
+}
+
+// focusInstructions tells the model which lines to work on and how line numbers are shown,
+// or returns "" for a whole-file target
+func focusInstructions(target fileTarget) string {
+	if target.Focus.Start == 0 {
+		return ""
+	}
+	return fmt.Sprintf("Work on lines %d-%d of %s, shown under Focus; the rest is context. "+
+		"Each line is prefixed with its line number in the file, which is not part of the code: "+
+		"refer to lines by these numbers and leave the prefixes out of any code you write.",
+		target.Focus.Start, target.Focus.End, target.Path)
+}
+
+// taskInstructions returns the instructions sent with a file command: how to read a focus
+// and, when edits will be applied, the edit format
+func taskInstructions(target fileTarget, apply bool) string {
+	var parts []string
+	if focus := focusInstructions(target); focus != "" {
+		parts = append(parts, focus)
+	}
+	if apply {
+		parts = append(parts, edits.FormatInstructions)
+	}
+	return strings.Join(parts, "\n\n")
+}
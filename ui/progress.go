@@ -0,0 +1,82 @@
+package ui
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// progressWidth is the number of cells in a progress bar
+const progressWidth = 30
+
+var (
+	progressDoneStyle = lipgloss.NewStyle().
+				Foreground(lipgloss.Color("#7D56F4"))
+
+	progressTodoStyle = lipgloss.NewStyle().
+				Foreground(lipgloss.Color("#444444"))
+)
+
+// SetProgress shows the progress of a long operation such as a download. With a total of
+// 0 only the message is shown; the bar is replaced by the next message.
+func (tui *TerminalUI) SetProgress(message string, completed, total int64) {
+	text := message
+	if total > 0 {
+		fraction := min(float64(completed)/float64(total), 1)
+		text = fmt.Sprintf("%s %s %3.0f%% %s/%s", message, progressBar(fraction, progressWidth), fraction*100, FormatBytes(completed), FormatBytes(total))
+	}
+
+	p := tui.running()
+	if p == nil && !isTerminal(os.Stderr) {
+		// Logs get one line per step instead of a redrawn bar
+		if message != tui.progressStatus {
+			fmt.Fprintln(os.Stderr, message)
+			tui.progressStatus = message
+		}
+		return
+	}
+	if p == nil {
+		tui.endStream()
+		// Redraw the same line, clearing what is left of a longer previous text
+		fmt.Fprintf(os.Stderr, "\r%s\033[K", text)
+		tui.progressLine = true
+		return
+	}
+	p.Send(loadingMsg{loading: true, message: text})
+}
+
+// endProgress moves past an inline progress bar
+func (tui *TerminalUI) endProgress() {
+	if tui.progressLine {
+		fmt.Fprintln(os.Stderr)
+		tui.progressLine = false
+	}
+}
+
+// progressBar draws a bar filled to the given fraction
+func progressBar(fraction float64, width int) string {
+	done := int(fraction * float64(width))
+	return progressDoneStyle.Render(strings.Repeat("█", done)) + progressTodoStyle.Render(strings.Repeat("░", width-done))
+}
+
+// FormatBytes formats a size in bytes with a binary unit, e.g. "4.7 GB"
+func FormatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	value, exp := float64(n)/unit, 0
+	for value >= unit && exp < 4 {
+		value /= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", value, "KMGTP"[exp])
+}
+
+// isTerminal reports whether a file is a terminal
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
@@ -1,7 +1,11 @@
 package ui
 
 import (
+	"bufio"
+	"context"
 	"fmt"
+	"os"
+	"os/signal"
 	"strings"
 	"sync"
 	"time"
@@ -47,6 +51,19 @@ var (
 			Background(lipgloss.Color("#333333")).
 			PaddingLeft(1).
 			PaddingRight(1)
+
+	diffAddStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("#88FF88"))
+
+	diffRemoveStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("#FF6666"))
+
+	diffHunkStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("#00FFFF"))
+
+	diffHeaderStyle = lipgloss.NewStyle().
+			Bold(true).
+			Foreground(lipgloss.Color("#FAFAFA"))
 )
 
 // Message represents a message in the chat history
@@ -69,8 +86,20 @@ type TerminalUI struct {
 	mutex      sync.Mutex
 	outputChan chan string
 	errChan    chan error
+	inputChan  chan string
 	statusMsg  string
 	statusType string // "info", "error", "success"
+
+	// program is set while the full-screen UI is running; without it output is printed inline
+	program        *tea.Program
+	confirm        *confirmMsg
+	stdin          *bufio.Reader
+	streaming      bool
+	progressLine   bool   // An inline progress bar is on the current line of stderr
+	progressStatus string // The last progress message printed when stderr is not a terminal
+
+	// cancel stops the request in flight, if any
+	cancel context.CancelFunc
 }
 
 // NewTerminalUI creates a new terminal UI
@@ -91,6 +120,7 @@ func NewTerminalUI() *TerminalUI {
 		messages:   []Message{},
 		outputChan: make(chan string, 100),
 		errChan:    make(chan error, 10),
+		inputChan:  make(chan string, 10),
 		statusMsg:  "Ready",
 		statusType: "info",
 	}
@@ -100,6 +130,10 @@ func NewTerminalUI() *TerminalUI {
 func (tui *TerminalUI) Start() error {
 	p := tea.NewProgram(tui)
 
+	tui.mutex.Lock()
+	tui.program = p
+	tui.mutex.Unlock()
+
 	// Handle streaming output and errors
 	go func() {
 		for {
@@ -119,26 +153,131 @@ func (tui *TerminalUI) Start() error {
 	}()
 
 	_, err := p.Run()
+
+	tui.mutex.Lock()
+	tui.program = nil
+	tui.mutex.Unlock()
+	close(tui.inputChan)
+
 	return err
 }
 
+// Stop quits the full-screen UI, if running, and restores the terminal
+func (tui *TerminalUI) Stop() {
+	if p := tui.running(); p != nil {
+		p.Quit()
+		p.Wait()
+	}
+}
+
+// running returns the active program, or nil when output is printed inline
+func (tui *TerminalUI) running() *tea.Program {
+	tui.mutex.Lock()
+	defer tui.mutex.Unlock()
+	return tui.program
+}
+
+// ReadInput blocks until the user submits a line, returning false once the UI has exited
+func (tui *TerminalUI) ReadInput() (string, bool) {
+	input, ok := <-tui.inputChan
+	return input, ok
+}
+
+// Cancellable returns a context for a model request that the user can stop with Esc or
+// Ctrl-C, or with SIGINT when the full-screen UI is not running. The returned function
+// must be called once the request has finished.
+func (tui *TerminalUI) Cancellable(parent context.Context) (context.Context, context.CancelFunc) {
+	if tui.running() == nil {
+		return signal.NotifyContext(parent, os.Interrupt)
+	}
+
+	ctx, cancel := context.WithCancel(parent)
+	tui.mutex.Lock()
+	tui.cancel = cancel
+	tui.mutex.Unlock()
+	return ctx, func() {
+		tui.mutex.Lock()
+		tui.cancel = nil
+		tui.mutex.Unlock()
+		cancel()
+	}
+}
+
+// cancelRequest stops the request in flight, reporting whether there was one
+func (tui *TerminalUI) cancelRequest() bool {
+	tui.mutex.Lock()
+	defer tui.mutex.Unlock()
+	if tui.cancel == nil {
+		return false
+	}
+	tui.cancel()
+	return true
+}
+
 // StreamOutput provides streaming output of AI responses
 func (tui *TerminalUI) StreamOutput(output string) {
+	if tui.running() == nil {
+		tui.streaming = true
+		fmt.Print(output)
+		return
+	}
 	tui.outputChan <- output
 }
 
 // ReportError reports an error to the UI
 func (tui *TerminalUI) ReportError(err error) {
+	if tui.running() == nil {
+		tui.endStream()
+		fmt.Fprintln(os.Stderr, errorStyle.Render("Error: "+err.Error()))
+		return
+	}
 	tui.errChan <- err
 }
 
 // SetLoading sets the loading state and status message
 func (tui *TerminalUI) SetLoading(loading bool, message string) {
-	if loading {
-		if message == "" {
-			message = "Loading..."
+	if loading && message == "" {
+		message = "Loading..."
+	}
+
+	p := tui.running()
+	if p == nil {
+		if loading {
+			tui.endProgress()
+			fmt.Fprintln(os.Stderr, infoStyle.Render("⏳ "+message))
+		} else {
+			tui.endStream()
 		}
-		tui.outputChan <- fmt.Sprintf("⏳ %s", message)
+		return
+	}
+	p.Send(loadingMsg{loading: loading, message: message})
+}
+
+// Confirm asks the user a yes/no question and blocks until it is answered
+func (tui *TerminalUI) Confirm(prompt string) bool {
+	p := tui.running()
+	if p == nil {
+		tui.endStream()
+		fmt.Fprint(os.Stderr, highlightStyle.Render(prompt+" [y/N]: "))
+		if tui.stdin == nil {
+			tui.stdin = bufio.NewReader(os.Stdin)
+		}
+		answer, _ := tui.stdin.ReadString('\n')
+		answer = strings.ToLower(strings.TrimSpace(answer))
+		return answer == "y" || answer == "yes"
+	}
+
+	reply := make(chan bool, 1)
+	p.Send(confirmMsg{prompt: prompt, reply: reply})
+	return <-reply
+}
+
+// endStream terminates a line of inline streamed output
+func (tui *TerminalUI) endStream() {
+	tui.endProgress()
+	if tui.streaming {
+		fmt.Println()
+		tui.streaming = false
 	}
 }
 
@@ -155,6 +294,12 @@ type errorMsg struct {
 
 type loadingMsg struct {
 	loading bool
+	message string
+}
+
+type confirmMsg struct {
+	prompt string
+	reply  chan bool
 }
 
 // Init initializes the TUI
@@ -174,9 +319,34 @@ func (tui *TerminalUI) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
+		// A pending confirmation captures all key presses until answered
+		if tui.confirm != nil {
+			answer := msg.Type == tea.KeyRunes && strings.EqualFold(string(msg.Runes), "y")
+			tui.confirm.reply <- answer
+			tui.confirm = nil
+			tui.statusMsg = "Ready"
+			tui.statusType = "info"
+			if answer {
+				tui.addMessage("system", "Approved")
+			} else {
+				tui.addMessage("system", "Denied")
+			}
+			if msg.Type == tea.KeyCtrlC || msg.Type == tea.KeyEsc {
+				tui.stopGenerating()
+			}
+			return tui, nil
+		}
+
 		switch msg.Type {
 		case tea.KeyCtrlC, tea.KeyEsc:
-			return tui, tea.Quit
+			// Stop the current generation; Ctrl-C quits when nothing is running
+			if tui.stopGenerating() {
+				return tui, nil
+			}
+			if msg.Type == tea.KeyCtrlC {
+				return tui, tea.Quit
+			}
+			return tui, nil
 		case tea.KeyEnter:
 			if tui.loading {
 				return tui, nil
@@ -187,16 +357,22 @@ func (tui *TerminalUI) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				return tui, nil
 			}
 
-			tui.AddMessage("user", userInput)
 			tui.textInput.Reset()
 
-			// Set loading state
-			tui.loading = true
-			cmds = append(cmds, func() tea.Msg {
-				return loadingMsg{loading: true}
-			})
+			// Hand the input to the session loop
+			select {
+			case tui.inputChan <- userInput:
+			default:
+				tui.statusMsg = "Busy, please wait"
+			}
 		}
 
+	case confirmMsg:
+		tui.confirm = &msg
+		tui.statusMsg = msg.prompt + " [y/N]"
+		tui.statusType = "info"
+		tui.addMessage("system", msg.prompt+" [y/N]")
+
 	case appendMessageMsg:
 		if msg.append {
 			// Append to the last message
@@ -205,10 +381,10 @@ func (tui *TerminalUI) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				tui.messages[len(tui.messages)-1].Content += msg.content
 				tui.mutex.Unlock()
 			} else {
-				tui.AddMessage(msg.role, msg.content)
+				tui.addMessage(msg.role, msg.content)
 			}
 		} else {
-			tui.AddMessage(msg.role, msg.content)
+			tui.addMessage(msg.role, msg.content)
 		}
 
 		tui.loading = false
@@ -225,12 +401,12 @@ func (tui *TerminalUI) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		tui.loading = false
 		tui.statusMsg = fmt.Sprintf("Error: %v", msg.err)
 		tui.statusType = "error"
-		tui.AddMessage("system", "Error: "+msg.err.Error())
+		tui.addMessage("system", "Error: "+msg.err.Error())
 
 	case loadingMsg:
 		tui.loading = msg.loading
 		if tui.loading {
-			tui.statusMsg = "Thinking..."
+			tui.statusMsg = msg.message
 			tui.statusType = "info"
 			cmds = append(cmds, spinner.Tick)
 		} else {
@@ -263,7 +439,8 @@ func (tui *TerminalUI) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 	// Update viewport
 	if tui.ready {
-		viewportCmd := tui.viewport.Update(msg)
+		var viewportCmd tea.Cmd
+		tui.viewport, viewportCmd = tui.viewport.Update(msg)
 		cmds = append(cmds, viewportCmd)
 	}
 
@@ -274,6 +451,16 @@ func (tui *TerminalUI) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	return tui, tea.Batch(cmds...)
 }
 
+// stopGenerating cancels the request in flight from within the update loop
+func (tui *TerminalUI) stopGenerating() bool {
+	if !tui.cancelRequest() {
+		return false
+	}
+	tui.statusMsg = "Stopping..."
+	tui.statusType = "info"
+	return true
+}
+
 // View renders the TUI
 func (tui *TerminalUI) View() string {
 	if !tui.ready {
@@ -301,6 +488,12 @@ func (tui *TerminalUI) View() string {
 		statusText = infoStyle.Render(tui.statusMsg)
 	}
 
+	tui.mutex.Lock()
+	if tui.cancel != nil {
+		statusText += infoStyle.Render("  (esc to stop)")
+	}
+	tui.mutex.Unlock()
+
 	if tui.loading {
 		sb.WriteString(fmt.Sprintf("%s %s\n", tui.spinner.View(), statusText))
 	} else {
@@ -316,18 +509,66 @@ func (tui *TerminalUI) View() string {
 
 // AddMessage adds a message to the chat history
 func (tui *TerminalUI) AddMessage(role, content string) {
-	tui.mutex.Lock()
-	defer tui.mutex.Unlock()
+	p := tui.running()
+	if p == nil {
+		tui.printMessage(role, content)
+		return
+	}
+	p.Send(appendMessageMsg{content: content, role: role})
+}
+
+// ShowDiff displays a unified diff with added and removed lines highlighted
+func (tui *TerminalUI) ShowDiff(diff string) {
+	tui.AddMessage("diff", diff)
+}
+
+// ReplayMessages shows previously recorded messages, e.g. when resuming a session.
+// Before the UI is started they are queued and rendered on the first draw.
+func (tui *TerminalUI) ReplayMessages(messages []Message) {
+	p := tui.running()
+	if p == nil {
+		tui.mutex.Lock()
+		tui.messages = append(tui.messages, messages...)
+		tui.mutex.Unlock()
+		return
+	}
+	for _, msg := range messages {
+		p.Send(appendMessageMsg{content: msg.Content, role: msg.Role})
+	}
+}
 
+// addMessage appends a message from within the update loop
+func (tui *TerminalUI) addMessage(role, content string) {
+	tui.mutex.Lock()
 	tui.messages = append(tui.messages, Message{
 		Role:    role,
 		Content: content,
 		Time:    time.Now(),
 	})
+	tui.mutex.Unlock()
 
 	tui.UpdateViewContent()
 }
 
+// printMessage writes a message directly to the terminal when the full-screen UI is not running
+func (tui *TerminalUI) printMessage(role, content string) {
+	if content == "" {
+		return
+	}
+	tui.endStream()
+
+	switch role {
+	case "user":
+		fmt.Fprintln(os.Stderr, promptStyle.Render("You: ")+userInputStyle.Render(content))
+	case "assistant":
+		fmt.Println(formatCodeBlocks(content, 100))
+	case "diff":
+		fmt.Println(formatDiff(content))
+	default:
+		fmt.Fprintln(os.Stderr, infoStyle.Render(content))
+	}
+}
+
 // UpdateViewContent updates the viewport with formatted messages
 func (tui *TerminalUI) UpdateViewContent() {
 	tui.mutex.Lock()
@@ -356,6 +597,8 @@ func (tui *TerminalUI) UpdateViewContent() {
 			content.WriteString(formattedContent)
 		case "system":
 			content.WriteString(infoStyle.Render(msg.Content))
+		case "diff":
+			content.WriteString(formatDiff(msg.Content))
 		}
 	}
 
@@ -405,6 +648,24 @@ func formatCodeBlocks(content string, width int) string {
 	return result.String()
 }
 
+// formatDiff colors the lines of a unified diff
+func formatDiff(diff string) string {
+	lines := strings.Split(strings.TrimSuffix(diff, "\n"), "\n")
+	for i, line := range lines {
+		switch {
+		case strings.HasPrefix(line, "+++"), strings.HasPrefix(line, "---"):
+			lines[i] = diffHeaderStyle.Render(line)
+		case strings.HasPrefix(line, "@@"):
+			lines[i] = diffHunkStyle.Render(line)
+		case strings.HasPrefix(line, "+"):
+			lines[i] = diffAddStyle.Render(line)
+		case strings.HasPrefix(line, "-"):
+			lines[i] = diffRemoveStyle.Render(line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
 // Helper function since Go doesn't have a built-in max function for ints
 func max(a, b int) int {
 	if a > b {